@@ -44,3 +44,24 @@ def load_graph(filename):
 
 def display_graph(graph, year_label, plt_handle):
     plt_handle.scatter(graph['deg_distr'][:,0], graph['deg_distr'][:,1], label=str(year_label))
+
+# Typed columnar output of the simulation's audits (see audit.ColumnarSink)
+COLUMN_DTYPES = {'int64': '<i8', 'float64': '<f8', 'string': 'u1', 'list<int64>': '<i8', 'list<int8>': 'i1'}
+
+def load_columns(folder):
+    import json
+    import os
+    with open(os.path.join(folder, 'schema.json')) as f:
+        schema = json.load(f)
+    data = {}
+    for column in schema['columns']:
+        values = np.fromfile(os.path.join(folder, column['values']), dtype=COLUMN_DTYPES[column['type']])
+        if 'offsets' in column:
+            offsets = np.fromfile(os.path.join(folder, column['offsets']), dtype='<i8')
+            if column['type'] == 'string':
+                values = [values[offsets[i]:offsets[i+1]].tobytes().decode('utf-8') for i in range(schema['rows'])]
+            else:
+                values = [values[offsets[i]:offsets[i+1]] for i in range(schema['rows'])]
+        valid = np.fromfile(os.path.join(folder, column['validity']), dtype=bool)
+        data[column['name']] = pd.Series(values).where(valid) if column['nulls'] > 0 else values
+    return pd.DataFrame(data)
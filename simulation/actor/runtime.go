@@ -0,0 +1,289 @@
+package actor
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+)
+
+// Message is exchanged between actors (identified by their ASN)
+type Message struct {
+	From    int
+	To      int
+	Payload interface{}
+}
+
+// Actor processes the messages of its inbox, new messages are sent using 'send'
+// An actor only accesses its own state, so that actors can run concurrently
+type Actor interface {
+	Receive(msg Message, send func(Message))
+}
+
+// Config describes the behavior of the network connecting actors
+type Config struct {
+	// LossRate is the probability that a message is lost
+	LossRate float64
+	// Retransmit lost messages: a lost message is sent again (and can be lost again)
+	// once its recipient has processed RetransmitDelay other messages, or when the
+	// recipient has nothing else to process (the timeout of the sender expires)
+	Retransmit      bool
+	RetransmitDelay int
+	// ReorderRate is the probability that a message is not processed in FIFO order
+	ReorderRate float64
+	Seed        int64
+}
+
+// Stats counts the messages handled by a Runtime
+type Stats struct {
+	Sent          int64
+	Delivered     int64
+	Lost          int64
+	Retransmitted int64
+	Reordered     int64
+}
+
+// retransmission is a lost message, sent again after 'after' processed messages
+type retransmission struct {
+	msg   Message
+	after int
+}
+
+// mailbox is the unbounded inbox of an actor
+// retransmissions are the lost messages waiting to be sent again to the actor
+type mailbox struct {
+	mutex           sync.Mutex
+	queue           []Message
+	retransmissions []retransmission
+	signal          chan bool
+}
+
+func newMailbox() *mailbox {
+	return &mailbox{
+		queue:  make([]Message, 0, 8),
+		signal: make(chan bool, 1),
+	}
+}
+
+func (m *mailbox) push(msg Message) {
+	m.mutex.Lock()
+	m.queue = append(m.queue, msg)
+	m.mutex.Unlock()
+
+	m.wake()
+}
+
+// delay holds a lost message until the actor has processed 'after' other messages
+func (m *mailbox) delay(msg Message, after int) {
+	m.mutex.Lock()
+	m.retransmissions = append(m.retransmissions, retransmission{msg: msg, after: after})
+	m.mutex.Unlock()
+
+	// The actor sends the message again if it's idle
+	m.wake()
+}
+
+// wake wakes up the actor (if it's not already awake)
+func (m *mailbox) wake() {
+	select {
+	case m.signal <- true:
+	default:
+	}
+}
+
+// tick counts a processed message, and returns the retransmissions that are due
+func (m *mailbox) tick() []Message {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	due := make([]Message, 0)
+	waiting := m.retransmissions[:0]
+	for _, rt := range m.retransmissions {
+		if rt.after--; rt.after <= 0 {
+			due = append(due, rt.msg)
+		} else {
+			waiting = append(waiting, rt)
+		}
+	}
+	m.retransmissions = waiting
+
+	return due
+}
+
+// expire returns the oldest retransmission, whose timeout expires since the actor is idle
+// returns false if no message is waiting to be sent again
+func (m *mailbox) expire() (Message, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if len(m.retransmissions) == 0 {
+		return Message{}, false
+	}
+
+	msg := m.retransmissions[0].msg
+	m.retransmissions = m.retransmissions[1:]
+
+	return msg, true
+}
+
+// pop extracts the next message to process, which is not the oldest one
+// with probability reorderRate
+// returns false if the mailbox is empty, and true if the message was reordered
+func (m *mailbox) pop(rng *rand.Rand, reorderRate float64) (Message, bool, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if len(m.queue) == 0 {
+		return Message{}, false, false
+	}
+
+	idx := 0
+	if len(m.queue) > 1 && rng.Float64() < reorderRate {
+		idx = 1 + rng.Intn(len(m.queue)-1)
+	}
+
+	msg := m.queue[idx]
+	m.queue = append(m.queue[:idx], m.queue[idx+1:]...)
+
+	return msg, true, idx > 0
+}
+
+// Runtime runs every actor in its own goroutine, until no message is in flight
+type Runtime struct {
+	config    Config
+	actors    map[int]Actor
+	mailboxes map[int]*mailbox
+	pending   int64
+	stats     Stats
+	done      chan bool
+	doneOnce  sync.Once
+}
+
+// InitRuntime creates a Runtime for a set of actors
+func InitRuntime(actors map[int]Actor, config Config) *Runtime {
+	runtime := Runtime{
+		config:    config,
+		actors:    actors,
+		mailboxes: make(map[int]*mailbox),
+		done:      make(chan bool),
+	}
+
+	for asn := range actors {
+		runtime.mailboxes[asn] = newMailbox()
+	}
+
+	return &runtime
+}
+
+// send delivers a message to the mailbox of its recipient, unless it is lost
+func (r *Runtime) send(msg Message, rng *rand.Rand) {
+	atomic.AddInt64(&r.stats.Sent, 1)
+
+	box, exists := r.mailboxes[msg.To]
+	if !exists {
+		atomic.AddInt64(&r.stats.Lost, 1)
+		return
+	}
+
+	if rng.Float64() < r.config.LossRate {
+		atomic.AddInt64(&r.stats.Lost, 1)
+		if r.config.Retransmit {
+			// The message is in flight until it is delivered
+			atomic.AddInt64(&r.pending, 1)
+			box.delay(msg, r.config.RetransmitDelay)
+		}
+		return
+	}
+
+	atomic.AddInt64(&r.pending, 1)
+	box.push(msg)
+}
+
+// retransmit sends a lost message again to the mailbox of its recipient
+func (r *Runtime) retransmit(box *mailbox, msg Message, rng *rand.Rand) {
+	atomic.AddInt64(&r.stats.Retransmitted, 1)
+
+	if rng.Float64() < r.config.LossRate {
+		atomic.AddInt64(&r.stats.Lost, 1)
+		box.delay(msg, r.config.RetransmitDelay)
+		return
+	}
+
+	box.push(msg)
+}
+
+// processed marks a message as handled, detecting quiescence
+func (r *Runtime) processed() {
+	if atomic.AddInt64(&r.pending, -1) == 0 {
+		r.doneOnce.Do(func() { close(r.done) })
+	}
+}
+
+func (r *Runtime) loop(asn int, rng *rand.Rand, stop chan bool, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	box := r.mailboxes[asn]
+	send := func(msg Message) { r.send(msg, rng) }
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-box.signal:
+		}
+
+		for {
+			msg, ok, reordered := box.pop(rng, r.config.ReorderRate)
+			if !ok {
+				lost, waiting := box.expire()
+				if !waiting {
+					break
+				}
+				r.retransmit(box, lost, rng)
+				continue
+			}
+			if reordered {
+				atomic.AddInt64(&r.stats.Reordered, 1)
+			}
+
+			r.actors[asn].Receive(msg, send)
+			atomic.AddInt64(&r.stats.Delivered, 1)
+
+			for _, lost := range box.tick() {
+				r.retransmit(box, lost, rng)
+			}
+
+			r.processed()
+		}
+	}
+}
+
+// Run sends the initial messages and waits until the system is quiescent
+// (no message is queued or being processed)
+// A Runtime can only be run once
+func (r *Runtime) Run(initial []Message) Stats {
+	rng := rand.New(rand.NewSource(r.config.Seed))
+
+	// Prevent early termination while the initial messages are sent
+	atomic.AddInt64(&r.pending, 1)
+	for _, msg := range initial {
+		r.send(msg, rng)
+	}
+
+	stop := make(chan bool)
+	var wg sync.WaitGroup
+
+	idx := int64(0)
+	for asn := range r.actors {
+		idx++
+		wg.Add(1)
+		go r.loop(asn, rand.New(rand.NewSource(r.config.Seed+idx)), stop, &wg)
+	}
+
+	r.processed()
+
+	<-r.done
+	close(stop)
+	wg.Wait()
+
+	return r.stats
+}
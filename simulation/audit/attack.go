@@ -0,0 +1,288 @@
+package audit
+
+import (
+	"fmt"
+	"sort"
+
+	"dedis.epfl.ch/audit/stats"
+	. "dedis.epfl.ch/core"
+	"dedis.epfl.ch/shell"
+	"dedis.epfl.ch/tz"
+	"dedis.epfl.ch/u"
+)
+
+// Strategies used by the adversary to choose the next link to delete
+const (
+	// PathLoadAttack deletes the link traversed by the most (sampled) audited routes
+	PathLoadAttack = iota
+	// BetweennessAttack deletes the link with the highest (sampled) edge betweenness
+	BetweennessAttack
+	// LandmarkAttack deletes the most loaded link of the top-level landmarks (tz.Graph only)
+	LandmarkAttack
+	// LandmarkOutageAttack takes down the top-level landmark traversed by the most (sampled)
+	// audited routes, through RemoveNode (tz.Graph only)
+	LandmarkOutageAttack
+)
+
+// MeasureTargetedAttack deletes 'steps' links (through RemoveEdge), or ASes (through RemoveNode)
+// with LandmarkOutageAttack, greedily choosing the most valuable one according to 'strategy'.
+// Scores are estimated over 'samples' random pairs (or sources)
+// For each step, the repair cost of the deletion and the stretch and valley rate of the
+// audited graph (over 'samples' random pairs among connected ASes) are recorded
+// A step that splits the graph is followed by a "disconnection" row, whose impacted
+// column counts the ASes cut off by the step (the rows of AS outages have endpointB -1)
+// returns the "impact", "stretch" and "valleyRate" metrics (one sample per step)
+// and the "finalStretch" and "disconnected" values
+func MeasureTargetedAttack(recorder *Recorder, baselineOriginal AbstractGraph, auditedOriginal AbstractGraph, strategy int, steps int, samples int) Result {
+
+	recorder.start("targeted-attack", map[string]interface{}{"strategy": strategy, "steps": steps, "samples": samples}, append([]Column{{"step", IntColumn}, {"event", StringColumn}, {"endpointA", IntColumn}, {"endpointB", IntColumn}, {"score", FloatColumn}, {"impacted", IntColumn}, {"disconnected", IntColumn}, {"stretch", FloatColumn}, {"valleyRate", FloatColumn}}, traceColumns(auditedOriginal)...))
+
+	recorder.seedRandom()
+
+	tracker := shell.StartTask("Attack steps", steps)
+	defer tracker.Finish()
+
+	// Conduct measurements on a copy of the graphs
+	baseline := baselineOriginal.Copy()
+	audited := auditedOriginal.Copy()
+
+	disconnectedNodes := make(map[int]bool)
+	// The ASes taken down are not used as endpoints either
+	excludedNodes := make(map[int]bool)
+
+	var impact, stretch, valleyRate stats.Sample
+	var lastStretch float64
+
+	for step := 0; step < steps; step++ {
+
+		var target [2]int
+		var score float64
+		var success bool
+		var impactedArea map[int]bool
+		var trace *tz.RepairTrace
+
+		event := "deletion"
+		deleted := false
+
+		if strategy == LandmarkOutageAttack {
+			event = "outage"
+			landmarks, scores := outageCandidates(audited, samples, excludedNodes)
+			for _, asn := range landmarks {
+				success, impactedArea, _, trace = removeNodeWithTrace(audited, asn)
+				if success || len(impactedArea) > 0 {
+					target = [2]int{asn, -1}
+					score = scores[asn]
+					deleted = true
+					break
+				}
+			}
+		} else {
+			candidates, scores := attackCandidates(audited, strategy, samples, excludedNodes)
+			for _, candidate := range candidates {
+				success, impactedArea, _, trace = removeEdgeWithTrace(audited, candidate[0], candidate[1])
+				if success || len(impactedArea) > 0 {
+					target = candidate
+					score = scores[candidate]
+					deleted = true
+					break
+				}
+			}
+		}
+
+		if !deleted {
+			fmt.Printf("No %s can be performed after %d steps\n", event, step)
+			break
+		}
+
+		if target[1] < 0 {
+			detachNode(baseline, target[0])
+			excludedNodes[target[0]] = true
+		} else {
+			baseline.RemoveEdge(target[0], target[1])
+		}
+
+		impactedNodes := len(impactedArea)
+		var separated map[int]bool
+		if !success {
+			// The graph is no more a connected component: the repair area is in the trace
+			separated = impactedArea
+			impactedNodes = 0
+			if trace != nil {
+				impactedNodes = len(trace.ImpactedArea)
+			}
+			disconnectedNodes = u.Union(disconnectedNodes, separated)
+			excludedNodes = u.Union(excludedNodes, separated)
+		}
+		stepStretch, stepValleyRate := sampledStretch(baseline, audited, samples, excludedNodes)
+		impact.Add(float64(impactedNodes))
+		stretch.Add(stepStretch)
+		valleyRate.Add(stepValleyRate)
+		lastStretch = stepStretch
+
+		tracker.Step()
+
+		row := []string{
+			u.Str(step),
+			event,
+			u.Str(target[0]),
+			u.Str(target[1]),
+			fmt.Sprintf("%f", score),
+			u.Str(impactedNodes),
+			u.Str(len(disconnectedNodes)),
+			fmt.Sprintf("%f", stepStretch),
+			fmt.Sprintf("%f", stepValleyRate),
+		}
+		recorder.record(append(row, formatTrace(trace)...)...)
+
+		if separated != nil {
+			// The messages of the step are all in the previous row
+			row[1] = "disconnection"
+			row[5] = u.Str(len(separated))
+			recorder.record(append(row, formatTrace(emptyTrace(trace))...)...)
+		}
+
+		fmt.Printf("	Step %d: %s of %d -> %d, stretch %f, valley rate %f\n", step, event, target[0], target[1], stepStretch, stepValleyRate)
+	}
+
+	result := newResult("targeted-attack")
+	result.addSample("impact", &impact)
+	result.addSample("stretch", &stretch)
+	result.addSample("valleyRate", &valleyRate)
+	result.Values["finalStretch"] = lastStretch
+	result.Values["disconnected"] = float64(len(disconnectedNodes))
+
+	recorder.end(&result)
+
+	return result
+}
+
+// emptyTrace returns a trace without messages, unless the trace is not available
+func emptyTrace(trace *tz.RepairTrace) *tz.RepairTrace {
+	if trace == nil {
+		return nil
+	}
+	return &tz.RepairTrace{}
+}
+
+// outageCandidates returns the top-level landmarks sorted by decreasing number of
+// (sampled) audited routes traversing them
+func outageCandidates(audited AbstractGraph, samples int, excludedNodes map[int]bool) ([]int, map[int]float64) {
+	tzAudited, isTz := audited.(*tz.Graph)
+	if !isTz {
+		panic("LandmarkOutageAttack only works on tz.Graph")
+	}
+
+	routes, _ := routePairs(audited, randomPairs(audited, samples, excludedNodes))
+	_, asLoad := pathLoads(routes)
+
+	scores := make(map[int]float64)
+	candidates := make([]int, 0, len(tzAudited.Landmarks[tzAudited.K-1]))
+	for nd := range tzAudited.Landmarks[tzAudited.K-1] {
+		scores[nd.Asn] = float64(asLoad[nd.Asn])
+		candidates = append(candidates, nd.Asn)
+	}
+
+	// Ties are broken by ASN, so that attacks are reproducible
+	sort.Slice(candidates, func(i, j int) bool {
+		if scores[candidates[i]] != scores[candidates[j]] {
+			return scores[candidates[i]] > scores[candidates[j]]
+		}
+		return candidates[i] < candidates[j]
+	})
+
+	return candidates, scores
+}
+
+// attackCandidates returns the links of the graph sorted by decreasing score
+// (only the links whose endpoints have more than 1 link are considered)
+func attackCandidates(audited AbstractGraph, strategy int, samples int, disconnectedNodes map[int]bool) ([][2]int, map[[2]int]float64) {
+	nodes := *audited.GetNodes()
+
+	scores := make(map[[2]int]float64)
+
+	switch strategy {
+	case PathLoadAttack, LandmarkAttack:
+		routes, _ := routePairs(audited, randomPairs(audited, samples, disconnectedNodes))
+		linkLoad, _ := pathLoads(routes)
+		for link, load := range linkLoad {
+			scores[link] = float64(load)
+		}
+
+	case BetweennessAttack:
+		sources := make([]int, 0, samples)
+		for _, p := range randomPairs(audited, samples, disconnectedNodes) {
+			sources = append(sources, p[0])
+		}
+		scores = edgeBetweenness(audited, sources)
+
+	default:
+		panic("Unknown attack strategy")
+	}
+
+	var targets map[int]bool
+	if strategy == LandmarkAttack {
+		tzAudited, isTz := audited.(*tz.Graph)
+		if !isTz {
+			panic("LandmarkAttack only works on tz.Graph")
+		}
+		targets = make(map[int]bool)
+		for nd := range tzAudited.Landmarks[tzAudited.K-1] {
+			targets[nd.Asn] = true
+		}
+	}
+
+	candidates := make([][2]int, 0, len(scores))
+	for link := range scores {
+		if len(nodes[link[0]].Links) < 2 || len(nodes[link[1]].Links) < 2 {
+			continue
+		}
+		if targets != nil && !targets[link[0]] && !targets[link[1]] {
+			continue
+		}
+		candidates = append(candidates, link)
+	}
+
+	// Ties are broken by ASN, so that attacks are reproducible
+	sort.Slice(candidates, func(i, j int) bool {
+		if scores[candidates[i]] != scores[candidates[j]] {
+			return scores[candidates[i]] > scores[candidates[j]]
+		}
+		if candidates[i][0] != candidates[j][0] {
+			return candidates[i][0] < candidates[j][0]
+		}
+		return candidates[i][1] < candidates[j][1]
+	})
+
+	return candidates, scores
+}
+
+// sampledStretch returns the average stretch and the valley rate of the audited routes
+// over 'samples' random pairs (pairs without a baseline route are ignored)
+func sampledStretch(baseline AbstractGraph, audited AbstractGraph, samples int, disconnectedNodes map[int]bool) (float64, float64) {
+	pairs := randomPairs(baseline, samples, disconnectedNodes)
+
+	baseRoutes, _ := routePairs(baseline, pairs)
+	auditRoutes, auditTypes := routePairs(audited, pairs)
+
+	var stretch float64
+	var valleys int
+	var measured int
+
+	for idx := range pairs {
+		if baseRoutes[idx] == nil || auditRoutes[idx] == nil {
+			continue
+		}
+
+		measured++
+		stretch += float64(len(auditRoutes[idx])-1) / float64(len(baseRoutes[idx])-1)
+		if !RespectsNoValley(auditTypes[idx]) {
+			valleys++
+		}
+	}
+
+	if measured == 0 {
+		return 0, 0
+	}
+
+	return stretch / float64(measured), float64(valleys) / float64(measured)
+}
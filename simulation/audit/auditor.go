@@ -4,13 +4,14 @@ import (
 	"encoding/csv"
 	"fmt"
 	"io"
-	"math"
 	"math/rand"
 	"os"
+	"sort"
 	"strings"
-	"time"
 
+	"dedis.epfl.ch/audit/stats"
 	. "dedis.epfl.ch/core"
+	"dedis.epfl.ch/shell"
 	"dedis.epfl.ch/tz"
 	"dedis.epfl.ch/u"
 )
@@ -31,6 +32,19 @@ func RandomNode(a AbstractGraph) *Node {
 	return nil
 }
 
+// sortedAsns returns the ASNs of the graph in increasing order
+// Unlike RandomNode, drawing from them picks the same ASes for the same seed
+// (e.g. when a measurement resumes from a checkpoint)
+func sortedAsns(a AbstractGraph) []int {
+	asns := make([]int, 0, len(*a.GetNodes()))
+	for asn := range *a.GetNodes() {
+		asns = append(asns, asn)
+	}
+	sort.Ints(asns)
+
+	return asns
+}
+
 // RandomLink returns a randomly chosen link
 // with the form of one of its endpoints and the index of the link to the other endpoint
 func RandomLink(a AbstractGraph, linksNum int) (*Node, int) {
@@ -51,25 +65,18 @@ func RandomLink(a AbstractGraph, linksNum int) (*Node, int) {
 	return nil, -1
 }
 
-// Check for a lax version of Gao-Rexford rules
-func respectsNoValley(routeLinks []int) bool {
-	goneDown := false
-
-	for _, ln := range routeLinks {
-		goneDown = goneDown || (ln == ToCustomer)
-
-		if goneDown && (ln == ToProvider) {
-			return false
-		}
-	}
+// stretchColumns are the columns recorded by stretchRound
+var stretchColumns = []Column{{"baseLength", IntColumn}, {"auditLength", IntColumn}, {"valley", IntColumn}, {"basePath", IntListColumn}, {"baseTypes", Int8ListColumn}, {"auditPath", IntListColumn}, {"auditTypes", Int8ListColumn}}
 
-	return true
-}
+// impactColumns are the columns recorded for each deletion (followed by traceColumns)
+var impactColumns = []Column{{"endpointA", IntColumn}, {"endpointB", IntColumn}, {"degreeA", IntColumn}, {"degreeB", IntColumn}, {"impacted", IntColumn}, {"impactMeasure", StringColumn}}
 
 type roundChannels struct {
-	stretchContribution chan float64
-	maxContribution     chan float64
-	valleyContribution  chan int
+	stretchSamples     chan *stats.Sample
+	valleyContribution chan int
+	// unroutedContribution counts the pairs routed by the baseline but not by the audited graph
+	// (e.g. with no valley-free path on a ValleyFree tz.Graph)
+	unroutedContribution chan int
 }
 
 func formatPath(path []*Node) string {
@@ -96,10 +103,13 @@ func formatTypes(types []int) string {
 	return sbType.String()
 }
 
-func stretchRound(baseline AbstractGraph, audited AbstractGraph, batches int, disconnectedNodes map[int]bool, channels roundChannels) {
+func stretchRound(recorder *Recorder, baseline AbstractGraph, audited AbstractGraph, batches int, disconnectedNodes map[int]bool, channels roundChannels) {
 	origs := make([]int, 0, batches)
 	dests := make([]int, 0, batches)
 
+	nodes := *baseline.GetNodes()
+	asns := sortedAsns(baseline)
+
 	for b := 0; b < batches; b++ {
 		// Choose endpoints (baseline.Nodes == audited.Nodes)
 
@@ -107,8 +117,8 @@ func stretchRound(baseline AbstractGraph, audited AbstractGraph, batches int, di
 		var or *Node
 		var ds *Node
 		for {
-			or = RandomNode(baseline)
-			ds = RandomNode(baseline)
+			or = nodes[asns[rand.Intn(len(asns))]]
+			ds = nodes[asns[rand.Intn(len(asns))]]
 
 			_, orDisconnected := disconnectedNodes[or.Asn]
 			_, dsDisconnected := disconnectedNodes[ds.Asn]
@@ -130,26 +140,31 @@ func stretchRound(baseline AbstractGraph, audited AbstractGraph, batches int, di
 	baseline.Evolve()
 	audited.Evolve()
 
-	var acc float64
-	var localMax float64
+	var sample stats.Sample
 	var localValley int
+	var localUnrouted int
 
 	// Get predictions
 	for b := 0; b < batches; b++ {
 		basePath, baseLinks := (baseline).GetRoute(origs[b], dests[b])
 		auditPath, auditLinks := (audited).GetRoute(origs[b], dests[b])
 
-		if basePath == nil || auditPath == nil {
+		// Pairs that the baseline cannot route are not measured
+		if len(basePath) == 0 {
+			continue
+		}
+		if len(auditPath) == 0 {
+			localUnrouted++
 			continue
 		}
 
 		withValleyFlag := 0
-		if !respectsNoValley(auditLinks) {
+		if !RespectsNoValley(auditLinks) {
 			localValley++
 			withValleyFlag = 1
 		}
 
-		record(
+		recorder.record(
 			u.Str(len(basePath)-1),
 			u.Str(len(auditPath)-1),
 			u.Str(withValleyFlag),
@@ -167,13 +182,8 @@ func stretchRound(baseline AbstractGraph, audited AbstractGraph, batches int, di
 			sampleStretch = float64(len(auditPath)-1) / float64(len(basePath)-1)
 		}
 
-		// Update localMax
-		if localMax < sampleStretch {
-			localMax = sampleStretch
-		}
-
 		// fmt.Printf("\tRoute %d	from %d to %d	obtained %d vs %d	stretch %f\n", b, origs[b], dests[b], len(auditPath)-1, len(basePath)-1, sampleStretch)
-		acc += sampleStretch
+		sample.Add(sampleStretch)
 	}
 
 	// Delete destinations
@@ -182,46 +192,56 @@ func stretchRound(baseline AbstractGraph, audited AbstractGraph, batches int, di
 		audited.DeleteDestination(d)
 	}
 
-	channels.stretchContribution <- acc
-	channels.maxContribution <- localMax
+	channels.stretchSamples <- &sample
 	channels.valleyContribution <- localValley
+	channels.unroutedContribution <- localUnrouted
 }
 
-// MeasureStretch measures the average path stretch over random paths
+// MeasureStretch measures the path stretch over random paths
 // batches : number of routes added per round
 // rounds  : number of rounds
-// return (averageStretch, maxStretch)
-func MeasureStretch(baseline AbstractGraph, audited AbstractGraph, rounds int, batches int) (float64, float64) {
+// returns the "stretch" metric, the "valleyRate" value and the "unroutedRate" value (the
+// fraction of pairs routed by the baseline for which the audited graph has no path)
+func MeasureStretch(recorder *Recorder, baseline AbstractGraph, audited AbstractGraph, rounds int, batches int) Result {
+
+	recorder.start("stretch", map[string]interface{}{"rounds": rounds, "batches": batches}, stretchColumns)
+
+	recorder.seedRandom()
 
-	rand.Seed(time.Now().UnixNano())
+	tracker := shell.StartTask("Stretch rounds", rounds)
+	defer tracker.Finish()
 
-	stretch := 0.0
-	max := 0.0
+	var stretch stats.Sample
 	valley := 0
+	unrouted := 0
 
 	channels := roundChannels{
-		stretchContribution: make(chan float64, rounds),
-		maxContribution:     make(chan float64, rounds),
-		valleyContribution:  make(chan int, rounds),
+		stretchSamples:       make(chan *stats.Sample, rounds),
+		valleyContribution:   make(chan int, rounds),
+		unroutedContribution: make(chan int, rounds),
 	}
 
 	for i := 0; i < rounds; i++ {
 		baselineCopy := baseline.Copy()
 		auditedCopy := audited.Copy()
-		go stretchRound(baselineCopy, auditedCopy, batches, map[int]bool{}, channels)
+		go stretchRound(recorder, baselineCopy, auditedCopy, batches, map[int]bool{}, channels)
 	}
 
 	for i := 0; i < rounds; i++ {
-		stretch += <-channels.stretchContribution
-		max = math.Max(max, <-channels.maxContribution)
+		stretch.Add((<-channels.stretchSamples).Values()...)
 		valley += <-channels.valleyContribution
+		unrouted += <-channels.unroutedContribution
+		tracker.Step()
 	}
 
-	fmt.Printf("%f%% of paths do not respec the no-valley rule\n", float64(valley)/float64(rounds*batches)*100)
+	result := newResult("stretch")
+	result.addSample("stretch", &stretch)
+	result.Values["valleyRate"] = float64(valley) / float64(rounds*batches)
+	result.Values["unroutedRate"] = float64(unrouted) / float64(rounds*batches)
 
-	stopRecording()
+	recorder.end(&result)
 
-	return stretch / float64(rounds*batches), max
+	return result
 }
 
 func loadEdgeDeletionsFile(filename string) [][]int {
@@ -250,69 +270,142 @@ func loadEdgeDeletionsFile(filename string) [][]int {
 	return deletionsList
 }
 
+// removeEdgeWithTrace deletes an edge, retrieving the trace of repair messages if
+// the graph supports it (only tz.Graph does)
+func removeEdgeWithTrace(audited AbstractGraph, aAsn int, bAsn int) (bool, map[int]bool, *TapeMeasure, *tz.RepairTrace) {
+	if tzGraph, isTz := audited.(*tz.Graph); isTz {
+		return tzGraph.RemoveEdgeWithTrace(aAsn, bAsn)
+	}
+
+	success, impactedArea, impactedMeasure := audited.RemoveEdge(aAsn, bAsn)
+	return success, impactedArea, impactedMeasure, nil
+}
+
+// removeNodeWithTrace removes an AS from the audited graph (with RemoveNode on tz.Graph)
+// Other graphs lose every link of the AS but the last one (that RemoveEdge refuses
+// to delete): the AS remains as a leaf, which cannot be used as transit
+func removeNodeWithTrace(audited AbstractGraph, asn int) (bool, map[int]bool, *TapeMeasure, *tz.RepairTrace) {
+	if tzGraph, isTz := audited.(*tz.Graph); isTz {
+		return tzGraph.RemoveNodeWithTrace(asn, true)
+	}
+
+	detachNode(audited, asn)
+	return true, map[int]bool{asn: true}, nil, nil
+}
+
+// detachNode deletes the links of an AS, until RemoveEdge refuses to
+func detachNode(graph AbstractGraph, asn int) {
+	nd, exists := (*graph.GetNodes())[asn]
+	if !exists {
+		return
+	}
+
+	neighbors := make([]int, len(nd.Links))
+	copy(neighbors, nd.Links)
+	for _, l := range neighbors {
+		graph.RemoveEdge(asn, l)
+	}
+}
+
+// formatMeasure returns the content of a TapeMeasure (empty if it is not available)
+func formatMeasure(measure *TapeMeasure) string {
+	if measure == nil {
+		return ""
+	}
+	return measure.String()
+}
+
+// formatTrace returns the number of invalidations, announcements and distance changes
+// in a trace (nothing if the trace is not available)
+func formatTrace(trace *tz.RepairTrace) []string {
+	if trace == nil {
+		return []string{}
+	}
+
+	return []string{
+		u.Str(trace.Count(tz.InvalidationMessage)),
+		u.Str(trace.Count(tz.AnnouncementMessage)),
+		u.Str(trace.DistanceChanges()),
+	}
+}
+
 // MeasureChosenEdgeDeletionImpact measures the number of nodes that must be updated when a sequence of edge
 // deletions is executed
+// On tz.Graph, the number of repair messages is recorded as well
 // deletionsFilename: 	 path to csv file containing the sequence of deletions
-// returns (averageImpact, maxImpact)
-func MeasureChosenEdgeDeletionImpact(audited AbstractGraph, deletionsFilename string) (float64, float64) {
+// returns the "impact" metric (and "messages" on tz.Graph)
+func MeasureChosenEdgeDeletionImpact(recorder *Recorder, audited AbstractGraph, deletionsFilename string) Result {
 
-	var averageImpact float64
-	var maxImpact float64
+	recorder.start("chosen-edge-deletion-impact", map[string]interface{}{"deletions": deletionsFilename}, append(impactColumns, traceColumns(audited)...))
+
+	var impact stats.Sample
+	var messages stats.Sample
 
 	linksNum := audited.CountLinks()
 
 	deletionsList := loadEdgeDeletionsFile(deletionsFilename)
 
-	var b int
+	tracker := shell.StartTask("Chosen deletions", len(deletionsList))
+	defer tracker.Finish()
 
 	for _, endpoints := range deletionsList {
 		// Delete link from the graph
-		success, impactedArea, impactedMeasure := audited.RemoveEdge(endpoints[0], endpoints[1])
+		success, impactedArea, impactedMeasure, trace := removeEdgeWithTrace(audited, endpoints[0], endpoints[1])
 		impactedNodes := len(impactedArea)
 		linksNum--
+		tracker.Step()
 
 		if success {
 			// Consider the sample only if it's successful
-			b++
-			averageImpact += float64(impactedNodes)
-			maxImpact = math.Max(maxImpact, float64(impactedNodes))
+			impact.Add(float64(impactedNodes))
+			if trace != nil {
+				messages.Add(float64(trace.Total()))
+			}
 
 			endA := (*audited.GetNodes())[endpoints[0]]
 			endB := (*audited.GetNodes())[endpoints[1]]
 
-			record(
+			recorder.record(append([]string{
 				u.Str(endpoints[0]),
 				u.Str(endpoints[1]),
-				u.Str(len(endA.Links)+1),
-				u.Str(len(endB.Links)+1),
+				u.Str(len(endA.Links) + 1),
+				u.Str(len(endB.Links) + 1),
 				u.Str(impactedNodes),
 				impactedMeasure.String(),
-			)
+			}, formatTrace(trace)...)...)
 		}
 	}
 
-	averageImpact /= float64(b)
+	result := newResult("chosen-edge-deletion-impact")
+	result.addSample("impact", &impact)
+	if messages.Len() > 0 {
+		result.addSample("messages", &messages)
+	}
 
-	stopRecording()
+	recorder.end(&result)
 
-	return averageImpact, maxImpact
+	return result
 }
 
 // MeasureEdgeDeletionImpact measures the number of nodes that must be updated when a random link fails
+// On tz.Graph, the number of repair messages is recorded as well (comparable to bgp messages)
 // batches: 	 number of random link deletions
-// returns (averageImpact, maxImpact)
-func MeasureEdgeDeletionImpact(baseline AbstractGraph, audited AbstractGraph, batches int) (float64, float64) {
+// returns the "impact" metric (and "messages" on tz.Graph)
+func MeasureEdgeDeletionImpact(recorder *Recorder, baseline AbstractGraph, audited AbstractGraph, batches int) Result {
 
-	rand.Seed(time.Now().UnixNano())
+	recorder.start("edge-deletion-impact", map[string]interface{}{"batches": batches}, append(impactColumns, traceColumns(audited)...))
 
-	var averageImpact float64
-	var maxImpact float64
+	recorder.seedRandom()
 
-	linksNum := audited.CountLinks()
+	tracker := shell.StartTask("Edge deletions", batches)
+	defer tracker.Finish()
+
+	var impact stats.Sample
+	var messages stats.Sample
 
-	var b int
+	linksNum := audited.CountLinks()
 
-	for b < batches {
+	for impact.Len() < batches {
 
 		// Choose a random link (from a node with more than 1 link)
 		endpoint, linkIdx := RandomLink(audited, linksNum)
@@ -323,53 +416,64 @@ func MeasureEdgeDeletionImpact(baseline AbstractGraph, audited AbstractGraph, ba
 		otherAsn := endpoint.Links[linkIdx]
 
 		// Delete link from the graph
-		success, impactedArea, impactedMeasure := audited.RemoveEdge(endpoint.Asn, otherAsn)
+		success, impactedArea, impactedMeasure, trace := removeEdgeWithTrace(audited, endpoint.Asn, otherAsn)
 		impactedNodes := len(impactedArea)
 		linksNum--
 
 		if success {
 			// Consider the sample only if it's successful
-			b++
-			averageImpact += float64(impactedNodes)
-			maxImpact = math.Max(maxImpact, float64(impactedNodes))
+			impact.Add(float64(impactedNodes))
+			if trace != nil {
+				messages.Add(float64(trace.Total()))
+			}
 
 			otherEndpoint := (*audited.GetNodes())[otherAsn]
 
-			record(
+			tracker.Step()
+
+			recorder.record(append([]string{
 				u.Str(endpoint.Asn),
 				u.Str(otherAsn),
-				u.Str(len(endpoint.Links)+1),
-				u.Str(len(otherEndpoint.Links)+1),
+				u.Str(len(endpoint.Links) + 1),
+				u.Str(len(otherEndpoint.Links) + 1),
 				u.Str(impactedNodes),
 				impactedMeasure.String(),
-			)
+			}, formatTrace(trace)...)...)
 		}
 	}
 
-	averageImpact /= float64(b)
+	result := newResult("edge-deletion-impact")
+	result.addSample("impact", &impact)
+	if messages.Len() > 0 {
+		result.addSample("messages", &messages)
+	}
 
-	stopRecording()
+	recorder.end(&result)
 
-	return averageImpact, maxImpact
+	return result
 }
 
 // MeasureDeletionStretch computes the relative increase in stretch after link deletion
 // the ONLY considered routes are the one between 2 neighboring nodes (in the original graph)
-func MeasureDeletionStretch(baselineOriginal AbstractGraph, auditedOriginal AbstractGraph, batches int) (float64, float64) {
+// returns the "stretchIncrease" metric
+func MeasureDeletionStretch(recorder *Recorder, baselineOriginal AbstractGraph, auditedOriginal AbstractGraph, batches int) Result {
 
-	rand.Seed(time.Now().UnixNano())
+	recorder.start("deletion-stretch", map[string]interface{}{"batches": batches}, []Column{{"baseLengthBefore", IntColumn}, {"basePathBefore", IntListColumn}, {"baseTypesBefore", Int8ListColumn}, {"auditLengthBefore", IntColumn}, {"auditPathBefore", IntListColumn}, {"auditTypesBefore", Int8ListColumn}, {"baseLengthAfter", IntColumn}, {"basePathAfter", IntListColumn}, {"baseTypesAfter", Int8ListColumn}, {"auditLengthAfter", IntColumn}, {"auditPathAfter", IntListColumn}, {"auditTypesAfter", Int8ListColumn}})
+
+	recorder.seedRandom()
+
+	tracker := shell.StartTask("Deletion stretch", batches)
+	defer tracker.Finish()
 
 	// Conduct measurements on a copy of the graphs
 	baseline := baselineOriginal.Copy()
 	audited := auditedOriginal.Copy()
 
-	var averageStretchIncrease float64
-	var maxStretchIncrease float64
+	var stretchIncrease stats.Sample
 
 	linksNum := audited.CountLinks()
 
-	b := 0
-	for b < batches {
+	for stretchIncrease.Len() < batches {
 
 		// Choose a random link (with endpoint with more than 1 link)
 		endpoint, linkIdx := RandomLink(audited, linksNum)
@@ -416,16 +520,17 @@ func MeasureDeletionStretch(baselineOriginal AbstractGraph, auditedOriginal Abst
 				// After the deletion, there is no path respecting GR rules in the original graph (only paths with valleys)
 				continue
 			}
+			if auditedBefore == nil || auditedAfter == nil {
+				// The audited graph has no path (e.g. no valley-free path on a ValleyFree tz.Graph)
+				continue
+			}
 
 			// Consider the sample only if it's successful
-			b++
+			stretchIncrease.Add((float64(len(auditedAfter)) / float64(len(baselineAfter))) / (float64(len(auditedBefore)) / float64(len(baselineBefore))))
 
-			sampleIncrease := (float64(len(auditedAfter)) / float64(len(baselineAfter))) / (float64(len(auditedBefore)) / float64(len(baselineBefore)))
+			tracker.Step()
 
-			averageStretchIncrease += sampleIncrease
-			maxStretchIncrease = math.Max(maxStretchIncrease, sampleIncrease)
-
-			record(
+			recorder.record(
 				u.Str(len(baselineBefore)),
 				formatPath(baselineBefore),
 				formatTypes(baselineTypesBefore),
@@ -442,7 +547,7 @@ func MeasureDeletionStretch(baselineOriginal AbstractGraph, auditedOriginal Abst
 		} else if !success && impactedNum > 0 {
 			// Game over! The graph is no more a connected component
 			// Start with fresh copies
-			fmt.Printf("Starting from fresh graphs after %d samples (detected > 1 connected component)\n", b)
+			fmt.Printf("Starting from fresh graphs after %d samples (detected > 1 connected component)\n", stretchIncrease.Len())
 			baseline = baselineOriginal.Copy()
 			audited = auditedOriginal.Copy()
 
@@ -451,29 +556,36 @@ func MeasureDeletionStretch(baselineOriginal AbstractGraph, auditedOriginal Abst
 		}
 	}
 
-	averageStretchIncrease /= float64(b)
+	result := newResult("deletion-stretch")
+	result.addSample("stretchIncrease", &stretchIncrease)
 
-	stopRecording()
+	recorder.end(&result)
 
-	return averageStretchIncrease, maxStretchIncrease
+	return result
 }
 
 // MeasureLandmarkLevelAfterDeletion stores which level of landmarks is used
 // to compute the path between to adjacent nodes, before and after the edge
 // connecting them is deleted
+// returns the "levelBefore" and "levelAfter" metrics
 // WARNING: Only works on tz.Graph
-func MeasureLandmarkLevelAfterDeletion(baselineGraph AbstractGraph, auditedGraph *tz.Graph, samples int) {
+func MeasureLandmarkLevelAfterDeletion(recorder *Recorder, baselineGraph AbstractGraph, auditedGraph *tz.Graph, samples int) Result {
+
+	recorder.start("landmark-level-after-deletion", map[string]interface{}{"samples": samples}, []Column{{"baseLengthBefore", IntColumn}, {"baseTypesBefore", Int8ListColumn}, {"levelBefore", IntColumn}, {"auditPathBefore", IntListColumn}, {"baseLengthAfter", IntColumn}, {"baseTypesAfter", Int8ListColumn}, {"levelAfter", IntColumn}, {"auditPathAfter", IntListColumn}})
+
+	tracker := shell.StartTask("Landmark levels", samples)
+	defer tracker.Finish()
 
 	baseline := baselineGraph.Copy()
 	audited := auditedGraph.CopyAsTz()
 
-	rand.Seed(time.Now().UnixNano())
+	recorder.seedRandom()
 
 	linksNum := audited.CountLinks()
 
-	var s int = 0
+	var levelsBefore, levelsAfter stats.Sample
 
-	for s < samples {
+	for levelsAfter.Len() < samples {
 
 		// Choose a random link (with endpoint with more than 1 link)
 		endpoint, linkIdx := RandomLink(audited, linksNum)
@@ -520,11 +632,18 @@ func MeasureLandmarkLevelAfterDeletion(baselineGraph AbstractGraph, auditedGraph
 				// After the deletion, there is no path respecting GR rules in the original graph (only paths with valleys)
 				continue
 			}
+			if auditedAsnBefore == nil || auditedAsnAfter == nil {
+				// No level was used (e.g. no valley-free path on a ValleyFree tz.Graph)
+				continue
+			}
 
 			// Consider the sample only if it's successful
-			s++
+			levelsBefore.Add(float64(levelBefore))
+			levelsAfter.Add(float64(levelAfter))
 
-			record(
+			tracker.Step()
+
+			recorder.record(
 				u.Str(len(baselineBefore)),
 				formatTypes(baselineTypesBefore),
 				u.Str(levelBefore),
@@ -538,7 +657,7 @@ func MeasureLandmarkLevelAfterDeletion(baselineGraph AbstractGraph, auditedGraph
 		} else if !success && impactedNum > 0 {
 			// Game over! The graph is no more a connected component
 			// Start with fresh copies
-			fmt.Printf("Starting from fresh graphs after %d samples (detected > 1 connected component)\n", s)
+			fmt.Printf("Starting from fresh graphs after %d samples (detected > 1 connected component)\n", levelsAfter.Len())
 			baseline = baselineGraph.Copy()
 			audited = auditedGraph.CopyAsTz()
 
@@ -547,17 +666,96 @@ func MeasureLandmarkLevelAfterDeletion(baselineGraph AbstractGraph, auditedGraph
 		}
 	}
 
-	stopRecording()
+	result := newResult("landmark-level-after-deletion")
+	result.addSample("levelBefore", &levelsBefore)
+	result.addSample("levelAfter", &levelsAfter)
+
+	recorder.end(&result)
+
+	return result
+}
+
+// MeasureBidirectionalStretch measures how much stretch is saved by evaluating TZ queries in
+// both directions (and, if allLandmarks is set, through every landmark shared by the bunches)
+// with respect to the original one-directional query
+// returns the "stretchSaving" metric and the "unroutedRate" value (the fraction of
+// pairs routed by the baseline but not by the audited graph)
+// WARNING: Only works on tz.Graph
+func MeasureBidirectionalStretch(recorder *Recorder, baselineGraph AbstractGraph, audited *tz.Graph, samples int, allLandmarks bool) Result {
+
+	recorder.start("bidirectional-stretch", map[string]interface{}{"samples": samples, "allLandmarks": allLandmarks}, []Column{{"baseLength", IntColumn}, {"originalLength", IntColumn}, {"bestLength", IntColumn}, {"originalLevel", IntColumn}, {"bestLevel", IntColumn}, {"basePath", IntListColumn}, {"originalPath", IntListColumn}, {"bestPath", IntListColumn}})
+
+	tracker := shell.StartTask("Bidirectional stretch", samples)
+	defer tracker.Finish()
+
+	baseline := baselineGraph.Copy()
+
+	recorder.seedRandom()
+
+	var saving stats.Sample
+	unrouted := 0
+
+	for saving.Len() < samples {
+		or := RandomNode(baseline)
+		ds := RandomNode(baseline)
+
+		if or.Asn == ds.Asn {
+			continue
+		}
+
+		baseline.SetDestinations(map[int]bool{ds.Asn: true})
+		baseline.Evolve()
+		basePath, _ := baseline.GetRoute(or.Asn, ds.Asn)
+		baseline.DeleteDestination(ds.Asn)
+
+		if basePath == nil {
+			continue
+		}
+
+		origLevel, origPath := audited.ApproximatePath(or.Asn, ds.Asn)
+		bestLevel, bestPath := audited.ApproximateBestPath(or.Asn, ds.Asn, allLandmarks)
+
+		// Pairs routed by the baseline only are counted apart (no saving can be computed)
+		if origPath == nil || bestPath == nil {
+			unrouted++
+			continue
+		}
+
+		saving.Add(float64(len(origPath)-len(bestPath)) / float64(len(basePath)-1))
+
+		tracker.Step()
+
+		recorder.record(
+			u.Str(len(basePath)-1),
+			u.Str(len(origPath)-1),
+			u.Str(len(bestPath)-1),
+			u.Str(origLevel),
+			u.Str(bestLevel),
+			formatPath(basePath),
+			formatAsnPath(origPath),
+			formatAsnPath(bestPath),
+		)
+	}
+
+	result := newResult("bidirectional-stretch")
+	result.addSample("stretchSaving", &saving)
+	if measured := saving.Len() + unrouted; measured > 0 {
+		result.Values["unroutedRate"] = float64(unrouted) / float64(measured)
+	}
+
+	recorder.end(&result)
+
+	return result
 }
 
+// deletionsRound deletes random links from both graphs (the baseline can be nil)
+// returns false if the graph was split in multiple connected components
 func deletionsRound(baseline AbstractGraph, audited AbstractGraph, round int, deletionProportion float64) bool {
 
 	linksNum := audited.CountLinks()
 
 	toDelete := int(float64(linksNum) * deletionProportion)
 
-	fmt.Printf("Starting round #%d: deleting %d links\n", round, toDelete)
-
 	for toDelete > 0 {
 		// Choose a random link
 		endpoint, linkIdx := RandomLink(audited, linksNum)
@@ -568,11 +766,20 @@ func deletionsRound(baseline AbstractGraph, audited AbstractGraph, round int, de
 			otherAsn = endpoint.Links[linkIdx]
 		}
 
-		baselineSuccess, _, _ := baseline.RemoveEdge(endpoint.Asn, otherAsn)
 		auditedSuccess, impactedArea, _ := audited.RemoveEdge(endpoint.Asn, otherAsn)
 
 		impactedNum := len(impactedArea)
 
+		if tzAudited, isTz := audited.(*tz.Graph); isTz && tzAudited.AtomicDeletions && !auditedSuccess && impactedNum == 0 {
+			// The deletion was refused since it would disconnect the graph, choose another link
+			continue
+		}
+
+		baselineSuccess := true
+		if baseline != nil {
+			baselineSuccess, _, _ = baseline.RemoveEdge(endpoint.Asn, otherAsn)
+		}
+
 		if auditedSuccess {
 			if !baselineSuccess {
 				panic("Baseline and Audited graphs out of sync")
@@ -605,10 +812,6 @@ func chosenDeletionsRound(baseline AbstractGraph, audited AbstractGraph, deletio
 		endIdx = (slot + 1) * len(deletionsList) / rounds
 	}
 
-	toDelete := endIdx - startIdx
-
-	fmt.Printf("Starting round #%d: deleting %d links\n", slot, toDelete)
-
 	disconnectedNodes := make(map[int]bool)
 
 	for startIdx < endIdx {
@@ -641,18 +844,26 @@ func chosenDeletionsRound(baseline AbstractGraph, audited AbstractGraph, deletio
 	return disconnectedNodes
 }
 
-// MeasureChosenDeletionsStretch computes the average and maximum increase in empirical stretch after having deleted
+// MeasureChosenDeletionsStretch computes the increase in empirical stretch after having deleted
 // a specific sequence of edges from the graph (distributed over 'rounds' rounds)
 // If recording is active, for each round, the lengths and shapes of measured paths are saved to file
-func MeasureChosenDeletionsStretch(baselineOriginal *AbstractGraph, auditedOriginal *AbstractGraph, rounds int, deletionsFilename string) (float64, float64) {
+// If the recorder has checkpoints enabled, the state is saved after each round of deletions
+// (and restored if the recorder resumes from a checkpoint)
+// returns the "roundStretch" metric (one sample per round) and the "stretchIncrease"
+// metric (between consecutive rounds)
+func MeasureChosenDeletionsStretch(recorder *Recorder, baselineOriginal *AbstractGraph, auditedOriginal *AbstractGraph, rounds int, deletionsFilename string) Result {
+
+	recorder.start("chosen-deletions-stretch", map[string]interface{}{"rounds": rounds, "deletions": deletionsFilename}, stretchColumns)
+
+	recorder.seedRandom()
 
 	// Conduct measurements on a copy of the graphs
 	baseline := (*baselineOriginal).Copy()
 	audited := (*auditedOriginal).Copy()
 
 	var previousStretch float64
-	var averageStretchIncrease float64
-	var maxStretchIncrease float64
+	var roundStretch stats.Sample
+	var stretchIncrease stats.Sample
 
 	deletionsList := loadEdgeDeletionsFile(deletionsFilename)
 
@@ -660,139 +871,214 @@ func MeasureChosenDeletionsStretch(baselineOriginal *AbstractGraph, auditedOrigi
 
 	disconnectedNodes := make(map[int]bool)
 
+	firstRound := 0
+	if checkpoint := recorder.resumedCheckpoint("chosen-deletions-stretch"); checkpoint != nil {
+		baseline, audited = recorder.restoreGraphs(checkpoint, baseline, audited)
+		firstRound = checkpoint.Round
+		previousStretch = checkpoint.Values["previousStretch"]
+		roundStretch = checkpoint.sample("roundStretch")
+		stretchIncrease = checkpoint.sample("stretchIncrease")
+		for _, asn := range checkpoint.Disconnected {
+			disconnectedNodes[asn] = true
+		}
+	}
+
+	tracker := shell.StartTask("Deletion rounds", rounds+1-firstRound)
+	defer tracker.Finish()
+
 	// 1 round is performed, since the round#0 is without deletions
-	for r := 0; r <= rounds; r++ {
+	for r := firstRound; r <= rounds; r++ {
+		recorder.seedRound(r)
 
-		record(
+		recorder.record(
 			u.Str(-r),
 			u.Str(-r),
 			u.Str(-r),
 		)
 
-		// Measure stretch
-		stretchChannel := roundChannels{
-			stretchContribution: make(chan float64, 1),
-			maxContribution:     make(chan float64, 1),
-			valleyContribution:  make(chan int, 1),
+		stretch := measureRoundStretch(recorder, baseline, audited, perRoundSamples, disconnectedNodes)
+		if r > 0 {
+			stretchIncrease.Add(stretch - previousStretch)
+			fmt.Printf("	Measured %f increase in round stretch\n", stretch-previousStretch)
 		}
-
-		go stretchRound(baseline, audited, perRoundSamples, disconnectedNodes, stretchChannel)
-
-		roundStretch := <-stretchChannel.stretchContribution / float64(perRoundSamples)
-		roundStretchIncrease := roundStretch - previousStretch
-		previousStretch = roundStretch
-
-		averageStretchIncrease += roundStretchIncrease
-		if roundStretchIncrease > maxStretchIncrease {
-			maxStretchIncrease = roundStretchIncrease
-		}
-
-		fmt.Printf("	Measured %f increase in round stretch\n", roundStretchIncrease)
+		roundStretch.Add(stretch)
+		previousStretch = stretch
+		tracker.Step()
 
 		if r != rounds {
 			newlyDisconnected := chosenDeletionsRound(baseline, audited, deletionsList, r, rounds)
 			disconnectedNodes = u.Union(disconnectedNodes, newlyDisconnected)
+
+			checkpoint := deletionsCheckpoint("chosen-deletions-stretch", r+1, previousStretch, &roundStretch, &stretchIncrease)
+			for asn := range disconnectedNodes {
+				checkpoint.Disconnected = append(checkpoint.Disconnected, asn)
+			}
+			recorder.checkpoint(checkpoint, baseline, audited)
 		}
 	}
 
-	averageStretchIncrease /= float64(rounds)
+	result := newResult("chosen-deletions-stretch")
+	result.addSample("roundStretch", &roundStretch)
+	result.addSample("stretchIncrease", &stretchIncrease)
 
-	stopRecording()
+	recorder.end(&result)
 
 	(*baselineOriginal) = baseline
 	(*auditedOriginal) = audited
 
-	return averageStretchIncrease, maxStretchIncrease
+	return result
+}
+
+// deletionsCheckpoint describes the state of a deletions-stretch measurement after 'round' rounds
+func deletionsCheckpoint(measurement string, round int, previousStretch float64, roundStretch *stats.Sample, stretchIncrease *stats.Sample) Checkpoint {
+	checkpoint := newCheckpoint(measurement, round)
+	checkpoint.Values["previousStretch"] = previousStretch
+	checkpoint.Samples["roundStretch"] = roundStretch.Values()
+	checkpoint.Samples["stretchIncrease"] = stretchIncrease.Values()
+	return checkpoint
+}
+
+// measureRoundStretch returns the average stretch over 'samples' random paths
+func measureRoundStretch(recorder *Recorder, baseline AbstractGraph, audited AbstractGraph, samples int, disconnectedNodes map[int]bool) float64 {
+	stretchChannel := roundChannels{
+		stretchSamples:       make(chan *stats.Sample, 1),
+		valleyContribution:   make(chan int, 1),
+		unroutedContribution: make(chan int, 1),
+	}
+
+	go stretchRound(recorder, baseline, audited, samples, disconnectedNodes, stretchChannel)
+
+	stretch := (<-stretchChannel.stretchSamples).Mean()
+	<-stretchChannel.valleyContribution
+	<-stretchChannel.unroutedContribution
+
+	return stretch
 }
 
-// MeasureRandomDeletionsStretch computes the average and maximum increase in empirical stretch after having deleted
+// MeasureRandomDeletionsStretch computes the increase in empirical stretch after having deleted
 // a fraction 'deletionProportion' of edges from the graph (without creating multiple connected components)
 // this operation is repeated ('rounds' - 1) times
+// If the audited graph is a tz.Graph with AtomicDeletions, the deletions that would
+// disconnect the graph are skipped, instead of rolling back the round
 // If recording is active, for each round, the lengths and shapes of measured paths are saved to file
-func MeasureRandomDeletionsStretch(baselineOriginal *AbstractGraph, auditedOriginal *AbstractGraph, rounds int, deletionProportion float64) (float64, float64) {
+// If the recorder has checkpoints enabled, the state is saved after each round of deletions
+// (and restored if the recorder resumes from a checkpoint)
+// returns the "roundStretch" metric (one sample per round) and the "stretchIncrease"
+// metric (between consecutive rounds)
+func MeasureRandomDeletionsStretch(recorder *Recorder, baselineOriginal *AbstractGraph, auditedOriginal *AbstractGraph, rounds int, deletionProportion float64) Result {
 
-	rand.Seed(time.Now().UnixNano())
+	recorder.start("random-deletions-stretch", map[string]interface{}{"rounds": rounds, "deletionProportion": deletionProportion}, stretchColumns)
+
+	recorder.seedRandom()
 
 	// Conduct measurements on a copy of the graphs
 	baseline := (*baselineOriginal).Copy()
 	audited := (*auditedOriginal).Copy()
 
 	var previousStretch float64
-	var averageStretchIncrease float64
-	var maxStretchIncrease float64
+	var roundStretch stats.Sample
+	var stretchIncrease stats.Sample
 
 	perRoundSamples := 1200
 
-	for r := 0; r < rounds; r++ {
+	firstRound := 0
+	if checkpoint := recorder.resumedCheckpoint("random-deletions-stretch"); checkpoint != nil {
+		baseline, audited = recorder.restoreGraphs(checkpoint, baseline, audited)
+		firstRound = checkpoint.Round
+		previousStretch = checkpoint.Values["previousStretch"]
+		roundStretch = checkpoint.sample("roundStretch")
+		stretchIncrease = checkpoint.sample("stretchIncrease")
+	}
+
+	tracker := shell.StartTask("Deletion rounds", rounds-firstRound)
+	defer tracker.Finish()
+
+	for r := firstRound; r < rounds; r++ {
+		recorder.seedRound(r)
 
 		// Mark the beginning of a round
-		record(
+		recorder.record(
 			u.Str(-r),
 			u.Str(-r),
 			u.Str(-r),
 		)
 
-		// Measure stretch
-		stretchChannel := roundChannels{
-			stretchContribution: make(chan float64, 1),
-			maxContribution:     make(chan float64, 1),
-			valleyContribution:  make(chan int, 1),
+		stretch := measureRoundStretch(recorder, baseline, audited, perRoundSamples, map[int]bool{})
+		if r > 0 {
+			stretchIncrease.Add(stretch - previousStretch)
+			fmt.Printf("	Measured %f increase in round stretch\n", stretch-previousStretch)
 		}
-
-		go stretchRound(baseline, audited, perRoundSamples, map[int]bool{}, stretchChannel)
-
-		roundStretch := <-stretchChannel.stretchContribution / float64(perRoundSamples)
-		roundStretchIncrease := roundStretch - previousStretch
-		previousStretch = roundStretch
-
-		averageStretchIncrease += roundStretchIncrease
-		if roundStretchIncrease > maxStretchIncrease {
-			maxStretchIncrease = roundStretchIncrease
-		}
-
-		fmt.Printf("	Measured %f increase in round stretch\n", roundStretchIncrease)
+		roundStretch.Add(stretch)
+		previousStretch = stretch
+		tracker.Step()
 
 		if r != rounds-1 {
-			safeBaselineCopy := baseline.Copy()
-			safeAuditedCopy := audited.Copy()
+			for {
+				baseline.Begin()
+				audited.Begin()
+
+				if deletionsRound(baseline, audited, r, deletionProportion) {
+					baseline.Commit()
+					audited.Commit()
+					break
+				}
 
-			for !deletionsRound(baseline, audited, r, deletionProportion) {
 				// Try again
-				fmt.Println("Obtained 2 connected components, retrying from safe copy ...")
-				baseline = safeBaselineCopy.Copy()
-				audited = safeAuditedCopy.Copy()
+				fmt.Println("Obtained 2 connected components, rolling back the round ...")
+				baseline.Rollback()
+				audited.Rollback()
 			}
+
+			recorder.checkpoint(deletionsCheckpoint("random-deletions-stretch", r+1, previousStretch, &roundStretch, &stretchIncrease), baseline, audited)
 		}
 	}
 
-	isRecording, logPath := GetOutputDir()
+	isRecording, logPath := recorder.Folder()
 	if isRecording {
 		GraphStructure(*audited.GetNodes()).WriteStructureToCsv(fmt.Sprintf("%smissing-edges-%dx%.3f.csv", logPath, rounds, deletionProportion))
 	}
 
-	averageStretchIncrease /= float64(rounds)
+	result := newResult("random-deletions-stretch")
+	result.addSample("roundStretch", &roundStretch)
+	result.addSample("stretchIncrease", &stretchIncrease)
 
-	stopRecording()
+	recorder.end(&result)
 
 	(*baselineOriginal) = baseline
 	(*auditedOriginal) = audited
 
-	return averageStretchIncrease, maxStretchIncrease
+	return result
 }
 
-// MeasureEndpointsDegrees returns the list of the degrees of endpoints
-// of each edge in the graph
-func MeasureEndpointsDegrees(graph AbstractGraph) {
+// MeasureEndpointsDegrees records the degrees of the endpoints of each edge in the graph
+// returns the "degree" metric (one sample per endpoint of each edge)
+func MeasureEndpointsDegrees(recorder *Recorder, graph AbstractGraph) Result {
+	recorder.start("endpoints-degrees", map[string]interface{}{}, []Column{{"degree", IntColumn}, {"neighborDegree", IntColumn}})
+
+	tracker := shell.StartTask("Endpoint degrees", 2*graph.CountLinks())
+	defer tracker.Finish()
+
 	nodes := *graph.GetNodes()
 
+	var degree stats.Sample
+
 	for _, n := range nodes {
 		for _, l := range n.Links {
-			record(
+			degree.Add(float64(len(n.Links)))
+
+			tracker.Step()
+
+			recorder.record(
 				u.Str(len(n.Links)),
 				u.Str(len(nodes[l].Links)),
 			)
 		}
 	}
 
-	stopRecording()
+	result := newResult("endpoints-degrees")
+	result.addSample("degree", &degree)
+
+	recorder.end(&result)
+
+	return result
 }
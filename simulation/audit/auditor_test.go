@@ -0,0 +1,59 @@
+package audit
+
+import (
+	"testing"
+
+	"dedis.epfl.ch/bgp"
+	. "dedis.epfl.ch/core"
+	"dedis.epfl.ch/tz"
+	"dedis.epfl.ch/u"
+)
+
+// loadTestGraphs loads data/test.csv as a BGP graph and as a tz.Graph (with the
+// landmark 3 at level 1)
+func loadTestGraphs(t *testing.T) (AbstractGraph, AbstractGraph) {
+	baseline := bgp.InitGraph()
+	if err := bgp.LoadFromCsv(&baseline, "../data/test.csv"); err != nil {
+		t.Fatal(err)
+	}
+
+	audited := tz.InitGraph()
+	audited.K = 2
+	if err := tz.LoadFromCsv(&audited, "../data/test.csv"); err != nil {
+		t.Fatal(err)
+	}
+	audited.Landmarks[0] = make(map[*Node]bool)
+	for _, nd := range audited.Nodes {
+		audited.Landmarks[0][nd] = true
+	}
+	audited.Landmarks[1] = map[*Node]bool{audited.Nodes[3]: true}
+	audited.Landmarks[2] = nil
+	audited.Preprocess()
+
+	return &baseline, &audited
+}
+
+func TestMeasureBidirectionalStretch(t *testing.T) {
+	baseline, audited := loadTestGraphs(t)
+	u.FixedSeed = 3
+	defer func() { u.FixedSeed = 0 }()
+
+	for _, allLandmarks := range []bool{false, true} {
+		sink := &MemorySink{}
+		result := MeasureBidirectionalStretch(NewRecorder(sink), baseline, audited.(*tz.Graph), 20, allLandmarks)
+
+		if saving := result.Metrics["stretchSaving"]; saving.Count != 20 || saving.Min < 0 {
+			t.Errorf("allLandmarks=%v: saving %+v", allLandmarks, saving)
+		}
+		if len(sink.Rows) != 20 {
+			t.Fatalf("allLandmarks=%v: %d rows recorded", allLandmarks, len(sink.Rows))
+		}
+
+		for _, row := range sink.Rows {
+			// TZ paths ignore policies, so they can be shorter than the BGP route
+			if originalLength, bestLength := u.Int(row[1]), u.Int(row[2]); bestLength > originalLength {
+				t.Errorf("allLandmarks=%v: row %v", allLandmarks, row)
+			}
+		}
+	}
+}
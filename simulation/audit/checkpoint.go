@@ -0,0 +1,333 @@
+package audit
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"dedis.epfl.ch/audit/stats"
+	"dedis.epfl.ch/bgp"
+	. "dedis.epfl.ch/core"
+	"dedis.epfl.ch/tz"
+	"dedis.epfl.ch/u"
+)
+
+const checkpointFile = "checkpoint.json"
+
+// Checkpoint describes the state of a round-based measurement after a completed round
+// The graphs are saved next to it, in the folder round-<Round>
+type Checkpoint struct {
+	Measurement string `json:"measurement"`
+	// Round is the number of completed rounds (the measurement resumes from this round)
+	Round int `json:"round"`
+	// Seed is the seed from which the random generator is reseeded at each round
+	Seed int64 `json:"seed"`
+	// Rows and Offsets describe the output of the recorder (one offset per sink)
+	Rows    int     `json:"rows"`
+	Offsets []int64 `json:"offsets"`
+	// Samples and Values hold the partial results of the measurement
+	Samples map[string][]float64 `json:"samples"`
+	Values  map[string]float64   `json:"values"`
+	// Disconnected lists the ASes that are no longer considered by the measurement
+	Disconnected []int     `json:"disconnected"`
+	Saved        time.Time `json:"saved"`
+}
+
+// newCheckpoint returns an empty checkpoint for a measurement
+func newCheckpoint(measurement string, round int) Checkpoint {
+	return Checkpoint{
+		Measurement:  measurement,
+		Round:        round,
+		Samples:      make(map[string][]float64),
+		Values:       make(map[string]float64),
+		Disconnected: []int{},
+	}
+}
+
+// sample returns the partial sample of a metric
+func (c *Checkpoint) sample(metric string) stats.Sample {
+	var sample stats.Sample
+	for _, v := range c.Samples[metric] {
+		sample.Add(v)
+	}
+	return sample
+}
+
+// LoadCheckpoint reads the checkpoint saved in a folder
+// the boolean is false if there is no checkpoint
+func LoadCheckpoint(folder string) (bool, Checkpoint) {
+	checkpoint := Checkpoint{}
+
+	checkpointJSON, err := os.Open(folder + pathSeparator + checkpointFile)
+	if os.IsNotExist(err) {
+		return false, checkpoint
+	} else if err != nil {
+		panic("Unable to open the checkpoint in " + folder)
+	}
+	defer checkpointJSON.Close()
+
+	if err := json.NewDecoder(checkpointJSON).Decode(&checkpoint); err != nil {
+		panic("Invalid checkpoint in " + folder + ": " + err.Error())
+	}
+
+	return true, checkpoint
+}
+
+// EnableCheckpoints makes round-based measurements save a checkpoint in 'folder' after each round
+// (the checkpoint is deleted when the measurement ends)
+// Unless the recorder resumes from a checkpoint (see Resume), the previous checkpoint is deleted
+func (r *Recorder) EnableCheckpoints(folder string) {
+	if r == nil {
+		return
+	}
+
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		panic("Unable to create the checkpoints folder " + folder)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.checkpoints = folder
+
+	if r.resumed == nil {
+		r.clearCheckpoint()
+	}
+}
+
+// clearCheckpoint deletes the checkpoint (if any) of the checkpoints folder
+func (r *Recorder) clearCheckpoint() {
+	if r.checkpoints == "" {
+		return
+	}
+
+	if hasPrevious, previous := LoadCheckpoint(r.checkpoints); hasPrevious {
+		os.Remove(r.checkpoints + pathSeparator + checkpointFile)
+		os.RemoveAll(r.roundFolder(previous.Round))
+	}
+}
+
+// Resume makes the next measurement continue from a checkpoint
+// The sinks must have been opened at the offsets of the checkpoint (e.g. by ResumeCSVSink)
+func (r *Recorder) Resume(checkpoint Checkpoint) {
+	if r == nil {
+		panic("Cannot resume a measurement without output")
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if len(checkpoint.Offsets) != len(r.sinks) {
+		panic("The checkpoint does not match the sinks of the recorder")
+	}
+
+	r.resumed = &checkpoint
+	r.manifest.Rows = checkpoint.Rows
+}
+
+// resumedCheckpoint returns the checkpoint from which the measurement resumes (nil if it starts afresh)
+func (r *Recorder) resumedCheckpoint(measurement string) *Checkpoint {
+	if r == nil || r.resumed == nil {
+		return nil
+	}
+
+	if r.resumed.Measurement != measurement {
+		panic("The checkpoint belongs to " + r.resumed.Measurement + ", not to " + measurement)
+	}
+
+	return r.resumed
+}
+
+// roundFolder returns the folder where the graphs of a checkpoint are saved
+func (r *Recorder) roundFolder(round int) string {
+	return r.checkpoints + pathSeparator + "round-" + u.Str(round)
+}
+
+// restoreGraphs loads the graphs saved with the checkpoint
+// 'baseline' and 'audited' are only used to know which kind of graphs to load
+func (r *Recorder) restoreGraphs(checkpoint *Checkpoint, baseline AbstractGraph, audited AbstractGraph) (AbstractGraph, AbstractGraph) {
+	folder := r.roundFolder(checkpoint.Round)
+	fmt.Printf("Resuming %s from round #%d (%s)\n", checkpoint.Measurement, checkpoint.Round, folder)
+
+	return restoreGraph(folder+pathSeparator+"baseline", baseline), restoreGraph(folder+pathSeparator+"audited", audited)
+}
+
+// checkpoint saves the graphs and the state of the measurement (if checkpoints are enabled)
+// The previous checkpoint is deleted once the new one is complete
+func (r *Recorder) checkpoint(checkpoint Checkpoint, baseline AbstractGraph, audited AbstractGraph) {
+	if r == nil || r.checkpoints == "" {
+		return
+	}
+
+	folder := r.roundFolder(checkpoint.Round)
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		panic("Unable to create the checkpoint folder " + folder)
+	}
+
+	saveGraph(folder+pathSeparator+"baseline", baseline)
+	saveGraph(folder+pathSeparator+"audited", audited)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	checkpoint.Seed = r.manifest.Seed
+	checkpoint.Rows = r.manifest.Rows
+	checkpoint.Offsets = make([]int64, len(r.sinks))
+	for idx, s := range r.sinks {
+		if resumable, isResumable := s.(ResumableSink); isResumable {
+			checkpoint.Offsets[idx] = resumable.Offset()
+		} else {
+			checkpoint.Offsets[idx] = -1
+		}
+	}
+	checkpoint.Saved = time.Now()
+
+	// Replace the checkpoint atomically, then delete the graphs of the previous one
+	hasPrevious, previous := LoadCheckpoint(r.checkpoints)
+
+	temporary := r.checkpoints + pathSeparator + checkpointFile + ".tmp"
+	if err := writeJSON(temporary, checkpoint); err != nil {
+		panic("Unable to save the checkpoint: " + err.Error())
+	}
+	if err := os.Rename(temporary, r.checkpoints+pathSeparator+checkpointFile); err != nil {
+		panic("Unable to save the checkpoint: " + err.Error())
+	}
+
+	if hasPrevious && previous.Round != checkpoint.Round {
+		os.RemoveAll(r.roundFolder(previous.Round))
+	}
+
+	fmt.Printf("Saved checkpoint after round #%d\n", checkpoint.Round)
+}
+
+// saveGraph writes the ASes and the links of the graph (and its structures, for a tz.Graph)
+// to files starting with prefix
+// The routes learned by BGP speakers are not saved: they are learned again when needed
+func saveGraph(prefix string, graph AbstractGraph) {
+	GraphStructure(*graph.GetNodes()).WriteStructureToCsv(prefix + ".csv")
+	writeNodes(prefix+"-nodes.csv", *graph.GetNodes())
+
+	if tzGraph, isTz := graph.(*tz.Graph); isTz {
+		tz.WriteLandmarksToCsv(prefix+"-landmarks.csv", &tzGraph.Landmarks)
+		tz.WriteWitnessesToCsv(prefix+"-witnesses.csv", &tzGraph.Witnesses)
+		tz.WriteToCsv(prefix+"-bunches.csv", &map[int]Serializable{0: &tzGraph.Bunches})
+		writeComponents(prefix+"-components.csv", tzGraph.Components)
+	}
+}
+
+// restoreGraph loads a graph saved by saveGraph, of the same kind (and with the same settings) as 'like'
+func restoreGraph(prefix string, like AbstractGraph) AbstractGraph {
+	switch original := like.(type) {
+	case *bgp.Graph:
+		graph := bgp.InitGraph()
+		if err := bgp.LoadFromCsv(&graph, prefix+".csv"); err != nil {
+			panic("Unable to restore the graph " + prefix + ": " + err.Error())
+		}
+		// ASes without links do not appear among the links
+		for _, asn := range loadNodes(prefix + "-nodes.csv") {
+			graph.AddNode(asn)
+		}
+		return &graph
+
+	case *tz.Graph:
+		graph := tz.InitGraph()
+		graph.K = original.K
+		graph.ValleyFree = original.ValleyFree
+		graph.AtomicDeletions = original.AtomicDeletions
+
+		if err := tz.LoadFromCsv(&graph, prefix+".csv"); err != nil {
+			panic("Unable to restore the graph " + prefix + ": " + err.Error())
+		}
+		for _, asn := range loadNodes(prefix + "-nodes.csv") {
+			if _, exists := graph.Nodes[asn]; !exists {
+				tempNode := ToNode(asn, Link{}, Rel{})
+				graph.Nodes[asn] = &tempNode
+			}
+		}
+		graph.LoadLandmarksFromCsv(prefix + "-landmarks.csv")
+		graph.LoadWitnessesFromCsv(prefix + "-witnesses.csv")
+		graph.LoadBunchesFromCsv(prefix + "-bunches.csv")
+		graph.Components = loadComponents(prefix + "-components.csv")
+		return &graph
+
+	default:
+		panic("Checkpoints are not supported for this kind of graph")
+	}
+}
+
+func writeNodes(filename string, nodes map[int]*Node) {
+	csvFile, err := os.Create(filename)
+	if err != nil {
+		panic("Unable to create " + filename)
+	}
+	defer csvFile.Close()
+
+	writer := csv.NewWriter(csvFile)
+	for asn := range nodes {
+		writer.Write([]string{u.Str(asn)})
+	}
+	writer.Flush()
+}
+
+func loadNodes(filename string) []int {
+	csvFile, err := os.Open(filename)
+	if err != nil {
+		panic("Unable to open " + filename)
+	}
+	defer csvFile.Close()
+
+	asns := make([]int, 0)
+	reader := csv.NewReader(csvFile)
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			panic("Unable to read " + filename + ": " + err.Error())
+		}
+		asns = append(asns, u.Int(row[0]))
+	}
+
+	return asns
+}
+
+func writeComponents(filename string, components map[int]int) {
+	csvFile, err := os.Create(filename)
+	if err != nil {
+		panic("Unable to create " + filename)
+	}
+	defer csvFile.Close()
+
+	writer := csv.NewWriter(csvFile)
+	for asn, component := range components {
+		writer.Write([]string{u.Str(asn), u.Str(component)})
+	}
+	writer.Flush()
+}
+
+func loadComponents(filename string) map[int]int {
+	csvFile, err := os.Open(filename)
+	if err != nil {
+		panic("Unable to open " + filename)
+	}
+	defer csvFile.Close()
+
+	components := make(map[int]int)
+	reader := csv.NewReader(csvFile)
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			panic("Unable to read " + filename + ": " + err.Error())
+		}
+		components[u.Int(row[0])] = u.Int(row[1])
+	}
+
+	return components
+}
@@ -0,0 +1,154 @@
+package audit
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"dedis.epfl.ch/bgp"
+	. "dedis.epfl.ch/core"
+	"dedis.epfl.ch/tz"
+	"dedis.epfl.ch/u"
+)
+
+// graphState describes the links of every AS (and the structures of a tz.Graph)
+func graphState(graph AbstractGraph) string {
+	nodes := *graph.GetNodes()
+	asns := make([]int, 0, len(nodes))
+	for asn := range nodes {
+		asns = append(asns, asn)
+	}
+	sort.Ints(asns)
+
+	var state strings.Builder
+	for _, asn := range asns {
+		fmt.Fprintf(&state, "%d: %v %v\n", asn, nodes[asn].Links, nodes[asn].Type)
+	}
+
+	if tzGraph, isTz := graph.(*tz.Graph); isTz {
+		for level := 0; level < tzGraph.K; level++ {
+			landmarks := make([]int, 0, len(tzGraph.Landmarks[level]))
+			for nd := range tzGraph.Landmarks[level] {
+				landmarks = append(landmarks, nd.Asn)
+			}
+			sort.Ints(landmarks)
+			fmt.Fprintf(&state, "landmarks %d: %v\nwitnesses %d: %v\n", level, landmarks, level, *tzGraph.Witnesses[level])
+		}
+		fmt.Fprintf(&state, "bunches: %v\ncomponents: %v\n", tzGraph.Bunches, tzGraph.Components)
+	}
+
+	return state.String()
+}
+
+func tempFolder(t *testing.T) string {
+	folder, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return folder
+}
+
+func TestCheckpointRestoresGraphs(t *testing.T) {
+	folder := tempFolder(t)
+	defer os.RemoveAll(folder)
+
+	baseline, audited := loadTestGraphs(t)
+	// ASes without links are restored as well
+	baseline.(*bgp.Graph).AddNode(8)
+	isolated := ToNode(8, Link{}, Rel{})
+	audited.(*tz.Graph).Nodes[8] = &isolated
+
+	for name, graph := range map[string]AbstractGraph{"baseline": baseline, "audited": audited} {
+		saveGraph(folder+pathSeparator+name, graph)
+		if restored := restoreGraph(folder+pathSeparator+name, graph); graphState(restored) != graphState(graph) {
+			t.Errorf("%s restored as\n%s\ninstead of\n%s", name, graphState(restored), graphState(graph))
+		}
+	}
+}
+
+// crashSink panics when it receives the marker of a round, like an interrupted measurement
+type crashSink struct {
+	*CSVSink
+	round int
+}
+
+func (s *crashSink) Write(row []string) {
+	if row[0] == u.Str(-s.round) {
+		panic("crash")
+	}
+	s.CSVSink.Write(row)
+}
+
+func TestResumedMeasurementMatchesUninterrupted(t *testing.T) {
+	defer func(seed int64) { u.FixedSeed = seed }(u.FixedSeed)
+	u.FixedSeed = 7
+
+	folder := tempFolder(t)
+	defer os.RemoveAll(folder)
+
+	// Uninterrupted
+	baseline, audited := loadTestGraphs(t)
+	recorder := NewRecorder(NewCSVSink(folder + "/uninterrupted.csv"))
+	expected := MeasureChosenDeletionsStretch(recorder, &baseline, &audited, 2, "../data/test-deletions.csv")
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Interrupted at the beginning of the last round (after the checkpoint of the deletions)
+	checkpoints := folder + "/checkpoints"
+	interruptedBaseline, interruptedAudited := loadTestGraphs(t)
+	recorder = NewRecorder(&crashSink{CSVSink: NewCSVSink(folder + "/resumed.csv"), round: 2})
+	recorder.EnableCheckpoints(checkpoints)
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("the measurement was not interrupted")
+			}
+		}()
+		MeasureChosenDeletionsStretch(recorder, &interruptedBaseline, &interruptedAudited, 2, "../data/test-deletions.csv")
+	}()
+
+	hasCheckpoint, checkpoint := LoadCheckpoint(checkpoints)
+	if !hasCheckpoint || checkpoint.Round != 2 {
+		t.Fatalf("checkpoint after the crash: %v %+v", hasCheckpoint, checkpoint)
+	}
+
+	uninterrupted, err := ioutil.ReadFile(folder + "/uninterrupted.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset := checkpoint.Offsets[0]; offset < 0 || offset > int64(len(uninterrupted)) || !strings.HasPrefix(string(uninterrupted[offset:]), "-2,-2,-2\n") {
+		t.Errorf("the offset %d of the checkpoint is not the beginning of the last round", offset)
+	}
+
+	// Resumed
+	resumedBaseline, resumedAudited := loadTestGraphs(t)
+	recorder = NewRecorder(ResumeCSVSink(folder+"/resumed.csv", checkpoint.Offsets[0]))
+	recorder.Resume(checkpoint)
+	recorder.EnableCheckpoints(checkpoints)
+	result := MeasureChosenDeletionsStretch(recorder, &resumedBaseline, &resumedAudited, 2, "../data/test-deletions.csv")
+	if err := recorder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if graphState(resumedBaseline) != graphState(baseline) || graphState(resumedAudited) != graphState(audited) {
+		t.Errorf("resumed graphs\n%s%s\ninstead of\n%s%s", graphState(resumedBaseline), graphState(resumedAudited), graphState(baseline), graphState(audited))
+	}
+	if fmt.Sprint(result.Metrics) != fmt.Sprint(expected.Metrics) {
+		t.Errorf("resumed metrics %v instead of %v", result.Metrics, expected.Metrics)
+	}
+
+	resumed, err := ioutil.ReadFile(folder + "/resumed.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resumed) != string(uninterrupted) {
+		t.Errorf("resumed output\n%s\ninstead of\n%s", resumed, uninterrupted)
+	}
+	if hasCheckpoint, _ := LoadCheckpoint(checkpoints); hasCheckpoint {
+		t.Error("the checkpoint was not deleted at the end of the measurement")
+	}
+}
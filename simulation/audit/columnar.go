@@ -0,0 +1,293 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ColumnType is the type of the values of a column in columnar output
+type ColumnType string
+
+const (
+	// IntColumn holds little-endian int64 values
+	IntColumn ColumnType = "int64"
+	// FloatColumn holds little-endian float64 values
+	FloatColumn ColumnType = "float64"
+	// StringColumn holds utf-8 bytes, delimited by the offsets
+	StringColumn ColumnType = "string"
+	// IntListColumn holds int64 values (e.g. the ASes of a path), delimited by the offsets
+	IntListColumn ColumnType = "list<int64>"
+	// Int8ListColumn holds int8 values (e.g. the types of the links of a path), delimited by the offsets
+	Int8ListColumn ColumnType = "list<int8>"
+)
+
+// elementSize returns the size in bytes of the values of a column type
+func elementSize(columnType ColumnType) int64 {
+	switch columnType {
+	case IntColumn, FloatColumn, IntListColumn:
+		return 8
+	default:
+		return 1
+	}
+}
+
+// Schema describes the files of a columnar output
+type Schema struct {
+	Rows    int             `json:"rows"`
+	Columns []*ColumnSchema `json:"columns"`
+}
+
+// ColumnSchema describes the files of a column:
+// - Values: the values, concatenated
+// - Offsets: for strings and lists, Rows+1 int64 positions in Values (counted in values, not bytes)
+// - Validity: one byte per row, 0 if the row had no value for the column
+type ColumnSchema struct {
+	Name     string     `json:"name"`
+	Type     ColumnType `json:"type"`
+	Values   string     `json:"values"`
+	Offsets  string     `json:"offsets,omitempty"`
+	Validity string     `json:"validity"`
+	Nulls    int        `json:"nulls"`
+
+	files    []*os.File
+	values   *bufio.Writer
+	offsets  *bufio.Writer
+	validity *bufio.Writer
+	offset   int64
+}
+
+// ColumnarSink writes each column to its own binary files in a folder, described by
+// schema.json; the manifest and the result are saved next to the folder
+type ColumnarSink struct {
+	folder  string
+	schema  Schema
+	resumed bool
+}
+
+// NewColumnarSink creates the folder of the columns
+func NewColumnarSink(folder string) *ColumnarSink {
+	folder = strings.TrimSuffix(folder, pathSeparator)
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		panic("Could not create the output folder for the auditor")
+	}
+
+	return &ColumnarSink{folder: folder, schema: Schema{Columns: []*ColumnSchema{}}}
+}
+
+// ResumeColumnarSink opens the columns in the folder, keeping their first 'rows' rows
+// (as returned by Offset)
+func ResumeColumnarSink(folder string, rows int64) *ColumnarSink {
+	s := NewColumnarSink(folder)
+	s.schema.Rows = int(rows)
+	s.resumed = true
+	return s
+}
+
+// Filename returns the path of the folder
+func (s *ColumnarSink) Filename() string {
+	return s.folder
+}
+
+// Header implements Sink
+func (s *ColumnarSink) Header(columns []Column) {
+	for _, c := range columns {
+		column := &ColumnSchema{
+			Name:     c.Name,
+			Type:     c.Type,
+			Values:   c.Name + ".values",
+			Validity: c.Name + ".validity",
+		}
+		switch c.Type {
+		case IntColumn, FloatColumn:
+		case StringColumn, IntListColumn, Int8ListColumn:
+			column.Offsets = c.Name + ".offsets"
+		default:
+			panic("Unknown type '" + string(c.Type) + "' of column " + c.Name)
+		}
+
+		if s.resumed {
+			s.reopen(column)
+		} else {
+			column.values = s.open(column, column.Values, 0)
+			column.validity = s.open(column, column.Validity, 0)
+			if column.Offsets != "" {
+				column.offsets = s.open(column, column.Offsets, 0)
+				writeBinary(column.offsets, int64(0))
+			}
+		}
+
+		s.schema.Columns = append(s.schema.Columns, column)
+	}
+}
+
+// reopen truncates the files of a column after the rows kept by ResumeColumnarSink
+func (s *ColumnarSink) reopen(column *ColumnSchema) {
+	rows := int64(s.schema.Rows)
+
+	validity, err := ioutil.ReadFile(s.folder + pathSeparator + column.Validity)
+	if err != nil || int64(len(validity)) < rows {
+		panic("Could not resume the column " + column.Name + " of the columnar output")
+	}
+	column.Nulls = bytes.Count(validity[:rows], []byte{0})
+
+	valuesSize := rows * elementSize(column.Type)
+	if column.Offsets != "" {
+		offsets, err := ioutil.ReadFile(s.folder + pathSeparator + column.Offsets)
+		if err != nil || int64(len(offsets)) < (rows+1)*8 {
+			panic("Could not resume the column " + column.Name + " of the columnar output")
+		}
+		column.offset = int64(binary.LittleEndian.Uint64(offsets[rows*8:]))
+		valuesSize = column.offset * elementSize(column.Type)
+
+		column.offsets = s.open(column, column.Offsets, (rows+1)*8)
+	}
+
+	column.values = s.open(column, column.Values, valuesSize)
+	column.validity = s.open(column, column.Validity, rows)
+}
+
+// open returns a writer appending to a file of the column, truncated after 'size' bytes
+func (s *ColumnarSink) open(column *ColumnSchema, filename string, size int64) *bufio.Writer {
+	path := s.folder + pathSeparator + filename
+
+	var file *os.File
+	if size == 0 {
+		var err error
+		if file, err = os.Create(path); err != nil {
+			panic("Could not create the file " + filename + " of the columnar output")
+		}
+	} else {
+		file = openAt(path, size)
+	}
+
+	column.files = append(column.files, file)
+	return bufio.NewWriter(file)
+}
+
+// Write implements Sink
+// Columns without a value in the row (or with a malformed value) are marked as invalid
+func (s *ColumnarSink) Write(row []string) {
+	if len(row) > len(s.schema.Columns) {
+		panic("The row has more values than the columnar output has columns")
+	}
+
+	for idx, column := range s.schema.Columns {
+		if idx < len(row) {
+			column.write(row[idx])
+		} else {
+			column.skip()
+		}
+	}
+
+	s.schema.Rows++
+}
+
+// write parses the formatted value and appends it to the files of the column
+// A value that cannot be parsed is appended as missing (see skip), so that a
+// malformed value does not interrupt the measurement
+func (c *ColumnSchema) write(value string) {
+	switch c.Type {
+	case IntColumn:
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			c.skip()
+			return
+		}
+		writeBinary(c.values, parsed)
+	case FloatColumn:
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			c.skip()
+			return
+		}
+		writeBinary(c.values, parsed)
+	case StringColumn:
+		c.values.WriteString(value)
+		c.offset += int64(len(value))
+	case IntListColumn, Int8ListColumn:
+		// Lists are formatted as "1>2>3>" by formatPath and formatTypes
+		elements := make([]int64, 0, strings.Count(value, ">")+1)
+		for _, element := range strings.Split(value, ">") {
+			if element == "" {
+				continue
+			}
+			parsed, err := strconv.ParseInt(element, 10, 64)
+			if err != nil || (c.Type == Int8ListColumn && int64(int8(parsed)) != parsed) {
+				c.skip()
+				return
+			}
+			elements = append(elements, parsed)
+		}
+		for _, element := range elements {
+			if c.Type == IntListColumn {
+				writeBinary(c.values, element)
+			} else {
+				writeBinary(c.values, int8(element))
+			}
+			c.offset++
+		}
+	}
+
+	if c.offsets != nil {
+		writeBinary(c.offsets, c.offset)
+	}
+	c.validity.WriteByte(1)
+}
+
+// skip appends a missing value (zero, or an empty list) to the files of the column
+func (c *ColumnSchema) skip() {
+	switch c.Type {
+	case IntColumn:
+		writeBinary(c.values, int64(0))
+	case FloatColumn:
+		writeBinary(c.values, float64(0))
+	}
+
+	if c.offsets != nil {
+		writeBinary(c.offsets, c.offset)
+	}
+	c.validity.WriteByte(0)
+	c.Nulls++
+}
+
+// Offset implements ResumableSink (the offset is the number of rows)
+func (s *ColumnarSink) Offset() int64 {
+	s.flush()
+	return int64(s.schema.Rows)
+}
+
+func (s *ColumnarSink) flush() {
+	for _, column := range s.schema.Columns {
+		for _, w := range []*bufio.Writer{column.values, column.offsets, column.validity} {
+			if w != nil {
+				w.Flush()
+			}
+		}
+	}
+}
+
+// Close implements Sink
+func (s *ColumnarSink) Close(manifest Manifest, result *Result) error {
+	s.flush()
+	for _, column := range s.schema.Columns {
+		for _, f := range column.files {
+			f.Close()
+		}
+	}
+
+	if err := writeJSON(s.folder+pathSeparator+"schema.json", s.schema); err != nil {
+		return err
+	}
+	return writeSidecars(strings.TrimSuffix(s.folder, ".columns"), s.folder, manifest, result)
+}
+
+func writeBinary(w *bufio.Writer, value interface{}) {
+	if err := binary.Write(w, binary.LittleEndian, value); err != nil {
+		panic("Unable to write to the columnar output: " + err.Error())
+	}
+}
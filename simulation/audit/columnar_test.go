@@ -0,0 +1,129 @@
+package audit
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// readColumns reads a columnar output back, according to its schema.json
+// Each value is formatted with %v, and missing values are nil
+func readColumns(t *testing.T, folder string) (Schema, map[string][]interface{}) {
+	var schema Schema
+	content, err := ioutil.ReadFile(folder + "/schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(content, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(filename string) []byte {
+		content, err := ioutil.ReadFile(folder + "/" + filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return content
+	}
+
+	columns := make(map[string][]interface{})
+	for _, column := range schema.Columns {
+		values, validity := read(column.Values), read(column.Validity)
+		if len(validity) != schema.Rows {
+			t.Fatalf("%d validity bytes in column %s, for %d rows", len(validity), column.Name, schema.Rows)
+		}
+
+		var offsets []byte
+		if column.Offsets != "" {
+			offsets = read(column.Offsets)
+			if len(offsets) != (schema.Rows+1)*8 {
+				t.Fatalf("%d offset bytes in column %s, for %d rows", len(offsets), column.Name, schema.Rows)
+			}
+		}
+		offset := func(row int) int {
+			return int(binary.LittleEndian.Uint64(offsets[row*8:]))
+		}
+
+		nulls := 0
+		for row := 0; row < schema.Rows; row++ {
+			var value interface{}
+			switch column.Type {
+			case IntColumn:
+				value = int64(binary.LittleEndian.Uint64(values[row*8:]))
+			case FloatColumn:
+				value = math.Float64frombits(binary.LittleEndian.Uint64(values[row*8:]))
+			case StringColumn:
+				value = string(values[offset(row):offset(row+1)])
+			case IntListColumn:
+				list := []int64{}
+				for idx := offset(row); idx < offset(row+1); idx++ {
+					list = append(list, int64(binary.LittleEndian.Uint64(values[idx*8:])))
+				}
+				value = list
+			case Int8ListColumn:
+				list := []int8{}
+				for idx := offset(row); idx < offset(row+1); idx++ {
+					list = append(list, int8(values[idx]))
+				}
+				value = list
+			}
+
+			if validity[row] == 0 {
+				value = nil
+				nulls++
+			}
+			columns[column.Name] = append(columns[column.Name], value)
+		}
+
+		if nulls != column.Nulls {
+			t.Errorf("column %s has %d nulls, %d in the schema", column.Name, nulls, column.Nulls)
+		}
+	}
+
+	return schema, columns
+}
+
+func TestColumnarSinkMatchesSchema(t *testing.T) {
+	folder, err := ioutil.TempDir("", "columnar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	sink := NewColumnarSink(folder + "/output.columns")
+	sink.Header([]Column{{"asn", IntColumn}, {"stretch", FloatColumn}, {"label", StringColumn}, {"path", IntListColumn}, {"types", Int8ListColumn}})
+
+	sink.Write([]string{"1", "0.5", "first", "1>2>3>", "1>-1>"})
+	// Malformed values are written as missing
+	sink.Write([]string{"x", "1.5", "", "4>", "0>"})
+	sink.Write([]string{"3", "wrong", "third", "1>y>", "300>"})
+	// Short rows miss their last values
+	sink.Write([]string{"4", "2"})
+
+	if err := sink.Close(Manifest{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	schema, columns := readColumns(t, folder+"/output.columns")
+	if schema.Rows != 4 || len(schema.Columns) != 5 {
+		t.Fatalf("schema %+v", schema)
+	}
+
+	expected := map[string][]interface{}{
+		"asn":     {int64(1), nil, int64(3), int64(4)},
+		"stretch": {0.5, 1.5, nil, 2.0},
+		"label":   {"first", "", "third", nil},
+		"path":    {[]int64{1, 2, 3}, []int64{4}, nil, nil},
+		"types":   {[]int8{1, -1}, []int8{0}, nil, nil},
+	}
+	for name, values := range expected {
+		if !reflect.DeepEqual(columns[name], values) {
+			t.Errorf("column %s read as %s, expected %s", name, fmt.Sprint(columns[name]), fmt.Sprint(values))
+		}
+	}
+}
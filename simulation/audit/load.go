@@ -0,0 +1,362 @@
+package audit
+
+import (
+	"sort"
+
+	"dedis.epfl.ch/audit/stats"
+	. "dedis.epfl.ch/core"
+	"dedis.epfl.ch/shell"
+	"dedis.epfl.ch/tz"
+	"dedis.epfl.ch/u"
+)
+
+// linkKey identifies an undirected link by its endpoints
+func linkKey(a int, b int) [2]int {
+	if a < b {
+		return [2]int{a, b}
+	}
+	return [2]int{b, a}
+}
+
+// randomPairs returns n pairs of distinct ASes (none of them in 'excluded')
+func randomPairs(graph AbstractGraph, n int, excluded map[int]bool) [][2]int {
+	pairs := make([][2]int, 0, n)
+
+	for len(pairs) < n {
+		or := RandomNode(graph)
+		ds := RandomNode(graph)
+
+		if or.Asn != ds.Asn && !excluded[or.Asn] && !excluded[ds.Asn] {
+			pairs = append(pairs, [2]int{or.Asn, ds.Asn})
+		}
+	}
+
+	return pairs
+}
+
+// routePairs returns the route (and the types of links) of every pair obtained with GetRoute
+// Routes are nil if the destination cannot be reached
+func routePairs(graph AbstractGraph, pairs [][2]int) ([][]*Node, [][]int) {
+	destinations := make(map[int]bool)
+	for _, p := range pairs {
+		destinations[p[1]] = true
+	}
+
+	// Only destinations must be declared
+	graph.SetDestinations(destinations)
+	graph.Evolve()
+
+	tracker := shell.StartTask("Routes", len(pairs))
+	defer tracker.Finish()
+
+	routes := make([][]*Node, len(pairs))
+	types := make([][]int, len(pairs))
+	for idx, p := range pairs {
+		routes[idx], types[idx] = graph.GetRoute(p[0], p[1])
+		tracker.Step()
+	}
+
+	for d := range destinations {
+		graph.DeleteDestination(d)
+	}
+
+	return routes, types
+}
+
+// pathLoads counts how many routes traverse each link and each AS (as transit only)
+func pathLoads(routes [][]*Node) (map[[2]int]int, map[int]int) {
+	linkLoad := make(map[[2]int]int)
+	asLoad := make(map[int]int)
+
+	addPathLoads(routes, linkLoad, asLoad)
+
+	return linkLoad, asLoad
+}
+
+// addPathLoads adds the traversals of the routes to the loads of links and ASes
+// returns the number of routes (i.e. the pairs that could be routed)
+func addPathLoads(routes [][]*Node, linkLoad map[[2]int]int, asLoad map[int]int) int {
+	routed := 0
+
+	for _, route := range routes {
+		if route != nil {
+			routed++
+		}
+		for idx, nd := range route {
+			if idx > 0 {
+				linkLoad[linkKey(route[idx-1].Asn, nd.Asn)]++
+			}
+			if idx > 0 && idx < len(route)-1 {
+				asLoad[nd.Asn]++
+			}
+		}
+	}
+
+	return routed
+}
+
+// edgeBetweenness computes the betweenness of every link over the shortest paths
+// (ignoring policies) from the 'sources' ASes, using Brandes' algorithm
+func edgeBetweenness(graph AbstractGraph, sources []int) map[[2]int]float64 {
+	nodes := *graph.GetNodes()
+
+	betweenness := make(map[[2]int]float64)
+
+	for _, s := range sources {
+		// Breadth-first search, counting the shortest paths
+		distance := map[int]int{s: 0}
+		paths := map[int]float64{s: 1}
+		order := []int{s}
+
+		for cursor := 0; cursor < len(order); cursor++ {
+			asn := order[cursor]
+			for _, l := range nodes[asn].Links {
+				if _, visited := distance[l]; !visited {
+					distance[l] = distance[asn] + 1
+					order = append(order, l)
+				}
+				if distance[l] == distance[asn]+1 {
+					paths[l] += paths[asn]
+				}
+			}
+		}
+
+		// Accumulate dependencies from the farthest nodes
+		dependency := make(map[int]float64)
+		for cursor := len(order) - 1; cursor > 0; cursor-- {
+			asn := order[cursor]
+			for _, l := range nodes[asn].Links {
+				if distance[l] == distance[asn]-1 {
+					contribution := paths[l] / paths[asn] * (1 + dependency[asn])
+					betweenness[linkKey(asn, l)] += contribution
+					dependency[l] += contribution
+				}
+			}
+		}
+	}
+
+	return betweenness
+}
+
+// LoadReport describes how the routes of a graph are spread over links and ASes
+type LoadReport struct {
+	// Routes is the number of pairs with a route
+	Routes int
+	// UsedLinks is the number of links traversed by at least one route (path diversity)
+	UsedLinks int
+	// Gini coefficients of the number of routes per link and per (transit) AS
+	LinkGini float64
+	ASGini   float64
+	// TopLinks are the k most loaded links (by decreasing load), TopLinksShare is the
+	// fraction of link traversals on them
+	TopLinks      []LinkLoad
+	TopLinksShare float64
+	// LandmarkShare is the fraction of transit traversals on the landmarks (level >= 1)
+	LandmarkShare float64
+}
+
+// LinkLoad is the number of routes traversing a link
+type LinkLoad struct {
+	Endpoints [2]int
+	Load      int
+}
+
+// MeasureLinkLoad routes a set of pairs on both graphs and compares how the routes are
+// spread over links and ASes. If samples <= 0, all the pairs of distinct ASes are routed,
+// a batch of 'batchSize' (>= 1) destinations at a time (like MeasureExhaustiveStretch)
+// Landmarks are taken from the audited graph (if it's a tz.Graph), so that the load shifted
+// onto them by TZ can be compared to the BGP load of the same ASes
+// If recording is active, the load of each link on both graphs is saved to file
+// returns the "baselineLinkLoad" and "auditedLinkLoad" metrics (one sample per link),
+// with the LoadReport of both graphs ("baseline" and "audited") as details
+func MeasureLinkLoad(recorder *Recorder, baseline AbstractGraph, audited AbstractGraph, samples int, topK int, batchSize int) Result {
+
+	recorder.start("link-load", map[string]interface{}{"samples": samples, "topK": topK, "batchSize": batchSize}, []Column{{"endpointA", IntColumn}, {"endpointB", IntColumn}, {"baseLoad", IntColumn}, {"auditLoad", IntColumn}})
+
+	landmarks := make(map[int]bool)
+	if tzAudited, isTz := audited.(*tz.Graph); isTz {
+		for level := 1; level < tzAudited.K; level++ {
+			for nd := range tzAudited.Landmarks[level] {
+				landmarks[nd.Asn] = true
+			}
+		}
+	}
+
+	baseLinkLoad, baseASLoad := make(map[[2]int]int), make(map[int]int)
+	auditLinkLoad, auditASLoad := make(map[[2]int]int), make(map[int]int)
+	var baseRouted, auditRouted int
+
+	addBatch := func(pairs [][2]int) {
+		baseRoutes, _ := routePairs(baseline, pairs)
+		baseRouted += addPathLoads(baseRoutes, baseLinkLoad, baseASLoad)
+		auditRoutes, _ := routePairs(audited, pairs)
+		auditRouted += addPathLoads(auditRoutes, auditLinkLoad, auditASLoad)
+	}
+
+	if samples > 0 {
+		addBatch(randomPairs(baseline, samples, map[int]bool{}))
+	} else {
+		batchesTowards(baseline, batchSize, "Link load destinations", addBatch)
+	}
+
+	var baseLoads, auditLoads stats.Sample
+
+	// Links without routes are part of the distribution as well
+	nodes := *audited.GetNodes()
+	for asn, nd := range nodes {
+		for _, l := range nd.Links {
+			if asn < l {
+				baseLoads.Add(float64(baseLinkLoad[linkKey(asn, l)]))
+				auditLoads.Add(float64(auditLinkLoad[linkKey(asn, l)]))
+
+				recorder.record(
+					u.Str(asn),
+					u.Str(l),
+					u.Str(baseLinkLoad[linkKey(asn, l)]),
+					u.Str(auditLinkLoad[linkKey(asn, l)]),
+				)
+			}
+		}
+	}
+
+	result := newResult("link-load")
+	result.addSample("baselineLinkLoad", &baseLoads)
+	result.addSample("auditedLinkLoad", &auditLoads)
+	result.Details = map[string]LoadReport{
+		"baseline": loadReport(nodes, baseRouted, baseLinkLoad, baseASLoad, landmarks, topK),
+		"audited":  loadReport(nodes, auditRouted, auditLinkLoad, auditASLoad, landmarks, topK),
+	}
+
+	recorder.end(&result)
+
+	return result
+}
+
+func loadReport(nodes map[int]*Node, routed int, linkLoad map[[2]int]int, asLoad map[int]int, landmarks map[int]bool, topK int) LoadReport {
+	report := LoadReport{Routes: routed}
+
+	links := make([]LinkLoad, 0, len(linkLoad))
+	linkValues := make([]float64, 0, len(linkLoad))
+	asValues := make([]float64, 0, len(nodes))
+
+	var linkTotal float64
+	for asn, nd := range nodes {
+		for _, l := range nd.Links {
+			if asn < l {
+				load := linkLoad[linkKey(asn, l)]
+				links = append(links, LinkLoad{Endpoints: linkKey(asn, l), Load: load})
+				linkValues = append(linkValues, float64(load))
+				linkTotal += float64(load)
+				if load > 0 {
+					report.UsedLinks++
+				}
+			}
+		}
+	}
+
+	var asTotal, landmarkLoad float64
+	for asn := range nodes {
+		load := float64(asLoad[asn])
+		asValues = append(asValues, load)
+		asTotal += load
+		if landmarks[asn] {
+			landmarkLoad += load
+		}
+	}
+
+	report.LinkGini = gini(linkValues)
+	report.ASGini = gini(asValues)
+
+	// Links with the same load are sorted by endpoints, so that the top links do not
+	// depend on the order of the maps
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].Load != links[j].Load {
+			return links[i].Load > links[j].Load
+		}
+		if links[i].Endpoints[0] != links[j].Endpoints[0] {
+			return links[i].Endpoints[0] < links[j].Endpoints[0]
+		}
+		return links[i].Endpoints[1] < links[j].Endpoints[1]
+	})
+	if topK > len(links) {
+		topK = len(links)
+	}
+	if topK > 0 {
+		report.TopLinks = links[:topK]
+	}
+
+	var topLoad float64
+	for _, link := range report.TopLinks {
+		topLoad += float64(link.Load)
+	}
+
+	if linkTotal > 0 {
+		report.TopLinksShare = topLoad / linkTotal
+	}
+	if asTotal > 0 {
+		report.LandmarkShare = landmarkLoad / asTotal
+	}
+
+	return report
+}
+
+// batchesTowards calls 'process' with the pairs from every AS of the graph towards
+// each batch of 'batchSize' destinations (in increasing order of ASN)
+func batchesTowards(graph AbstractGraph, batchSize int, task string, process func(pairs [][2]int)) {
+	if batchSize < 1 {
+		panic("The batch size must be >= 1, got " + u.Str(batchSize))
+	}
+
+	asns := make([]int, 0, len(*graph.GetNodes()))
+	for asn := range *graph.GetNodes() {
+		asns = append(asns, asn)
+	}
+	sort.Ints(asns)
+
+	tracker := shell.StartTask(task, len(asns))
+	defer tracker.Finish()
+
+	for start := 0; start < len(asns); start += batchSize {
+		end := start + batchSize
+		if end > len(asns) {
+			end = len(asns)
+		}
+		process(pairsTowards(asns, asns[start:end]))
+		tracker.Add(end - start)
+	}
+}
+
+// pairsTowards returns the pairs from every AS of 'origins' to every AS of 'destinations'
+// (except the pairs of an AS with itself)
+func pairsTowards(origins []int, destinations []int) [][2]int {
+	pairs := make([][2]int, 0, len(origins)*len(destinations))
+	for _, ds := range destinations {
+		for _, or := range origins {
+			if or != ds {
+				pairs = append(pairs, [2]int{or, ds})
+			}
+		}
+	}
+
+	return pairs
+}
+
+// gini returns the Gini coefficient of the values (0: equal loads, 1: all the load on one element)
+func gini(values []float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	var total, weighted float64
+	for idx, v := range sorted {
+		total += v
+		weighted += float64(idx+1) * v
+	}
+
+	n := float64(len(values))
+	if total == 0 || n == 0 {
+		return 0
+	}
+
+	return 2*weighted/(n*total) - (n+1)/n
+}
@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	. "dedis.epfl.ch/core"
+	"dedis.epfl.ch/tz"
+)
+
+// Provenance describes the inputs of an experiment
+type Provenance struct {
+	Dataset       string `json:"dataset"`
+	DatasetSHA256 string `json:"datasetSha256"`
+	K             int    `json:"k"`
+	Strategy      string `json:"strategy"`
+	Policy        string `json:"policy"`
+}
+
+// Manifest describes how the rows of a Recorder were produced
+type Manifest struct {
+	Output      string                 `json:"output"`
+	Measurement string                 `json:"measurement"`
+	Columns     []Column               `json:"columns"`
+	Parameters  map[string]interface{} `json:"parameters"`
+	Provenance  Provenance             `json:"provenance"`
+	Seed        int64                  `json:"seed"`
+	GitRevision string                 `json:"gitRevision"`
+	GitDirty    bool                   `json:"gitDirty"`
+	Rows        int                    `json:"rows"`
+	Started     time.Time              `json:"started"`
+	WallTime    float64                `json:"wallTimeSeconds"`
+}
+
+// HashFile returns the hex-encoded SHA-256 of a file
+func HashFile(filename string) string {
+	hashedFile, err := os.Open(filename)
+	if err != nil {
+		panic("Unable to open " + filename)
+	}
+	defer hashedFile.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, hashedFile); err != nil {
+		panic("Unable to read " + filename)
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// gitRevision returns the current commit (and whether the tree has local changes),
+// or "unknown" if git is not available
+func gitRevision() (string, bool) {
+	revision, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "unknown", false
+	}
+
+	status, err := exec.Command("git", "status", "--porcelain", "--untracked-files=no").Output()
+
+	return strings.TrimSpace(string(revision)), err == nil && len(status) > 0
+}
+
+// traceColumns returns the columns added by formatTrace
+func traceColumns(audited AbstractGraph) []Column {
+	if _, isTz := audited.(*tz.Graph); isTz {
+		return []Column{{"invalidations", IntColumn}, {"announcements", IntColumn}, {"distanceChanges", IntColumn}}
+	}
+	return []Column{}
+}
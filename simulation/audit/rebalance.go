@@ -0,0 +1,78 @@
+package audit
+
+import (
+	"fmt"
+	"time"
+
+	"dedis.epfl.ch/audit/stats"
+	"dedis.epfl.ch/shell"
+	"dedis.epfl.ch/tz"
+	"dedis.epfl.ch/u"
+)
+
+// MeasureRebalancing deletes a fraction 'deletionProportion' of edges per round (without
+// creating multiple connected components) and re-balances the landmarks after each round
+// For each round, the stretch before and after the re-balancing is recorded next
+// to its cost (moved landmarks, rebuilt clusters, impacted nodes and time)
+// returns the "stretchReduction", "rebuiltClusters", "impactedNodes" and "elapsedMs" metrics (one sample per round)
+func MeasureRebalancing(recorder *Recorder, auditedOriginal *tz.Graph, rounds int, deletionProportion float64, config tz.RebalanceConfig) Result {
+
+	config.IsValid()
+
+	recorder.start("rebalancing", map[string]interface{}{"rounds": rounds, "deletionProportion": deletionProportion, "config": config}, []Column{{"round", IntColumn}, {"promoted", IntColumn}, {"demoted", IntColumn}, {"rebuiltClusters", IntColumn}, {"impactedNodes", IntColumn}, {"elapsedMs", IntColumn}, {"stretchBefore", FloatColumn}, {"stretchAfter", FloatColumn}})
+
+	recorder.seedRandom()
+
+	tracker := shell.StartTask("Rebalancing rounds", rounds)
+	defer tracker.Finish()
+
+	// Conduct measurements on a copy of the graph
+	audited := auditedOriginal.CopyAsTz()
+
+	var reduction, rebuilt, impacted, elapsedMs stats.Sample
+
+	for r := 0; r < rounds; r++ {
+
+		audited.Begin()
+		for !deletionsRound(nil, audited, r, deletionProportion) {
+			fmt.Println("Obtained 2 connected components, rolling back the round ...")
+			audited.Rollback()
+			audited.Begin()
+		}
+		audited.Commit()
+
+		start := time.Now()
+		report := audited.Rebalance(config)
+		elapsed := time.Since(start)
+
+		reduction.Add(report.StretchBefore - report.StretchAfter)
+		rebuilt.Add(float64(report.RebuiltClusters))
+		impacted.Add(float64(report.ImpactedNodes))
+		elapsedMs.Add(float64(elapsed.Milliseconds()))
+
+		tracker.Step()
+
+		recorder.record(
+			u.Str(r),
+			u.Str(len(report.Promoted)),
+			u.Str(len(report.Demoted)),
+			u.Str(report.RebuiltClusters),
+			u.Str(report.ImpactedNodes),
+			u.Str64(elapsed.Milliseconds()),
+			fmt.Sprintf("%f", report.StretchBefore),
+			fmt.Sprintf("%f", report.StretchAfter),
+		)
+
+		fmt.Printf("	Round %d: %d promotions, %d demotions, stretch %f -> %f\n", r, len(report.Promoted), len(report.Demoted), report.StretchBefore, report.StretchAfter)
+	}
+
+	result := newResult("rebalancing")
+	result.addSample("stretchReduction", &reduction)
+	result.addSample("rebuiltClusters", &rebuilt)
+	result.addSample("impactedNodes", &impacted)
+	result.addSample("elapsedMs", &elapsedMs)
+
+	recorder.end(&result)
+
+	return result
+}
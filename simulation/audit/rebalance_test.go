@@ -0,0 +1,79 @@
+package audit
+
+import (
+	"strconv"
+	"testing"
+
+	. "dedis.epfl.ch/core"
+	"dedis.epfl.ch/tz"
+	"dedis.epfl.ch/u"
+)
+
+// loadCliqueGraph preprocesses data/clique.csv (12 ASes, where the lower ASN of each
+// link is the provider) with the landmarks 1 and 2 at level 1 and 1 at level 2
+// Deletion rounds require 8 links at both endpoints, that data/test.csv lacks
+func loadCliqueGraph(t *testing.T) *tz.Graph {
+	audited := tz.InitGraph()
+	audited.K = 3
+	if err := tz.LoadFromCsv(&audited, "../data/clique.csv"); err != nil {
+		t.Fatal(err)
+	}
+	audited.Landmarks[0] = make(map[*Node]bool)
+	for _, nd := range audited.Nodes {
+		audited.Landmarks[0][nd] = true
+	}
+	audited.Landmarks[1] = map[*Node]bool{audited.Nodes[1]: true, audited.Nodes[2]: true}
+	audited.Landmarks[2] = map[*Node]bool{audited.Nodes[1]: true}
+	audited.Landmarks[3] = nil
+	audited.Preprocess()
+
+	return &audited
+}
+
+func TestMeasureRebalancing(t *testing.T) {
+	audited := loadCliqueGraph(t)
+	u.FixedSeed = 5
+	defer func() { u.FixedSeed = 0 }()
+
+	sink := &MemorySink{}
+	config := tz.RebalanceConfig{MinClusterSize: 2, StretchSamples: 20}
+	result := MeasureRebalancing(NewRecorder(sink), audited, 2, 0.05, config)
+
+	if len(sink.Rows) != 2 {
+		t.Fatalf("%d rows recorded", len(sink.Rows))
+	}
+	for _, metric := range []string{"stretchReduction", "rebuiltClusters", "impactedNodes", "elapsedMs"} {
+		if summary := result.Metrics[metric]; summary.Count != 2 {
+			t.Errorf("%s: %+v", metric, summary)
+		}
+	}
+
+	// The cluster of 2 only contains 2 (1 is as close to the other ASes): the first round
+	// demotes it, then 1 is left alone at level 1
+	if demoted := u.Int(sink.Rows[0][2]) + u.Int(sink.Rows[1][2]); demoted != 1 || u.Int(sink.Rows[0][2]) != 1 {
+		t.Errorf("rounds %v demoted %d landmarks", sink.Rows, demoted)
+	}
+	for _, row := range sink.Rows {
+		if before, _ := strconv.ParseFloat(row[6], 64); before < 1 {
+			t.Errorf("round %v: stretch below 1", row)
+		}
+		if after, _ := strconv.ParseFloat(row[7], 64); after < 1 {
+			t.Errorf("round %v: stretch below 1", row)
+		}
+	}
+
+	// The measurement works on a copy
+	if landmarks := len(audited.Landmarks[1]); landmarks != 2 {
+		t.Errorf("%d landmarks of level 1 in the original graph", landmarks)
+	}
+}
+
+func TestMeasureRebalancingRequiresStretchSamples(t *testing.T) {
+	audited := loadCliqueGraph(t)
+	defer func() {
+		if recover() == nil {
+			t.Error("MaxStretch accepted without StretchSamples")
+		}
+	}()
+	MeasureRebalancing(NewRecorder(), audited, 1, 0.05, tz.RebalanceConfig{MaxStretch: 2})
+}
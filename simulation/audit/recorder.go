@@ -1,88 +1,185 @@
 package audit
 
 import (
-	"encoding/csv"
-	"os"
+	"math/rand"
 	"strings"
 	"sync"
+	"time"
+
+	"dedis.epfl.ch/u"
 )
 
 // TODO: Add support for DOS-like paths
 const pathSeparator = "/"
 
-const maxBufferSize = 50
-
-// Recorder stores test logs in a thread-safe way
+// Recorder stores the rows of a measurement in a thread-safe way, forwarding them to its sinks
+// Rows are not stored by a nil *Recorder
 type Recorder struct {
-	mutex      sync.Mutex
-	active     bool
-	folder     string
-	file       *os.File
-	rec        *csv.Writer
-	bufferSize int
+	mutex    sync.Mutex
+	sinks    []Sink
+	manifest Manifest
+	result   *Result
+	closed   bool
+	// checkpoints is the folder of the checkpoints ("" if they are disabled)
+	checkpoints string
+	// resumed is the checkpoint from which the measurement continues (nil if it starts afresh)
+	resumed *Checkpoint
+}
+
+// NewRecorder returns a recorder writing to all the sinks
+func NewRecorder(sinks ...Sink) *Recorder {
+	return &Recorder{
+		sinks: sinks,
+		manifest: Manifest{
+			Parameters: make(map[string]interface{}),
+			Started:    time.Now(),
+		},
+	}
 }
 
-var globalRecorder Recorder = Recorder{
-	active:     false,
-	folder:     "",
-	file:       nil,
-	rec:        nil,
-	bufferSize: 0,
+// InitRecorder returns a recorder writing to a csv file
+func InitRecorder(filename string) *Recorder {
+	return NewRecorder(NewCSVSink(filename))
 }
 
-// GetOutputDir returns the path to the directory used to store logs
-// the boolean is true if the path is valid
-func GetOutputDir() (bool, string) {
-	globalRecorder.mutex.Lock()
-	defer globalRecorder.mutex.Unlock()
+// SetProvenance sets the provenance written in the manifest
+func (r *Recorder) SetProvenance(provenance Provenance) {
+	if r == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 
-	if globalRecorder.active {
-		return true, globalRecorder.folder
+	r.manifest.Provenance = provenance
+}
+
+// Folder returns the path to the directory of the first file sink (ending with a slash)
+// the boolean is false if the recorder has no file sinks
+func (r *Recorder) Folder() (bool, string) {
+	if r == nil {
+		return false, ""
+	}
+
+	for _, s := range r.sinks {
+		if fileSink, isFile := s.(interface{ Filename() string }); isFile {
+			path := strings.Split(fileSink.Filename(), pathSeparator)
+			return true, strings.Join(path[:len(path)-1], pathSeparator) + pathSeparator
+		}
 	}
 
 	return false, ""
 }
 
-// InitRecorder initializes the globalRecorder
-func InitRecorder(filename string) {
-	path := strings.Split(filename, pathSeparator)
+// start describes the measurement in the manifest and sends the columns to the sinks
+func (r *Recorder) start(measurement string, parameters map[string]interface{}, columns []Column) {
+	if r == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.manifest.Measurement = measurement
+	r.manifest.Parameters = parameters
+	r.manifest.Columns = columns
+
+	for _, s := range r.sinks {
+		s.Header(columns)
+	}
+}
+
+// seedRandom seeds math/rand for a measurement (see u.SeedRandom), keeping the seed
+// for the manifest and the checkpoints
+// A measurement resumed from a checkpoint draws its rounds from the seed of the checkpoint
+func (r *Recorder) seedRandom() {
+	u.SeedRandom()
+
+	if r == nil {
+		return
+	}
 
-	globalRecorder.folder = strings.Join(path[:len(path)-1], pathSeparator) + pathSeparator
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 
-	var err error
-	globalRecorder.file, err = os.Create(filename)
-	if err != nil {
-		panic("Could not create the output file for the auditor")
+	r.manifest.Seed = u.LastSeed
+	if r.resumed != nil {
+		r.manifest.Seed = r.resumed.Seed
 	}
+}
+
+// seedRound reseeds math/rand for a round of a long measurement, deriving the seed
+// from the one of the measurement, so that a resumed measurement draws the same numbers
+func (r *Recorder) seedRound(round int) {
+	if r == nil {
+		u.SeedRound(round)
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 
-	globalRecorder.rec = csv.NewWriter(globalRecorder.file)
-	globalRecorder.active = true
-	globalRecorder.bufferSize = 0
+	rand.Seed(r.manifest.Seed + int64(round))
 }
 
 // record is thread-safe
-func record(payload ...string) {
-	globalRecorder.mutex.Lock()
-	defer globalRecorder.mutex.Unlock()
+func (r *Recorder) record(payload ...string) {
+	if r == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 
-	if globalRecorder.active {
-		globalRecorder.rec.Write(payload)
-		globalRecorder.bufferSize++
+	if r.closed {
+		panic("Recording on a closed recorder")
 	}
-	if globalRecorder.bufferSize >= maxBufferSize {
-		globalRecorder.rec.Flush()
-		globalRecorder.bufferSize = 0
+
+	r.manifest.Rows++
+	for _, s := range r.sinks {
+		s.Write(payload)
 	}
 }
 
-// stopRecording is thread-safe
-func stopRecording() {
-	globalRecorder.mutex.Lock()
-	defer globalRecorder.mutex.Unlock()
+// end keeps the result of the measurement, to be saved when the recorder is closed
+// The checkpoint of the measurement (if any) is no longer needed
+func (r *Recorder) end(result *Result) {
+	if r == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
 
-	if globalRecorder.active {
-		globalRecorder.rec.Flush()
-		defer globalRecorder.file.Close()
-		globalRecorder.active = false
+	r.result = result
+	r.resumed = nil
+	r.clearCheckpoint()
+}
+
+// Close completes the manifest and closes the sinks (closing twice has no effect)
+// returns the first error of the sinks (all of them are closed anyway)
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.closed {
+		return nil
 	}
+	r.closed = true
+
+	r.manifest.GitRevision, r.manifest.GitDirty = gitRevision()
+	r.manifest.WallTime = time.Since(r.manifest.Started).Seconds()
+
+	var firstErr error
+	for _, s := range r.sinks {
+		if err := s.Close(r.manifest, r.result); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
 }
@@ -0,0 +1,61 @@
+package audit
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"dedis.epfl.ch/audit/stats"
+)
+
+// Result is the outcome of a measurement: the summaries of the sampled metrics,
+// some scalar values and (optionally) measurement-specific details
+type Result struct {
+	Name    string                   `json:"name"`
+	Metrics map[string]stats.Summary `json:"metrics"`
+	Values  map[string]float64       `json:"values"`
+	Details interface{}              `json:"details,omitempty"`
+}
+
+func newResult(name string) Result {
+	return Result{
+		Name:    name,
+		Metrics: make(map[string]stats.Summary),
+		Values:  make(map[string]float64),
+	}
+}
+
+// addSample summarizes a sample under the given metric name
+func (r *Result) addSample(metric string, sample *stats.Sample) {
+	r.Metrics[metric] = sample.Summarize()
+}
+
+// WriteJSON saves the result to file
+func (r *Result) WriteJSON(filename string) error {
+	return writeJSON(filename, r)
+}
+
+func (r Result) String() string {
+	var sb strings.Builder
+	sb.WriteString(r.Name + "\n")
+
+	metrics := make([]string, 0, len(r.Metrics))
+	for m := range r.Metrics {
+		metrics = append(metrics, m)
+	}
+	sort.Strings(metrics)
+	for _, m := range metrics {
+		sb.WriteString("\t" + m + ": " + r.Metrics[m].String() + "\n")
+	}
+
+	values := make([]string, 0, len(r.Values))
+	for v := range r.Values {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	for _, v := range values {
+		sb.WriteString("\t" + v + ": " + strconv.FormatFloat(r.Values[v], 'f', -1, 64) + "\n")
+	}
+
+	return sb.String()
+}
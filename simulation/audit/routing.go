@@ -0,0 +1,143 @@
+package audit
+
+import (
+	"dedis.epfl.ch/audit/stats"
+	. "dedis.epfl.ch/core"
+	"dedis.epfl.ch/shell"
+	"dedis.epfl.ch/tz"
+	"dedis.epfl.ch/u"
+)
+
+// MeasureRoutingTableSizes records, for each AS, the size of its routing table and label
+// in the Thorup-Zwick routing scheme
+// returns the "tableBits" and "labelBits" metrics
+func MeasureRoutingTableSizes(recorder *Recorder, audited *tz.Graph) Result {
+
+	recorder.start("routing-table-sizes", map[string]interface{}{}, []Column{{"asn", IntColumn}, {"degree", IntColumn}, {"tableEntries", IntColumn}, {"tableBits", IntColumn}, {"labelEntries", IntColumn}, {"labelBits", IntColumn}})
+
+	tracker := shell.StartTask("Routing tables", len(audited.Nodes))
+	defer tracker.Finish()
+
+	scheme := audited.BuildRoutingScheme()
+
+	var tableSizes, labelSizes stats.Sample
+
+	for asn, nd := range audited.Nodes {
+		tableBits := scheme.TableBits(asn)
+
+		tableSizes.Add(float64(tableBits))
+		labelSizes.Add(float64(scheme.LabelBits(asn)))
+
+		tracker.Step()
+
+		recorder.record(
+			u.Str(asn),
+			u.Str(len(nd.Links)),
+			u.Str(len(scheme.Tables[asn])),
+			u.Str(tableBits),
+			u.Str(len(scheme.Labels[asn])),
+			u.Str(scheme.LabelBits(asn)),
+		)
+	}
+
+	result := newResult("routing-table-sizes")
+	result.addSample("tableBits", &tableSizes)
+	result.addSample("labelBits", &labelSizes)
+
+	recorder.end(&result)
+
+	return result
+}
+
+// MeasureRoutingScheme forwards packets between random pairs of distinct ASes using only
+// local routing tables and destination labels, and compares the routes with GetRoute
+// Pairs without a route in GetRoute are only counted
+// returns the "lengthRatio" metric (between the length of delivered forwarded routes
+// and the one of GetRoute), the "unroutedFraction", "undeliveredFraction" and
+// "routeMismatchFraction" values (the last two among the pairs with a route)
+func MeasureRoutingScheme(recorder *Recorder, audited *tz.Graph, samples int) Result {
+
+	recorder.start("routing-scheme", map[string]interface{}{"samples": samples}, []Column{{"tzLength", IntColumn}, {"forwardedLength", IntColumn}, {"delivered", IntColumn}, {"matchesRoute", IntColumn}, {"tzPath", IntListColumn}, {"forwardedPath", IntListColumn}})
+
+	recorder.seedRandom()
+
+	tracker := shell.StartTask("Forwarded packets", samples)
+	defer tracker.Finish()
+
+	scheme := audited.BuildRoutingScheme()
+
+	var unrouted, undelivered, mismatches int
+	var lengthRatio stats.Sample
+
+	for _, pair := range randomPairs(audited, samples, map[int]bool{}) {
+		tzPath, _ := audited.GetRoute(pair[0], pair[1])
+		forwardedPath, delivered := scheme.Forward(pair[0], pair[1])
+
+		tracker.Step()
+
+		if tzPath == nil {
+			unrouted++
+			continue
+		}
+
+		deliveredFlag := 1
+		if !delivered {
+			undelivered++
+			deliveredFlag = 0
+		} else {
+			lengthRatio.Add(float64(len(forwardedPath)-1) / float64(len(tzPath)-1))
+		}
+
+		matchesFlag := 0
+		if delivered && samePath(forwardedPath, pathAsns(tzPath)) {
+			matchesFlag = 1
+		} else if delivered {
+			mismatches++
+		}
+
+		recorder.record(
+			u.Str(len(tzPath)-1),
+			u.Str(len(forwardedPath)-1),
+			u.Str(deliveredFlag),
+			u.Str(matchesFlag),
+			formatPath(tzPath),
+			formatAsnPath(forwardedPath),
+		)
+	}
+
+	result := newResult("routing-scheme")
+	result.addSample("lengthRatio", &lengthRatio)
+	if samples > 0 {
+		result.Values["unroutedFraction"] = float64(unrouted) / float64(samples)
+	}
+	if routed := samples - unrouted; routed > 0 {
+		result.Values["undeliveredFraction"] = float64(undelivered) / float64(routed)
+		result.Values["routeMismatchFraction"] = float64(mismatches) / float64(routed)
+	}
+
+	recorder.end(&result)
+
+	return result
+}
+
+// pathAsns returns the ASNs of the ASes of a path
+func pathAsns(path []*Node) []int {
+	asns := make([]int, len(path))
+	for idx, nd := range path {
+		asns[idx] = nd.Asn
+	}
+	return asns
+}
+
+// samePath returns true if the two paths traverse the same ASes
+func samePath(a []int, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for idx := range a {
+		if a[idx] != b[idx] {
+			return false
+		}
+	}
+	return true
+}
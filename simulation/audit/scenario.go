@@ -0,0 +1,267 @@
+package audit
+
+import (
+	"fmt"
+	"sort"
+
+	"dedis.epfl.ch/audit/stats"
+	. "dedis.epfl.ch/core"
+	"dedis.epfl.ch/shell"
+	"dedis.epfl.ch/u"
+)
+
+// Scenario is a set of correlated failures: ASes going down (Outages), then links
+type Scenario struct {
+	Name    string
+	Outages []int
+	Links   [][2]int
+}
+
+// ScenarioReport summarizes the impact of a scenario and the stretch measured after it
+type ScenarioReport struct {
+	Name string
+	// Removed counts the performed deletions, Refused the ones rejected by RemoveEdge
+	// (e.g. because an AS would remain isolated)
+	Removed       int
+	Refused       int
+	Disconnected  int
+	AverageImpact float64
+	MaxImpact     float64
+	// Messages is the number of repair messages (only available on tz.Graph)
+	Messages   int
+	Stretch    float64
+	MaxStretch float64
+	ValleyRate float64
+	// UnroutedRate is the fraction of paths routed by the baseline but not by the audited graph
+	UnroutedRate float64
+}
+
+// ASOutageScenario removes an AS (with RemoveNode on tz.Graph)
+func ASOutageScenario(graph AbstractGraph, asn int) Scenario {
+	scenario := Scenario{Name: fmt.Sprintf("outage-%d", asn), Outages: make([]int, 0, 1), Links: make([][2]int, 0)}
+
+	if _, exists := (*graph.GetNodes())[asn]; exists {
+		scenario.Outages = append(scenario.Outages, asn)
+	}
+
+	return scenario
+}
+
+// DepeeringScenario fails all the peer links of an AS
+func DepeeringScenario(graph AbstractGraph, asn int) Scenario {
+	scenario := Scenario{Name: fmt.Sprintf("depeering-%d", asn), Links: make([][2]int, 0)}
+
+	if nd, exists := (*graph.GetNodes())[asn]; exists {
+		for idx, l := range nd.Links {
+			if nd.Type[idx] == ToPeer {
+				scenario.Links = append(scenario.Links, [2]int{asn, l})
+			}
+		}
+	}
+
+	return scenario
+}
+
+// TopDegreeLinksScenario fails the n links with the highest degree, i.e. the highest
+// sum of the number of links of their endpoints (ties are broken by ASN)
+func TopDegreeLinksScenario(graph AbstractGraph, n int) Scenario {
+	nodes := *graph.GetNodes()
+
+	links := make([][2]int, 0, graph.CountLinks())
+	for asn, nd := range nodes {
+		for _, l := range nd.Links {
+			if asn < l {
+				links = append(links, [2]int{asn, l})
+			}
+		}
+	}
+
+	degree := func(link [2]int) int {
+		return len(nodes[link[0]].Links) + len(nodes[link[1]].Links)
+	}
+
+	sort.Slice(links, func(i, j int) bool {
+		if degree(links[i]) != degree(links[j]) {
+			return degree(links[i]) > degree(links[j])
+		}
+		if links[i][0] != links[j][0] {
+			return links[i][0] < links[j][0]
+		}
+		return links[i][1] < links[j][1]
+	})
+
+	if n < len(links) {
+		links = links[:n]
+	}
+
+	return Scenario{Name: fmt.Sprintf("top-degree-%d", n), Links: links}
+}
+
+// ProviderOutageScenario fails every link of a set of providers
+// (links between two providers of the set appear once)
+func ProviderOutageScenario(graph AbstractGraph, providers []int) Scenario {
+	scenario := Scenario{Name: fmt.Sprintf("providers-%d", len(providers)), Links: make([][2]int, 0)}
+
+	failed := make(map[int]bool)
+	for _, asn := range providers {
+		failed[asn] = true
+	}
+
+	nodes := *graph.GetNodes()
+	for _, asn := range providers {
+		nd, exists := nodes[asn]
+		if !exists {
+			continue
+		}
+		for _, l := range nd.Links {
+			if failed[l] && l < asn {
+				// Already added from the other provider
+				continue
+			}
+			scenario.Links = append(scenario.Links, [2]int{asn, l})
+		}
+	}
+
+	return scenario
+}
+
+// MeasureScenarios applies each scenario to copies of the graphs (through RemoveNode
+// and RemoveEdge), recording the impact of every deletion, then measures the stretch
+// over 'samples' random paths (among the ASes that remained connected and up)
+// The graphs are rolled back before the following scenario
+// If recording is active, the impact of each deletion is saved (starting with the name of
+// the scenario, and with endpointB -1 for AS outages): the measured paths are not recorded
+// returns the "<name>/impact" and "<name>/stretch" metrics of each scenario,
+// with the list of ScenarioReport as details
+func MeasureScenarios(recorder *Recorder, baselineOriginal AbstractGraph, auditedOriginal AbstractGraph, scenarios []Scenario, samples int) Result {
+
+	recorder.start("scenarios", map[string]interface{}{"scenarios": len(scenarios), "samples": samples}, append([]Column{{"scenario", StringColumn}, {"endpointA", IntColumn}, {"endpointB", IntColumn}, {"impacted", IntColumn}, {"impactMeasure", StringColumn}}, traceColumns(auditedOriginal)...))
+
+	tracker := shell.StartTask("Scenarios", len(scenarios))
+	defer tracker.Finish()
+
+	// Conduct measurements on a copy of the graphs
+	baseline := baselineOriginal.Copy()
+	audited := auditedOriginal.Copy()
+
+	reports := make([]ScenarioReport, 0, len(scenarios))
+	result := newResult("scenarios")
+
+	for _, scenario := range scenarios {
+
+		report := ScenarioReport{Name: scenario.Name}
+
+		baseline.Begin()
+		audited.Begin()
+
+		disconnectedNodes := make(map[int]bool)
+		// The ASes that went down are not used as endpoints
+		excludedNodes := make(map[int]bool)
+
+		var impact stats.Sample
+
+		for _, asn := range scenario.Outages {
+			success, impactedArea, impactedMeasure, trace := removeNodeWithTrace(audited, asn)
+			impactedNodes := len(impactedArea)
+
+			if !success && impactedNodes == 0 {
+				report.Refused++
+				continue
+			}
+
+			// The AS went down in the audited graph (even if it split the graph)
+			detachNode(baseline, asn)
+			excludedNodes[asn] = true
+
+			if !success {
+				disconnectedNodes = u.Union(disconnectedNodes, impactedArea)
+				continue
+			}
+
+			report.Removed++
+			impact.Add(float64(impactedNodes))
+			if trace != nil {
+				report.Messages += trace.Total()
+			}
+
+			recorder.record(append([]string{
+				scenario.Name,
+				u.Str(asn),
+				u.Str(-1),
+				u.Str(impactedNodes),
+				formatMeasure(impactedMeasure),
+			}, formatTrace(trace)...)...)
+		}
+
+		for _, link := range scenario.Links {
+			success, impactedArea, impactedMeasure, trace := removeEdgeWithTrace(audited, link[0], link[1])
+			impactedNodes := len(impactedArea)
+
+			if !success && impactedNodes == 0 {
+				report.Refused++
+				continue
+			}
+
+			// The link was deleted from the audited graph (even if it split the graph)
+			baseline.RemoveEdge(link[0], link[1])
+
+			if !success {
+				disconnectedNodes = u.Union(disconnectedNodes, impactedArea)
+				continue
+			}
+
+			report.Removed++
+			impact.Add(float64(impactedNodes))
+			if trace != nil {
+				report.Messages += trace.Total()
+			}
+
+			recorder.record(append([]string{
+				scenario.Name,
+				u.Str(link[0]),
+				u.Str(link[1]),
+				u.Str(impactedNodes),
+				formatMeasure(impactedMeasure),
+			}, formatTrace(trace)...)...)
+		}
+
+		report.AverageImpact = impact.Mean()
+		report.MaxImpact = impact.Max()
+		report.Disconnected = len(disconnectedNodes)
+
+		// Measure stretch
+		stretchChannel := roundChannels{
+			stretchSamples:       make(chan *stats.Sample, 1),
+			valleyContribution:   make(chan int, 1),
+			unroutedContribution: make(chan int, 1),
+		}
+
+		// Stretch rows have other columns than the impact ones
+		// The ASes that went down are not sampled either
+		sampledOut := u.Union(u.Union(make(map[int]bool), disconnectedNodes), excludedNodes)
+		go stretchRound(nil, baseline, audited, samples, sampledOut, stretchChannel)
+
+		stretch := <-stretchChannel.stretchSamples
+		report.Stretch = stretch.Mean()
+		report.MaxStretch = stretch.Max()
+		report.ValleyRate = float64(<-stretchChannel.valleyContribution) / float64(samples)
+		report.UnroutedRate = float64(<-stretchChannel.unroutedContribution) / float64(samples)
+
+		result.addSample(scenario.Name+"/impact", &impact)
+		result.addSample(scenario.Name+"/stretch", stretch)
+
+		fmt.Printf("	Scenario %s: %d deletions (%d refused), %d disconnected ASes, stretch %f\n", scenario.Name, report.Removed, report.Refused, report.Disconnected, report.Stretch)
+
+		baseline.Rollback()
+		audited.Rollback()
+
+		reports = append(reports, report)
+		tracker.Step()
+	}
+
+	result.Details = reports
+
+	recorder.end(&result)
+
+	return result
+}
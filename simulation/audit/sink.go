@@ -0,0 +1,257 @@
+package audit
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"dedis.epfl.ch/u"
+)
+
+const maxBufferSize = 50
+
+// Column is a recorded column: its name and the type of its values
+type Column struct {
+	Name string     `json:"name"`
+	Type ColumnType `json:"type"`
+}
+
+// columnNames returns the names of the columns
+func columnNames(columns []Column) []string {
+	names := make([]string, len(columns))
+	for idx, column := range columns {
+		names[idx] = column.Name
+	}
+	return names
+}
+
+// Sink receives the rows of a Recorder
+type Sink interface {
+	// Header receives the columns (once, before the rows)
+	Header(columns []Column)
+	Write(row []string)
+	// Close receives the manifest and the result (nil if the measurement did not end)
+	// returns the error of saving them, if any
+	Close(manifest Manifest, result *Result) error
+}
+
+// ResumableSink is a Sink whose output can be continued by a measurement resumed from a checkpoint
+type ResumableSink interface {
+	Sink
+	// Offset flushes the rows received so far and returns the position after them
+	Offset() int64
+}
+
+// CSVSink writes rows to a csv file (with a header); the manifest and the result are
+// saved next to it, with extensions .manifest.json and .json
+type CSVSink struct {
+	filename   string
+	file       *os.File
+	rec        *csv.Writer
+	bufferSize int
+	resumed    bool
+}
+
+// NewCSVSink creates the csv file
+func NewCSVSink(filename string) *CSVSink {
+	file, err := os.Create(filename)
+	if err != nil {
+		panic("Could not create the output file for the auditor")
+	}
+
+	return &CSVSink{filename: filename, file: file, rec: csv.NewWriter(file)}
+}
+
+// ResumeCSVSink opens the csv file, keeping its first 'offset' bytes (as returned by Offset):
+// the header is not written again
+func ResumeCSVSink(filename string, offset int64) *CSVSink {
+	file := openAt(filename, offset)
+	return &CSVSink{filename: filename, file: file, rec: csv.NewWriter(file), resumed: true}
+}
+
+// Filename returns the path of the csv file
+func (s *CSVSink) Filename() string {
+	return s.filename
+}
+
+// Header implements Sink
+func (s *CSVSink) Header(columns []Column) {
+	if !s.resumed {
+		s.Write(columnNames(columns))
+	}
+}
+
+// Write implements Sink
+func (s *CSVSink) Write(row []string) {
+	s.rec.Write(row)
+	s.bufferSize++
+
+	if s.bufferSize >= maxBufferSize {
+		s.rec.Flush()
+		s.bufferSize = 0
+	}
+}
+
+// Offset implements ResumableSink
+func (s *CSVSink) Offset() int64 {
+	s.rec.Flush()
+	s.bufferSize = 0
+	return fileOffset(s.file)
+}
+
+// Close implements Sink
+func (s *CSVSink) Close(manifest Manifest, result *Result) error {
+	s.rec.Flush()
+	s.file.Close()
+
+	return writeSidecars(strings.TrimSuffix(s.filename, ".csv"), s.filename, manifest, result)
+}
+
+// JSONLinesSink writes each row to a file as a json object (keyed by column),
+// one per line; the manifest and the result are saved next to it
+type JSONLinesSink struct {
+	filename string
+	file     *os.File
+	encoder  *json.Encoder
+	columns  []string
+}
+
+// NewJSONLinesSink creates the json lines file
+func NewJSONLinesSink(filename string) *JSONLinesSink {
+	file, err := os.Create(filename)
+	if err != nil {
+		panic("Could not create the output file for the auditor")
+	}
+
+	return &JSONLinesSink{filename: filename, file: file, encoder: json.NewEncoder(file)}
+}
+
+// ResumeJSONLinesSink opens the json lines file, keeping its first 'offset' bytes (as returned by Offset)
+func ResumeJSONLinesSink(filename string, offset int64) *JSONLinesSink {
+	file := openAt(filename, offset)
+	return &JSONLinesSink{filename: filename, file: file, encoder: json.NewEncoder(file)}
+}
+
+// Filename returns the path of the json lines file
+func (s *JSONLinesSink) Filename() string {
+	return s.filename
+}
+
+// Header implements Sink
+func (s *JSONLinesSink) Header(columns []Column) {
+	s.columns = columnNames(columns)
+}
+
+// Write implements Sink
+// Values without a column (e.g. when rows are longer than the header) are keyed by their index
+func (s *JSONLinesSink) Write(row []string) {
+	object := make(map[string]string, len(row))
+	for idx, value := range row {
+		if idx < len(s.columns) {
+			object[s.columns[idx]] = value
+		} else {
+			object[u.Str(idx)] = value
+		}
+	}
+
+	if err := s.encoder.Encode(object); err != nil {
+		panic("Unable to write to " + s.filename)
+	}
+}
+
+// Offset implements ResumableSink
+func (s *JSONLinesSink) Offset() int64 {
+	return fileOffset(s.file)
+}
+
+// Close implements Sink
+func (s *JSONLinesSink) Close(manifest Manifest, result *Result) error {
+	s.file.Close()
+
+	return writeSidecars(strings.TrimSuffix(s.filename, ".jsonl"), s.filename, manifest, result)
+}
+
+// MemorySink keeps rows, manifest and result in memory
+type MemorySink struct {
+	Columns  []Column
+	Rows     [][]string
+	Manifest Manifest
+	Result   *Result
+}
+
+// Header implements Sink
+func (s *MemorySink) Header(columns []Column) {
+	s.Columns = columns
+}
+
+// Write implements Sink
+func (s *MemorySink) Write(row []string) {
+	s.Rows = append(s.Rows, append([]string{}, row...))
+}
+
+// Close implements Sink
+func (s *MemorySink) Close(manifest Manifest, result *Result) error {
+	s.Manifest = manifest
+	s.Result = result
+	return nil
+}
+
+// openAt opens an existing file, truncated after 'offset' bytes, ready to append
+func openAt(filename string, offset int64) *os.File {
+	file, err := os.OpenFile(filename, os.O_RDWR, 0644)
+	if err != nil {
+		panic("Could not open the output file " + filename + " to resume it")
+	}
+
+	if err := file.Truncate(offset); err != nil {
+		panic("Could not truncate the output file " + filename + ": " + err.Error())
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		panic("Could not seek in the output file " + filename + ": " + err.Error())
+	}
+
+	return file
+}
+
+// fileOffset returns the current position in the file
+func fileOffset(file *os.File) int64 {
+	offset, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		panic("Could not get the position in " + file.Name() + ": " + err.Error())
+	}
+	return offset
+}
+
+// writeSidecars saves the manifest (and the result, if any) next to an output file
+func writeSidecars(prefix string, output string, manifest Manifest, result *Result) error {
+	manifest.Output = output
+	if err := writeJSON(prefix+".manifest.json", manifest); err != nil {
+		return err
+	}
+
+	if result != nil {
+		return writeJSON(prefix+".json", result)
+	}
+	return nil
+}
+
+// writeJSON saves an indented json representation of v to file
+func writeJSON(filename string, v interface{}) error {
+	jsonFile, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(jsonFile)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		jsonFile.Close()
+		return fmt.Errorf("unable to serialize %s: %v", filename, err)
+	}
+
+	return jsonFile.Close()
+}
@@ -0,0 +1,86 @@
+package audit
+
+import (
+	"dedis.epfl.ch/audit/stats"
+	"dedis.epfl.ch/bgp"
+	"dedis.epfl.ch/shell"
+	"dedis.epfl.ch/tz"
+	"dedis.epfl.ch/u"
+)
+
+// ASNBytes is the size of an AS number stored in routing state
+const ASNBytes int = 4
+
+// DistanceBytes is the size of a distance (or path length) stored in routing state
+const DistanceBytes int = 2
+
+// BGPRouteBytes estimates the size of a BGP route without its AS path (destination and
+// next-hop): each AS of the path takes ASNBytes more
+const BGPRouteBytes int = 2 * ASNBytes
+
+// BunchEntryBytes estimates the size of a bunch entry (landmark, next-hop towards it and distance)
+const BunchEntryBytes int = 2*ASNBytes + DistanceBytes
+
+// WitnessEntryBytes estimates the size of a witness entry (witness, next-hop towards it and
+// distance): the level is the position of the entry
+const WitnessEntryBytes int = 2*ASNBytes + DistanceBytes
+
+// MeasureStateSize records, for each AS, the number of routes and the estimated bytes
+// held by its BGP speaker when all destinations are routed, next to the number of
+// entries of its TZ bunch and witnesses and their estimated bytes
+// returns the "bgpBytes" and "tzBytes" metrics and the "tzToBgpRatio" value (of the averages,
+// omitted if the BGP speakers hold no routes)
+func MeasureStateSize(recorder *Recorder, bgpOriginal *bgp.Graph, tzGraph *tz.Graph) Result {
+
+	recorder.start("state-size", map[string]interface{}{}, []Column{{"asn", IntColumn}, {"degree", IntColumn}, {"bgpEntries", IntColumn}, {"bgpBytes", IntColumn}, {"bunchEntries", IntColumn}, {"witnessEntries", IntColumn}, {"tzBytes", IntColumn}})
+
+	tracker := shell.StartTask("State sizes", len(tzGraph.Nodes))
+	defer tracker.Finish()
+
+	// Conduct measurements on a copy of the graph
+	bgpGraph := bgpOriginal.Copy().(*bgp.Graph)
+
+	allDestinations := make(map[int]bool)
+	for asn := range bgpGraph.Nodes {
+		allDestinations[asn] = true
+	}
+
+	bgpGraph.SetDestinations(allDestinations)
+	bgpGraph.Evolve()
+
+	var bgpSizes, tzSizes stats.Sample
+
+	for asn, nd := range tzGraph.Nodes {
+		speaker := bgpGraph.Speakers[asn]
+		bgpEntries := speaker.StateEntries()
+		bgpBytes := bgpEntries*BGPRouteBytes + speaker.PathHops()*ASNBytes
+		bunchEntries, witnessEntries := tzGraph.StateEntries(asn)
+		tzBytes := bunchEntries*BunchEntryBytes + witnessEntries*WitnessEntryBytes
+
+		bgpSizes.Add(float64(bgpBytes))
+		tzSizes.Add(float64(tzBytes))
+
+		tracker.Step()
+
+		recorder.record(
+			u.Str(asn),
+			u.Str(len(nd.Links)),
+			u.Str(bgpEntries),
+			u.Str(bgpBytes),
+			u.Str(bunchEntries),
+			u.Str(witnessEntries),
+			u.Str(tzBytes),
+		)
+	}
+
+	result := newResult("state-size")
+	result.addSample("bgpBytes", &bgpSizes)
+	result.addSample("tzBytes", &tzSizes)
+	if bgpSizes.Len() > 0 && bgpSizes.Mean() > 0 {
+		result.Values["tzToBgpRatio"] = tzSizes.Mean() / bgpSizes.Mean()
+	}
+
+	recorder.end(&result)
+
+	return result
+}
@@ -0,0 +1,36 @@
+package audit
+
+import (
+	"testing"
+
+	"dedis.epfl.ch/bgp"
+	"dedis.epfl.ch/tz"
+	"dedis.epfl.ch/u"
+)
+
+func TestMeasureStateSize(t *testing.T) {
+	baseline, audited := loadTestGraphs(t)
+	tzGraph := audited.(*tz.Graph)
+
+	sink := &MemorySink{}
+	MeasureStateSize(NewRecorder(sink), baseline.(*bgp.Graph), tzGraph)
+
+	if len(sink.Rows) != len(tzGraph.Nodes) {
+		t.Fatalf("%d rows recorded", len(sink.Rows))
+	}
+
+	for _, row := range sink.Rows {
+		asn, bgpEntries, bgpBytes := u.Int(row[0]), u.Int(row[2]), u.Int(row[3])
+		bunchEntries, witnessEntries, tzBytes := u.Int(row[4]), u.Int(row[5]), u.Int(row[6])
+
+		// The AS paths add ASNBytes per hop to the routes
+		if pathBytes := bgpBytes - bgpEntries*BGPRouteBytes; bgpEntries == 0 || pathBytes < 0 || pathBytes%ASNBytes != 0 {
+			t.Errorf("%d: %d BGP routes in %d bytes", asn, bgpEntries, bgpBytes)
+		}
+
+		// The level-1 witness of every AS is 3
+		if witnessEntries != 1 || bunchEntries != len(tzGraph.Bunches[asn]) || tzBytes != bunchEntries*BunchEntryBytes+WitnessEntryBytes {
+			t.Errorf("%d: %d bunch and %d witness entries in %d bytes", asn, bunchEntries, witnessEntries, tzBytes)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package stats
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// BootstrapResamples is the number of resamples used to estimate confidence intervals
+var BootstrapResamples = 1000
+
+// BootstrapMaxValues is the size of the largest sample whose mean CI is bootstrapped:
+// every resample draws all the observations, which is too slow for larger samples
+// (e.g. exhaustive stretches), whose mean is approximately normal
+var BootstrapMaxValues = 100000
+
+// BootstrapSeed seeds the resampling of the summaries, so that they are reproducible
+var BootstrapSeed int64 = 1
+
+// Confidence is the level of the confidence intervals
+var Confidence = 0.95
+
+// HistogramBins is the number of bins of the histograms in summaries
+var HistogramBins = 20
+
+// Percentiles reported in summaries
+var Percentiles = []float64{50, 90, 95, 99}
+
+// Interval is a confidence interval
+type Interval struct {
+	Low  float64 `json:"low"`
+	High float64 `json:"high"`
+}
+
+// Bin counts the values in [Low, High) (the last bin includes High)
+type Bin struct {
+	Low   float64 `json:"low"`
+	High  float64 `json:"high"`
+	Count int     `json:"count"`
+}
+
+// Point of a CCDF: Fraction of the values are greater or equal to Value
+type Point struct {
+	Value    float64 `json:"value"`
+	Fraction float64 `json:"fraction"`
+}
+
+// Sample collects the observations of a metric (the zero value is an empty sample)
+type Sample struct {
+	values []float64
+	sorted bool
+}
+
+// Add appends observations to the sample
+func (s *Sample) Add(values ...float64) {
+	s.values = append(s.values, values...)
+	s.sorted = len(s.values) < 2
+}
+
+// Len returns the number of observations
+func (s *Sample) Len() int {
+	return len(s.values)
+}
+
+// Values returns the observations in increasing order
+func (s *Sample) Values() []float64 {
+	if !s.sorted {
+		sort.Float64s(s.values)
+		s.sorted = true
+	}
+	return s.values
+}
+
+// Sum returns the sum of the observations
+func (s *Sample) Sum() float64 {
+	var sum float64
+	for _, v := range s.values {
+		sum += v
+	}
+	return sum
+}
+
+// Mean returns the average of the observations (0 if the sample is empty)
+func (s *Sample) Mean() float64 {
+	return Mean(s.values)
+}
+
+// Min returns the smallest observation (0 if the sample is empty)
+func (s *Sample) Min() float64 {
+	if len(s.values) == 0 {
+		return 0
+	}
+	return s.Values()[0]
+}
+
+// Max returns the largest observation (0 if the sample is empty)
+func (s *Sample) Max() float64 {
+	if len(s.values) == 0 {
+		return 0
+	}
+	return s.Values()[len(s.values)-1]
+}
+
+// Median returns the 50th percentile
+func (s *Sample) Median() float64 {
+	return s.Percentile(50)
+}
+
+// Percentile returns the p-th percentile (nearest rank) of the observations
+func (s *Sample) Percentile(p float64) float64 {
+	if len(s.values) == 0 {
+		return 0
+	}
+	return percentile(s.Values(), p)
+}
+
+// StdDev returns the sample standard deviation
+func (s *Sample) StdDev() float64 {
+	n := float64(len(s.values))
+	if n < 2 {
+		return 0
+	}
+
+	mean := s.Mean()
+
+	var squares float64
+	for _, v := range s.values {
+		squares += (v - mean) * (v - mean)
+	}
+
+	return math.Sqrt(squares / (n - 1))
+}
+
+// BootstrapCI estimates the confidence interval of a statistic by resampling the observations
+// (drawn from rng, in increasing order of the observations, so that the interval does not
+// depend on the order in which they were added)
+func (s *Sample) BootstrapCI(statistic func([]float64) float64, rng *rand.Rand) Interval {
+	if len(s.values) == 0 {
+		return Interval{}
+	}
+
+	values := s.Values()
+	estimates := make([]float64, BootstrapResamples)
+	resample := make([]float64, len(values))
+
+	for r := range estimates {
+		for idx := range resample {
+			resample[idx] = values[rng.Intn(len(values))]
+		}
+		estimates[r] = statistic(resample)
+	}
+
+	sort.Float64s(estimates)
+	tail := (1 - Confidence) / 2 * 100
+
+	return Interval{Low: percentile(estimates, tail), High: percentile(estimates, 100-tail)}
+}
+
+// MeanCI returns the confidence interval of the mean: bootstrapped (resampled from
+// BootstrapSeed) up to BootstrapMaxValues observations, from the normal approximation above
+func (s *Sample) MeanCI() Interval {
+	if len(s.values) <= BootstrapMaxValues {
+		return s.BootstrapCI(Mean, rand.New(rand.NewSource(BootstrapSeed)))
+	}
+
+	mean := s.Mean()
+	spread := normalQuantile(Confidence) * s.StdDev() / math.Sqrt(float64(len(s.values)))
+
+	return Interval{Low: mean - spread, High: mean + spread}
+}
+
+// PercentileCI returns the distribution-free confidence interval of the p-th percentile,
+// whose rank is approximately normal with variance n*p*(1-p)
+func (s *Sample) PercentileCI(p float64) Interval {
+	if len(s.values) == 0 {
+		return Interval{}
+	}
+
+	n := float64(len(s.values))
+	q := p / 100
+	spread := normalQuantile(Confidence) * math.Sqrt(n*q*(1-q))
+
+	return Interval{
+		Low:  percentile(s.Values(), math.Max(0, (n*q-spread)/n*100)),
+		High: percentile(s.Values(), math.Min(100, (n*q+spread)/n*100)),
+	}
+}
+
+// Histogram splits the range of the observations in 'bins' bins of equal width
+func (s *Sample) Histogram(bins int) []Bin {
+	if len(s.values) == 0 || bins < 1 {
+		return []Bin{}
+	}
+
+	low, high := s.Min(), s.Max()
+	if low == high {
+		return []Bin{{Low: low, High: high, Count: len(s.values)}}
+	}
+
+	width := (high - low) / float64(bins)
+	histogram := make([]Bin, bins)
+	for idx := range histogram {
+		histogram[idx].Low = low + float64(idx)*width
+		histogram[idx].High = low + float64(idx+1)*width
+	}
+	histogram[bins-1].High = high
+
+	for _, v := range s.values {
+		idx := int((v - low) / width)
+		if idx >= bins {
+			idx = bins - 1
+		}
+		histogram[idx].Count++
+	}
+
+	return histogram
+}
+
+// CCDF returns, for each distinct observation, the fraction of observations that are
+// greater or equal to it
+func (s *Sample) CCDF() []Point {
+	values := s.Values()
+	n := float64(len(values))
+
+	ccdf := make([]Point, 0)
+	for idx, v := range values {
+		if idx == 0 || v != values[idx-1] {
+			ccdf = append(ccdf, Point{Value: v, Fraction: (n - float64(idx)) / n})
+		}
+	}
+
+	return ccdf
+}
+
+// Summary describes the distribution of a sample
+type Summary struct {
+	Count         int                 `json:"count"`
+	Mean          float64             `json:"mean"`
+	MeanCI        Interval            `json:"meanCI"`
+	StdDev        float64             `json:"stdDev"`
+	Min           float64             `json:"min"`
+	Median        float64             `json:"median"`
+	Max           float64             `json:"max"`
+	Percentiles   map[string]float64  `json:"percentiles"`
+	PercentileCIs map[string]Interval `json:"percentileCIs"`
+	Histogram     []Bin               `json:"histogram"`
+}
+
+// Summarize computes the summary of the sample (see MeanCI for the CI of the mean)
+func (s *Sample) Summarize() Summary {
+	summary := Summary{
+		Count:         len(s.values),
+		Mean:          s.Mean(),
+		MeanCI:        s.MeanCI(),
+		StdDev:        s.StdDev(),
+		Min:           s.Min(),
+		Median:        s.Median(),
+		Max:           s.Max(),
+		Percentiles:   make(map[string]float64, len(Percentiles)),
+		PercentileCIs: make(map[string]Interval, len(Percentiles)),
+		Histogram:     s.Histogram(HistogramBins),
+	}
+
+	for _, p := range Percentiles {
+		summary.Percentiles[PercentileKey(p)] = s.Percentile(p)
+		summary.PercentileCIs[PercentileKey(p)] = s.PercentileCI(p)
+	}
+
+	return summary
+}
+
+// Percentile returns the p-th percentile of the summarized sample
+// (only the ones listed in Percentiles are available)
+func (summary Summary) Percentile(p float64) float64 {
+	return summary.Percentiles[PercentileKey(p)]
+}
+
+func (summary Summary) String() string {
+	return fmt.Sprintf("%d samples, mean %f [%f, %f], median %f, 95th percentile %f, max %f",
+		summary.Count, summary.Mean, summary.MeanCI.Low, summary.MeanCI.High, summary.Median, summary.Percentile(95), summary.Max)
+}
+
+// PercentileKey is the name of the p-th percentile in summaries (e.g. "p95")
+func PercentileKey(p float64) string {
+	return "p" + fmt.Sprint(p)
+}
+
+// Mean returns the average of the values (0 if there are none)
+func Mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+
+	return sum / float64(len(values))
+}
+
+// percentile returns the p-th percentile (nearest rank) of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// normalQuantile returns z such that a standard normal variable falls in [-z, z]
+// with the given probability
+func normalQuantile(confidence float64) float64 {
+	return math.Sqrt2 * math.Erfinv(confidence)
+}
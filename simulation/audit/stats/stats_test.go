@@ -0,0 +1,141 @@
+package stats
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// sequence returns a sample of the values from 1 to n, shuffled
+func sequence(n int) *Sample {
+	var s Sample
+	for _, idx := range rand.New(rand.NewSource(1)).Perm(n) {
+		s.Add(float64(idx + 1))
+	}
+	return &s
+}
+
+func TestPercentile(t *testing.T) {
+	s := sequence(100)
+
+	cases := []struct {
+		p        float64
+		expected float64
+	}{
+		{0, 1},
+		{1, 1},
+		{50, 50},
+		{90, 90},
+		{99, 99},
+		{99.5, 100},
+		{100, 100},
+	}
+
+	for _, c := range cases {
+		if value := s.Percentile(c.p); value != c.expected {
+			t.Errorf("p%v: %v, expected %v", c.p, value, c.expected)
+		}
+	}
+
+	var empty Sample
+	if value := empty.Percentile(50); value != 0 {
+		t.Errorf("median of an empty sample: %v", value)
+	}
+}
+
+func TestPercentileCI(t *testing.T) {
+	s := sequence(100)
+
+	// The rank of the median is 50 +- 1.96 * 5
+	cases := []struct {
+		p        float64
+		expected Interval
+	}{
+		{50, Interval{Low: 41, High: 60}},
+		{90, Interval{Low: 85, High: 96}},
+		{99, Interval{Low: 98, High: 100}},
+	}
+
+	for _, c := range cases {
+		if ci := s.PercentileCI(c.p); ci != c.expected {
+			t.Errorf("p%v: %+v, expected %+v", c.p, ci, c.expected)
+		}
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	cases := []struct {
+		values   []float64
+		bins     int
+		expected []Bin
+	}{
+		{[]float64{}, 3, []Bin{}},
+		{[]float64{2, 2, 2}, 3, []Bin{{Low: 2, High: 2, Count: 3}}},
+		{[]float64{0, 1, 2, 3, 4}, 2, []Bin{{Low: 0, High: 2, Count: 2}, {Low: 2, High: 4, Count: 3}}},
+		{[]float64{4, 0, 0, 1}, 4, []Bin{{Low: 0, High: 1, Count: 2}, {Low: 1, High: 2, Count: 1}, {Low: 2, High: 3}, {Low: 3, High: 4, Count: 1}}},
+	}
+
+	for _, c := range cases {
+		var s Sample
+		s.Add(c.values...)
+		if histogram := s.Histogram(c.bins); !reflect.DeepEqual(histogram, c.expected) {
+			t.Errorf("%v in %d bins: %+v, expected %+v", c.values, c.bins, histogram, c.expected)
+		}
+	}
+}
+
+func TestCCDF(t *testing.T) {
+	var s Sample
+	s.Add(3, 1, 2, 1)
+
+	expected := []Point{{Value: 1, Fraction: 1}, {Value: 2, Fraction: 0.5}, {Value: 3, Fraction: 0.25}}
+	if ccdf := s.CCDF(); !reflect.DeepEqual(ccdf, expected) {
+		t.Errorf("%+v, expected %+v", ccdf, expected)
+	}
+}
+
+func TestBootstrapCI(t *testing.T) {
+	var constant Sample
+	constant.Add(4, 4, 4)
+	if ci := constant.BootstrapCI(Mean, rand.New(rand.NewSource(1))); ci != (Interval{Low: 4, High: 4}) {
+		t.Errorf("CI of a constant sample: %+v", ci)
+	}
+
+	// The standard error of the mean of 1..100 is about 2.9
+	s := sequence(100)
+	ci := s.BootstrapCI(Mean, rand.New(rand.NewSource(1)))
+	if ci.Low < 44 || ci.Low > 50 || ci.High < 51 || ci.High > 57 {
+		t.Errorf("CI of the mean of 1..100: %+v", ci)
+	}
+	if again := s.BootstrapCI(Mean, rand.New(rand.NewSource(1))); again != ci {
+		t.Errorf("CI resampled with the same seed: %+v, then %+v", ci, again)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	s := sequence(100)
+	summary := s.Summarize()
+
+	if summary.Count != 100 || summary.Mean != 50.5 || summary.Min != 1 || summary.Max != 100 || summary.Median != 50 {
+		t.Errorf("summary of 1..100: %v", summary)
+	}
+	if summary.Percentile(95) != 95 || summary.PercentileCIs[PercentileKey(50)] != s.PercentileCI(50) {
+		t.Errorf("percentiles of 1..100: %v %v", summary.Percentiles, summary.PercentileCIs)
+	}
+	if summary.MeanCI != s.BootstrapCI(Mean, rand.New(rand.NewSource(BootstrapSeed))) {
+		t.Errorf("CI of the mean is not bootstrapped: %+v", summary.MeanCI)
+	}
+}
+
+func TestMeanCIOfLargeSamples(t *testing.T) {
+	defer func(max int) { BootstrapMaxValues = max }(BootstrapMaxValues)
+	BootstrapMaxValues = 10
+
+	s := sequence(100)
+	spread := 1.959963984540054 * s.StdDev() / 10
+
+	if ci := s.MeanCI(); math.Abs(ci.Low-(50.5-spread)) > 1e-9 || math.Abs(ci.High-(50.5+spread)) > 1e-9 {
+		t.Errorf("normal CI of the mean of 1..100: %+v, expected %v +- %v", ci, 50.5, spread)
+	}
+}
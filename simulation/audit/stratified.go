@@ -0,0 +1,196 @@
+package audit
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"dedis.epfl.ch/audit/stats"
+	. "dedis.epfl.ch/core"
+	"dedis.epfl.ch/tz"
+	"dedis.epfl.ch/u"
+)
+
+// DegreeStratum groups ASes by degree bucket (1, 2-3, 4-7, 8-15, ...)
+const DegreeStratum int = 0
+
+// TierStratum groups ASes by tier (1: no providers, 3: no customers, 2: otherwise)
+const TierStratum int = 1
+
+// LandmarkStratum groups ASes by highest landmark level (0: not a landmark)
+const LandmarkStratum int = 2
+
+// classifyNodes assigns each AS to its class according to the criterion
+// Landmark levels are taken from the audited graph (if it's a tz.Graph)
+func classifyNodes(audited AbstractGraph, criterion int) map[int]int {
+	nodes := *audited.GetNodes()
+	classes := make(map[int]int, len(nodes))
+
+	switch criterion {
+	case DegreeStratum:
+		for asn, nd := range nodes {
+			bucket := 0
+			for degree := len(nd.Links); degree > 1; degree /= 2 {
+				bucket++
+			}
+			classes[asn] = bucket
+		}
+
+	case TierStratum:
+		for asn, nd := range nodes {
+			hasProviders, hasCustomers := false, false
+			for _, linkType := range nd.Type {
+				hasProviders = hasProviders || linkType == ToProvider
+				hasCustomers = hasCustomers || linkType == ToCustomer
+			}
+
+			switch {
+			case !hasProviders:
+				classes[asn] = 1
+			case !hasCustomers:
+				classes[asn] = 3
+			default:
+				classes[asn] = 2
+			}
+		}
+
+	case LandmarkStratum:
+		for asn := range nodes {
+			classes[asn] = 0
+		}
+		if tzAudited, isTz := audited.(*tz.Graph); isTz {
+			for level := 1; level < tzAudited.K; level++ {
+				for nd := range tzAudited.Landmarks[level] {
+					classes[nd.Asn] = level
+				}
+			}
+		}
+
+	default:
+		panic("Invalid stratification criterion")
+	}
+
+	return classes
+}
+
+// MeasureStratifiedStretch samples 'samples' pairs for every combination of
+// origin and destination classes
+// If recording is active, each pair is saved to file along with its stratum
+// returns the "<origin class>-><destination class>" stretch metrics,
+// with the CCDF of each stratum as details
+func MeasureStratifiedStretch(recorder *Recorder, baseline AbstractGraph, audited AbstractGraph, criterion int, samples int) Result {
+
+	recorder.start("stratified-stretch", map[string]interface{}{"criterion": criterion, "samples": samples}, []Column{{"originClass", IntColumn}, {"destinationClass", IntColumn}, {"origin", IntColumn}, {"destination", IntColumn}, {"stretch", FloatColumn}})
+
+	recorder.seedRandom()
+
+	classes := classifyNodes(audited, criterion)
+
+	members := make(map[int][]int)
+	for asn, class := range classes {
+		members[class] = append(members[class], asn)
+	}
+	for _, asns := range members {
+		// Sorted, to make sampling reproducible for a given seed
+		sort.Ints(asns)
+	}
+
+	pairs := make([][2]int, 0)
+	for orClass, origins := range members {
+		for dsClass, destinations := range members {
+			if orClass == dsClass && len(origins) < 2 {
+				continue
+			}
+
+			for s := 0; s < samples; {
+				or := origins[rand.Intn(len(origins))]
+				ds := destinations[rand.Intn(len(destinations))]
+				if or != ds {
+					pairs = append(pairs, [2]int{or, ds})
+					s++
+				}
+			}
+		}
+	}
+
+	stretches := make(map[string]*stats.Sample)
+	pairValues, routed := pairStretches(baseline, audited, pairs)
+	for idx, pair := range pairs {
+		if !routed[idx] {
+			continue
+		}
+
+		sampleStretch := pairValues[idx]
+		stratum := u.Str(classes[pair[0]]) + "->" + u.Str(classes[pair[1]])
+		if _, exists := stretches[stratum]; !exists {
+			stretches[stratum] = &stats.Sample{}
+		}
+		stretches[stratum].Add(sampleStretch)
+
+		recorder.record(
+			u.Str(classes[pair[0]]),
+			u.Str(classes[pair[1]]),
+			u.Str(pair[0]),
+			u.Str(pair[1]),
+			fmt.Sprintf("%f", sampleStretch),
+		)
+	}
+
+	result := newResult("stratified-stretch")
+	ccdfs := make(map[string][]stats.Point, len(stretches))
+	for stratum, sample := range stretches {
+		result.addSample(stratum, sample)
+		ccdfs[stratum] = sample.CCDF()
+	}
+	result.Details = ccdfs
+
+	recorder.end(&result)
+
+	return result
+}
+
+// MeasureExhaustiveStretch computes the stretch of every pair of distinct ASes
+// It is meant for small graphs: routes are computed a batch of 'batchSize' (>= 1)
+// destinations at a time
+// If recording is active, each pair is saved to file
+// returns the "stretch" metric, with its CCDF as details
+func MeasureExhaustiveStretch(recorder *Recorder, baseline AbstractGraph, audited AbstractGraph, batchSize int) Result {
+	recorder.start("exhaustive-stretch", map[string]interface{}{"batchSize": batchSize}, []Column{{"origin", IntColumn}, {"destination", IntColumn}, {"stretch", FloatColumn}})
+
+	var stretch stats.Sample
+
+	batchesTowards(baseline, batchSize, "Exhaustive stretch destinations", func(pairs [][2]int) {
+		pairValues, routed := pairStretches(baseline, audited, pairs)
+		for idx, pair := range pairs {
+			if routed[idx] {
+				stretch.Add(pairValues[idx])
+				recorder.record(u.Str(pair[0]), u.Str(pair[1]), fmt.Sprintf("%f", pairValues[idx]))
+			}
+		}
+	})
+
+	result := newResult("exhaustive-stretch")
+	result.addSample("stretch", &stretch)
+	result.Details = stretch.CCDF()
+
+	recorder.end(&result)
+
+	return result
+}
+
+// pairStretches returns the stretch of every pair, and whether it can be routed on both graphs
+func pairStretches(baseline AbstractGraph, audited AbstractGraph, pairs [][2]int) ([]float64, []bool) {
+	baseRoutes, _ := routePairs(baseline, pairs)
+	auditRoutes, _ := routePairs(audited, pairs)
+
+	stretches := make([]float64, len(pairs))
+	routed := make([]bool, len(pairs))
+	for idx := range pairs {
+		if baseRoutes[idx] != nil && auditRoutes[idx] != nil {
+			stretches[idx] = float64(len(auditRoutes[idx])-1) / float64(len(baseRoutes[idx])-1)
+			routed[idx] = true
+		}
+	}
+
+	return stretches, routed
+}
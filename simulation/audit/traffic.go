@@ -0,0 +1,214 @@
+package audit
+
+import (
+	"encoding/csv"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+
+	"dedis.epfl.ch/audit/stats"
+	. "dedis.epfl.ch/core"
+	"dedis.epfl.ch/u"
+)
+
+// TrafficMatrix draws origin/destination pairs proportionally to their traffic
+// The matrix is either explicit (a list of weighted pairs) or a gravity model,
+// where the traffic between two ASes is proportional to the product of their masses
+type TrafficMatrix struct {
+	pairs [][2]int
+	asns  []int
+	// cumulative weights of pairs (explicit) or asns (gravity)
+	cumulative []float64
+}
+
+// LoadTrafficMatrix reads an explicit traffic matrix from a csv file
+// with rows (origin, destination, weight)
+func LoadTrafficMatrix(filename string) *TrafficMatrix {
+
+	csvFile, err := os.Open(filename)
+	if err != nil {
+		panic("Unable to load the traffic matrix")
+	}
+	defer csvFile.Close()
+
+	reader := csv.NewReader(csvFile)
+	// Rows of another length are reported (with their line) by Read
+	reader.FieldsPerRecord = 3
+
+	matrix := TrafficMatrix{
+		pairs:      make([][2]int, 0, 64),
+		cumulative: make([]float64, 0, 64),
+	}
+
+	var total float64
+
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			panic("Unable to read the traffic matrix: " + err.Error())
+		}
+
+		weight, err := strconv.ParseFloat(row[2], 64)
+		if err != nil || weight < 0 {
+			panic("Invalid weight in the traffic matrix: " + row[2])
+		}
+
+		if weight > 0 && row[0] != row[1] {
+			total += weight
+			matrix.pairs = append(matrix.pairs, [2]int{u.Int(row[0]), u.Int(row[1])})
+			matrix.cumulative = append(matrix.cumulative, total)
+		}
+	}
+
+	return &matrix
+}
+
+// GravityTrafficMatrix returns a gravity model whose masses are the customer cone
+// sizes of the ASes (the AS itself and all the ASes reachable through customer links)
+func GravityTrafficMatrix(graph AbstractGraph) *TrafficMatrix {
+	cones := CustomerConeSizes(graph)
+
+	matrix := TrafficMatrix{
+		asns:       make([]int, 0, len(cones)),
+		cumulative: make([]float64, 0, len(cones)),
+	}
+
+	for asn := range cones {
+		matrix.asns = append(matrix.asns, asn)
+	}
+	sort.Ints(matrix.asns)
+
+	var total float64
+	for _, asn := range matrix.asns {
+		total += float64(cones[asn])
+		matrix.cumulative = append(matrix.cumulative, total)
+	}
+
+	return &matrix
+}
+
+// CustomerConeSizes returns the size of the customer cone of every AS
+func CustomerConeSizes(graph AbstractGraph) map[int]int {
+	nodes := *graph.GetNodes()
+
+	sizes := make(map[int]int)
+
+	for asn := range nodes {
+		cone := map[int]bool{asn: true}
+		toVisit := []int{asn}
+		for len(toVisit) > 0 {
+			a := toVisit[len(toVisit)-1]
+			toVisit = toVisit[:len(toVisit)-1]
+			for idx, l := range nodes[a].Links {
+				if nodes[a].Type[idx] == ToCustomer && !cone[l] {
+					cone[l] = true
+					toVisit = append(toVisit, l)
+				}
+			}
+		}
+		sizes[asn] = len(cone)
+	}
+
+	return sizes
+}
+
+// pick returns the index of the element whose cumulative weight includes a random point
+func (t *TrafficMatrix) pick() int {
+	point := rand.Float64() * t.cumulative[len(t.cumulative)-1]
+	return sort.Search(len(t.cumulative), func(i int) bool { return t.cumulative[i] > point })
+}
+
+// SamplePairs draws n pairs of distinct ASes (none of them in 'excluded')
+func (t *TrafficMatrix) SamplePairs(n int, excluded map[int]bool) [][2]int {
+	pairs := make([][2]int, 0, n)
+
+	for len(pairs) < n {
+		var pair [2]int
+		if t.pairs != nil {
+			pair = t.pairs[t.pick()]
+		} else {
+			pair = [2]int{t.asns[t.pick()], t.asns[t.pick()]}
+		}
+
+		if pair[0] != pair[1] && !excluded[pair[0]] && !excluded[pair[1]] {
+			pairs = append(pairs, pair)
+		}
+	}
+
+	return pairs
+}
+
+// MeasureWeightedStretch measures the stretch over 'samples' pairs drawn from the traffic
+// matrix, and over as many uniform random pairs
+// If recording is active, each pair is saved to file (with 1 if it was drawn from the matrix)
+// returns the "weightedStretch" and "uniformStretch" metrics
+func MeasureWeightedStretch(recorder *Recorder, baseline AbstractGraph, audited AbstractGraph, matrix *TrafficMatrix, samples int) Result {
+
+	recorder.start("weighted-stretch", map[string]interface{}{"samples": samples}, []Column{{"weighted", IntColumn}, {"origin", IntColumn}, {"destination", IntColumn}, {"baseLength", IntColumn}, {"auditLength", IntColumn}})
+
+	recorder.seedRandom()
+
+	nodes := *baseline.GetNodes()
+
+	// Pairs involving unknown ASes are not drawn
+	unknown := make(map[int]bool)
+	drawable := matrix.pairs == nil
+	for _, pair := range matrix.pairs {
+		_, knownOrigin := nodes[pair[0]]
+		_, knownDestination := nodes[pair[1]]
+		if !knownOrigin {
+			unknown[pair[0]] = true
+		}
+		if !knownDestination {
+			unknown[pair[1]] = true
+		}
+		drawable = drawable || (knownOrigin && knownDestination)
+	}
+
+	if !drawable {
+		panic("The traffic matrix has no pair of known ASes")
+	}
+
+	weighted := pairsStretch(recorder, baseline, audited, matrix.SamplePairs(samples, unknown), 1)
+	uniform := pairsStretch(recorder, baseline, audited, randomPairs(baseline, samples, map[int]bool{}), 0)
+
+	result := newResult("weighted-stretch")
+	result.addSample("weightedStretch", weighted)
+	result.addSample("uniformStretch", uniform)
+
+	recorder.end(&result)
+
+	return result
+}
+
+// pairsStretch returns the stretch of the pairs (pairs without a route are ignored)
+// each pair is recorded along with 'weighted'
+func pairsStretch(recorder *Recorder, baseline AbstractGraph, audited AbstractGraph, pairs [][2]int, weighted int) *stats.Sample {
+	baseRoutes, _ := routePairs(baseline, pairs)
+	auditRoutes, _ := routePairs(audited, pairs)
+
+	var stretch stats.Sample
+
+	for idx, pair := range pairs {
+		if baseRoutes[idx] == nil || auditRoutes[idx] == nil {
+			continue
+		}
+
+		stretch.Add(float64(len(auditRoutes[idx])-1) / float64(len(baseRoutes[idx])-1))
+
+		recorder.record(
+			u.Str(weighted),
+			u.Str(pair[0]),
+			u.Str(pair[1]),
+			u.Str(len(baseRoutes[idx])-1),
+			u.Str(len(auditRoutes[idx])-1),
+		)
+	}
+
+	return &stretch
+}
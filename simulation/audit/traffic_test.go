@@ -0,0 +1,25 @@
+package audit
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLoadTrafficMatrixReportsShortRows(t *testing.T) {
+	file, err := ioutil.TempFile("", "traffic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("1,2,0.5\n3,4\n")
+	file.Close()
+
+	defer func() {
+		if message, _ := recover().(string); !strings.Contains(message, "line 2") {
+			t.Errorf("short row reported as %q", message)
+		}
+	}()
+	LoadTrafficMatrix(file.Name())
+}
@@ -0,0 +1,97 @@
+package bgp
+
+import (
+	"dedis.epfl.ch/actor"
+)
+
+// advertisement is the payload of the messages exchanged by speakerActors
+type advertisement struct {
+	destination int
+	length      int
+}
+
+// speakerActor processes the advertisements received by a single Speaker
+type speakerActor struct {
+	g   *Graph
+	asn int
+}
+
+// propagate advertises the fresh routes of a speaker to its neighbors,
+// following the same rules of Activate
+func (g *Graph) propagate(asn int, send func(actor.Message)) {
+	nd := g.Nodes[asn]
+	sp := g.Speakers[asn]
+
+	for i := 0; i < len(sp.Fresh); i++ {
+		if sp.Fresh[i] {
+			for _, link := range nd.Links {
+				if !sp.heardFrom(i, g.Nodes[link]) && nd.CanTellAbout(sp.NextHop[i], g.Nodes[link]) {
+					send(actor.Message{
+						From:    asn,
+						To:      link,
+						Payload: advertisement{destination: sp.Destinations[i].Asn, length: sp.Length[i]},
+					})
+				}
+			}
+			sp.Fresh[i] = false
+		}
+	}
+}
+
+// Receive implements actor.Actor
+func (a *speakerActor) Receive(msg actor.Message, send func(actor.Message)) {
+	adv := msg.Payload.(advertisement)
+
+	nd := a.g.Nodes[a.asn]
+	if a.g.Speakers[a.asn].advertise(nd, a.g.Nodes[adv.destination], a.g.Nodes[msg.From], adv.length) {
+		a.g.propagate(a.asn, send)
+	}
+}
+
+// EvolveConcurrently brings the graph to a stable state, running each speaker
+// in its own goroutine and exchanging advertisements asynchronously
+// returns the statistics of the exchanged messages
+func (g *Graph) EvolveConcurrently(config actor.Config) actor.Stats {
+	actors := make(map[int]actor.Actor)
+	for asn := range g.Nodes {
+		actors[asn] = &speakerActor{g: g, asn: asn}
+	}
+
+	// The unstable speakers start the exchange
+	initial := make([]actor.Message, 0)
+	for nd := range g.unstable {
+		g.propagate(nd.Asn, func(msg actor.Message) { initial = append(initial, msg) })
+		g.setStable(nd)
+	}
+
+	return actor.InitRuntime(actors, config).Run(initial)
+}
+
+// CountRouteDifferences compares the routes of two graphs with the same nodes
+// Routes are equivalent if they have the same length and the same type of next-hop
+// returns the number of routes that are missing or not equivalent
+func (g *Graph) CountRouteDifferences(other *Graph) int {
+	differences := 0
+
+	for asn, sp := range g.Speakers {
+		otherSp := other.Speakers[asn]
+
+		for idx, dest := range sp.Destinations {
+			otherIdx := otherSp.hasRoute(other.Nodes[dest.Asn])
+			if otherIdx < 0 ||
+				sp.Length[idx] != otherSp.Length[otherIdx] ||
+				sp.getNextHopType(g.Nodes[asn], idx) != otherSp.getNextHopType(other.Nodes[asn], otherIdx) {
+				differences++
+			}
+		}
+
+		// Routes known only by the other graph
+		for _, dest := range otherSp.Destinations {
+			if sp.hasRoute(g.Nodes[dest.Asn]) < 0 {
+				differences++
+			}
+		}
+	}
+
+	return differences
+}
@@ -0,0 +1,65 @@
+package bgp
+
+import (
+	"testing"
+
+	"dedis.epfl.ch/actor"
+)
+
+// loadTestGraph loads data/test.csv, with every AS as a destination
+func loadTestGraph(t *testing.T) *Graph {
+	graph := InitGraph()
+	if err := LoadFromCsv(&graph, "../data/test.csv"); err != nil {
+		t.Fatal(err)
+	}
+
+	destinations := make(map[int]bool)
+	for asn := range graph.Nodes {
+		destinations[asn] = true
+	}
+	graph.SetDestinations(destinations)
+
+	return &graph
+}
+
+func TestEvolveConcurrentlyMatchesEvolve(t *testing.T) {
+	synchronous := loadTestGraph(t)
+	synchronous.Evolve()
+
+	configs := []actor.Config{
+		{Seed: 1},
+		{ReorderRate: 0.5, Seed: 2},
+		{LossRate: 0.3, Retransmit: true, RetransmitDelay: 5, Seed: 3},
+		{LossRate: 0.3, Retransmit: true, RetransmitDelay: 5, ReorderRate: 0.5, Seed: 4},
+		{LossRate: 0.5, Retransmit: true, RetransmitDelay: 20, Seed: 5},
+	}
+
+	for _, config := range configs {
+		concurrent := loadTestGraph(t)
+		stats := concurrent.EvolveConcurrently(config)
+		if config.Retransmit && stats.Retransmitted == 0 {
+			t.Errorf("%+v: no message retransmitted", config)
+		}
+
+		if differences := synchronous.CountRouteDifferences(concurrent); differences != 0 {
+			t.Errorf("%+v: %d routes differ", config, differences)
+		}
+	}
+}
+
+func TestCountRouteDifferences(t *testing.T) {
+	graph := loadTestGraph(t)
+	graph.Evolve()
+
+	other := loadTestGraph(t)
+	other.Evolve()
+	other.DeleteDestination(1)
+
+	// Every AS lost its route to 1
+	if differences := graph.CountRouteDifferences(other); differences != len(graph.Nodes) {
+		t.Errorf("%d routes differ, expected %d", differences, len(graph.Nodes))
+	}
+	if differences := other.CountRouteDifferences(graph); differences != len(graph.Nodes) {
+		t.Errorf("%d routes differ (reversed), expected %d", differences, len(graph.Nodes))
+	}
+}
@@ -13,6 +13,8 @@ type Graph struct {
 	Speakers  map[int]*Speaker
 	unstable  map[*Node]bool
 	remaining int
+	// journal is nil outside transactions
+	journal *LinkJournal
 }
 
 func InitGraph() Graph {
@@ -155,6 +157,9 @@ func (g *Graph) RemoveEdge(aAsn int, bAsn int) (bool, map[int]bool, *TapeMeasure
 		return false, nil, nil
 	}
 
+	g.journal.Save(a)
+	g.journal.Save(b)
+
 	if !(a.DeleteLink(b) && b.DeleteLink(a)) {
 		panic("Link deletion unsuccessful! Corrupted graph")
 	}
@@ -163,6 +168,71 @@ func (g *Graph) RemoveEdge(aAsn int, bAsn int) (bool, map[int]bool, *TapeMeasure
 	return true, nil, nil
 }
 
+// AddEdge inserts a link of type 'linkType' (as seen by a) between a and b
+// returns false if one of the ASes does not exist or the link is already there
+func (g *Graph) AddEdge(aAsn int, bAsn int, linkType int) (bool, map[int]bool, *TapeMeasure) {
+
+	a, aOk := g.Nodes[aAsn]
+	b, bOk := g.Nodes[bAsn]
+
+	if !(aOk && bOk) || aAsn == bAsn || a.GetNeighborIndex(b) >= 0 {
+		return false, nil, nil
+	}
+
+	g.journal.Save(a)
+	g.journal.Save(b)
+
+	if !(a.AddLink(b, linkType) && b.AddLink(a, -linkType)) {
+		panic("Link insertion unsuccessful! Corrupted graph")
+	}
+
+	// Impact and TapeMeasure not supported
+	return true, nil, nil
+}
+
+// AddNode inserts an AS without links (nothing happens if the AS exists)
+// The journal only restores links, so AddNode is refused during a transaction
+func (g *Graph) AddNode(asn int) {
+	if g.journal != nil {
+		panic("Cannot add an AS during a transaction")
+	}
+	if _, exists := g.Nodes[asn]; exists {
+		return
+	}
+
+	tempNode := ToNode(asn, Link{}, Rel{})
+	g.Nodes[asn] = &tempNode
+	g.Speakers[asn] = InitSpeaker(&tempNode)
+	g.unstable[&tempNode] = true
+	g.remaining++
+}
+
+// Begin starts a transaction
+// Only the links are journaled: routes learned by the speakers are not restored by Rollback
+func (g *Graph) Begin() {
+	if g.journal != nil {
+		panic("A transaction is already in progress")
+	}
+	g.journal = InitLinkJournal()
+}
+
+// Commit keeps the changes performed since Begin
+func (g *Graph) Commit() {
+	if g.journal == nil {
+		panic("No transaction in progress")
+	}
+	g.journal = nil
+}
+
+// Rollback restores the links as they were when Begin was called
+func (g *Graph) Rollback() {
+	if g.journal == nil {
+		panic("No transaction in progress")
+	}
+	g.journal.Restore(g.Nodes)
+	g.journal = nil
+}
+
 func (g *Graph) printSpeakerStatus(asn int) {
 	if !g.validateAsn(asn) {
 		fmt.Println("INVALID AS number")
@@ -0,0 +1,75 @@
+package bgp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// linkState lists the links of every AS, sorted by ASN
+func linkState(g *Graph) string {
+	asns := make([]int, 0, len(g.Nodes))
+	for asn := range g.Nodes {
+		asns = append(asns, asn)
+	}
+	sort.Ints(asns)
+
+	var state strings.Builder
+	for _, asn := range asns {
+		fmt.Fprintf(&state, "%d:", asn)
+		for i, link := range g.Nodes[asn].Links {
+			fmt.Fprintf(&state, " %d(%d)", link, g.Nodes[asn].Type[i])
+		}
+		state.WriteString("\n")
+	}
+
+	return state.String()
+}
+
+func TestRemoveEdgeRollsBack(t *testing.T) {
+	graph := loadTestGraph(t)
+	before := linkState(graph)
+
+	graph.Begin()
+	if success, _, _ := graph.RemoveEdge(3, 4); !success {
+		t.Fatal("removal of 3-4 refused")
+	}
+	if during := linkState(graph); during == before {
+		t.Fatal("removal of 3-4 did not change the links")
+	}
+	graph.Rollback()
+
+	if after := linkState(graph); after != before {
+		t.Errorf("rollback did not restore the links:\n%s\nexpected:\n%s", after, before)
+	}
+}
+
+func TestAddEdgeRollsBack(t *testing.T) {
+	graph := loadTestGraph(t)
+	before := linkState(graph)
+
+	graph.Begin()
+	if success, _, _ := graph.AddEdge(5, 7, 1); !success {
+		t.Fatal("insertion of 5-7 refused")
+	}
+	if during := linkState(graph); during == before {
+		t.Fatal("insertion of 5-7 did not change the links")
+	}
+	graph.Rollback()
+
+	if after := linkState(graph); after != before {
+		t.Errorf("rollback did not restore the links:\n%s\nexpected:\n%s", after, before)
+	}
+}
+
+func TestAddNodeRefusedDuringTransaction(t *testing.T) {
+	graph := loadTestGraph(t)
+	graph.Begin()
+	defer func() {
+		if recover() == nil {
+			t.Error("AddNode accepted during a transaction")
+		}
+	}()
+	graph.AddNode(8)
+}
@@ -179,6 +179,11 @@ func (g *Graph) TestLink(originAsn int, destinationAsn int) {
 func (g *Graph) Evolve() (stepsToConvergence int) {
 	stepsToConvergence = 0
 
+	// The total grows as activations are queued
+	tracker := StartTask("BGP activations", g.remaining)
+	defer tracker.Finish()
+	activations := 0
+
 	var roundNum int = 0
 	for g.remaining > 0 {
 		//fmt.Printf("Round %d : %d activation queued\n", roundNum, g.remaining)
@@ -186,6 +191,9 @@ func (g *Graph) Evolve() (stepsToConvergence int) {
 		for k := range g.unstable {
 			//sh.Overwrite("	Activating AS#", Green, u.Str(k.Asn), Clear)
 			stepsToConvergence += g.Activate(k.Asn)
+			activations++
+			tracker.SetTotal(activations + g.remaining)
+			tracker.Step()
 		}
 		//fmt.Print("\n")
 		roundNum++
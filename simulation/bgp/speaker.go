@@ -159,3 +159,17 @@ func (s *Speaker) Copy() *Speaker {
 
 	return &copySpeaker
 }
+
+// StateEntries returns the number of routes held by the Speaker
+func (s *Speaker) StateEntries() int {
+	return len(s.Destinations)
+}
+
+// PathHops returns the total length of the AS paths of the routes held by the Speaker
+func (s *Speaker) PathHops() int {
+	hops := 0
+	for _, length := range s.Length {
+		hops += length
+	}
+	return hops
+}
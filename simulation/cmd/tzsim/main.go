@@ -0,0 +1,87 @@
+// Command tzsim runs the experiments described by json specs
+//
+// Usage: tzsim [--resume] [--progress=auto|terminal|log|none] <command> <spec.json> [<spec.json> ...]
+// (see stretch.example.json for the format of specs)
+// With --resume, measurements continue from the checkpoint saved in the
+// "checkpoints" folder of their spec, if any
+// Progress is shown on the current line of the terminal, or logged to the standard
+// error when the output is not a terminal (--progress=auto)
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"dedis.epfl.ch/experiment"
+	"dedis.epfl.ch/shell"
+)
+
+// logInterval is the minimum time between two log lines about the progress of a task
+const logInterval = 30 * time.Second
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: tzsim [--resume] [--progress=auto|terminal|log|none] <command> <spec.json> [<spec.json> ...]")
+	flag.PrintDefaults()
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, name := range experiment.CommandNames() {
+		fmt.Fprintf(os.Stderr, "  %-22s %s\n", name, experiment.Commands[name].Description)
+	}
+}
+
+// setupProgress sets the reporter of shell according to the --progress option
+// It returns false if the option is invalid
+func setupProgress(progress string) bool {
+	if progress == "auto" {
+		progress = "log"
+		if info, err := os.Stdout.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			progress = "terminal"
+		}
+	}
+
+	switch progress {
+	case "terminal":
+		shell.Reporter = shell.NewTerminalReporter()
+	case "log":
+		shell.Reporter = shell.NewLogReporter(log.New(os.Stderr, "", log.LstdFlags), logInterval)
+	case "none":
+		shell.Reporter = nil
+	default:
+		return false
+	}
+	return true
+}
+
+func main() {
+	resume := flag.Bool("resume", false, "continue from the checkpoints of the specs")
+	progress := flag.String("progress", "auto", "how to report progress: auto, terminal, log or none")
+	flag.Usage = usage
+	flag.Parse()
+
+	if !setupProgress(*progress) {
+		fmt.Fprintf(os.Stderr, "Unknown progress option %s\n\n", *progress)
+		usage()
+		os.Exit(2)
+	}
+
+	if flag.NArg() < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	command := flag.Arg(0)
+	if _, exists := experiment.Commands[command]; !exists {
+		fmt.Fprintf(os.Stderr, "Unknown command %s\n\n", command)
+		usage()
+		os.Exit(2)
+	}
+
+	for _, specFile := range flag.Args()[1:] {
+		if _, err := experiment.Run(command, experiment.LoadSpec(specFile), *resume); err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to save the output of %s: %s\n", specFile, err)
+			os.Exit(1)
+		}
+	}
+}
@@ -9,7 +9,14 @@ type AbstractGraph interface {
 	SetDestinations(dest map[int]bool)
 	Evolve() int
 	RemoveEdge(a int, b int) (bool, map[int]bool, *TapeMeasure)
+	// AddEdge inserts a link, where linkType is the type of b as seen by a
+	AddEdge(a int, b int, linkType int) (bool, map[int]bool, *TapeMeasure)
 	Copy() AbstractGraph
+	// Begin starts a transaction: the following mutations are journaled
+	// until Commit (keeping them) or Rollback (undoing them) is called
+	Begin()
+	Commit()
+	Rollback()
 }
 
 // Serializable represents an object that can be transferred to file
@@ -0,0 +1,51 @@
+package core
+
+// LinkJournal stores the links of the nodes touched by a transaction,
+// so that they can be restored on rollback
+type LinkJournal struct {
+	saved map[int]savedLinks
+}
+
+type savedLinks struct {
+	node  *Node
+	links Link
+	types Rel
+}
+
+// InitLinkJournal returns an empty journal
+func InitLinkJournal() *LinkJournal {
+	return &LinkJournal{saved: make(map[int]savedLinks)}
+}
+
+// Save stores the links of a node, unless they were already saved
+// (a nil journal ignores the call)
+func (j *LinkJournal) Save(n *Node) {
+	if j == nil {
+		return
+	}
+	if _, alreadySaved := j.saved[n.Asn]; alreadySaved {
+		return
+	}
+
+	before := n.Copy()
+	j.saved[n.Asn] = savedLinks{node: n, links: before.Links, types: before.Type}
+}
+
+// Restore puts back the saved links, re-inserting in 'nodes' the nodes
+// that were removed in the meantime
+func (j *LinkJournal) Restore(nodes map[int]*Node) {
+	for asn, s := range j.saved {
+		s.node.Links = s.links
+		s.node.Type = s.types
+		nodes[asn] = s.node
+	}
+}
+
+// Merge adds the links saved by another journal, unless they were already saved
+func (j *LinkJournal) Merge(other *LinkJournal) {
+	for asn, s := range other.saved {
+		if _, alreadySaved := j.saved[asn]; !alreadySaved {
+			j.saved[asn] = s
+		}
+	}
+}
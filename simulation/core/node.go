@@ -124,6 +124,22 @@ func grRule(heardFromType int, advertisedToType int) bool {
 	}
 }
 
+// RespectsNoValley checks a lax version of Gao-Rexford rules on the types
+// of links traversed by a route
+func RespectsNoValley(routeLinks []int) bool {
+	goneDown := false
+
+	for _, ln := range routeLinks {
+		goneDown = goneDown || (ln == ToCustomer)
+
+		if goneDown && (ln == ToProvider) {
+			return false
+		}
+	}
+
+	return true
+}
+
 // CanTellAbout enforces Gao-Rexford rules, determining if the presence of
 // a link between 'n' and 'subject' can be revealed to 'target'
 func (n *Node) CanTellAbout(subject *Node, target *Node) bool {
@@ -180,6 +196,30 @@ func (n *Node) DeleteLink(neighborNode *Node) bool {
 	}
 }
 
+// AddLink inserts an edge of the given type, keeping the links sorted
+// It returns false if the link already exists
+func (n *Node) AddLink(neighborNode *Node, linkType int) bool {
+	if n.GetNeighborIndex(neighborNode) >= 0 {
+		return false
+	}
+
+	idx := 0
+	for idx < len(n.Links) && n.Links[idx] < neighborNode.Asn {
+		idx++
+	}
+
+	n.Links = append(n.Links, 0)
+	n.Type = append(n.Type, 0)
+
+	copy(n.Links[idx+1:], n.Links[idx:])
+	copy(n.Type[idx+1:], n.Type[idx:])
+
+	n.Links[idx] = neighborNode.Asn
+	n.Type[idx] = linkType
+
+	return true
+}
+
 func (l *Link) searchOrDefault(target int) int {
 	slice := (*l)[:]
 
@@ -0,0 +1,169 @@
+package experiment
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"dedis.epfl.ch/audit"
+	. "dedis.epfl.ch/core"
+	"dedis.epfl.ch/u"
+)
+
+// Command is an experiment that can be run from a spec
+type Command struct {
+	Description string
+	Run         func(spec Spec, recorder *audit.Recorder)
+}
+
+// Commands lists the available experiments by name
+var Commands = map[string]Command{
+	"preprocess": {
+		Description: "elect landmarks, compute witnesses and bunches and save them",
+		Run:         func(spec Spec, recorder *audit.Recorder) { spec.Preprocess() },
+	},
+	"restore": {
+		Description: "load the saved structures and print the landmarks of each level",
+		Run:         restore,
+	},
+	"stretch": {
+		Description: "measure the stretch over 'rounds' x 'samples' random paths",
+		Run:         stretch,
+	},
+	"deletion-impact": {
+		Description: "measure the impact of 'samples' random link deletions (or of the 'deletions' sequence)",
+		Run:         deletionImpact,
+	},
+	"cumulative-deletions": {
+		Description: "measure the stretch over 'rounds' rounds of deletions ('deletionProportion' of links or the 'deletions' sequence)",
+		Run:         cumulativeDeletions,
+	},
+	"landmark-levels": {
+		Description: "record the landmark levels used before/after 'samples' link deletions",
+		Run:         landmarkLevels,
+	},
+	"degrees": {
+		Description: "record the degrees of the endpoints of each link",
+		Run:         degrees,
+	},
+}
+
+// CommandNames returns the names of the commands in alphabetical order
+func CommandNames() []string {
+	names := make([]string, 0, len(Commands))
+	for name := range Commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run executes the named command with the spec
+// If resume is set and the checkpoints folder of the spec holds a checkpoint, the measurement
+// continues from it (appending to the output); otherwise it starts from scratch
+// It returns false if the command does not exist, and the error of saving the output (if any)
+func Run(name string, spec Spec, resume bool) (bool, error) {
+	command, exists := Commands[name]
+	if !exists {
+		return false, nil
+	}
+
+	u.FixedSeed = spec.Seed
+
+	recorder := spec.Recorder(resume)
+	command.Run(spec, recorder)
+
+	return true, recorder.Close()
+}
+
+// Recorder returns a recorder writing to the output of the spec (json lines if its
+// extension is .jsonl, a folder of typed columns if it is .columns, csv otherwise),
+// or nil if the spec has no output
+// If resume is set, the recorder continues from the checkpoint of the spec (if any)
+func (s Spec) Recorder(resume bool) *audit.Recorder {
+	if s.Output == "" {
+		return nil
+	}
+
+	hasCheckpoint, checkpoint := false, audit.Checkpoint{}
+	if resume && s.Checkpoints != "" {
+		hasCheckpoint, checkpoint = audit.LoadCheckpoint(s.Checkpoints)
+	}
+	if resume && !hasCheckpoint {
+		fmt.Println("No checkpoint to resume from, starting from scratch")
+	}
+
+	var sink audit.Sink
+	switch {
+	case strings.HasSuffix(s.Output, ".jsonl") && hasCheckpoint:
+		sink = audit.ResumeJSONLinesSink(s.Output, checkpoint.Offsets[0])
+	case strings.HasSuffix(s.Output, ".jsonl"):
+		sink = audit.NewJSONLinesSink(s.Output)
+	case strings.HasSuffix(s.Output, ".columns") && hasCheckpoint:
+		sink = audit.ResumeColumnarSink(s.Output, checkpoint.Offsets[0])
+	case strings.HasSuffix(s.Output, ".columns"):
+		sink = audit.NewColumnarSink(s.Output)
+	case hasCheckpoint:
+		sink = audit.ResumeCSVSink(s.Output, checkpoint.Offsets[0])
+	default:
+		sink = audit.NewCSVSink(s.Output)
+	}
+
+	recorder := audit.NewRecorder(sink)
+	if hasCheckpoint {
+		recorder.Resume(checkpoint)
+	}
+	if s.Checkpoints != "" {
+		recorder.EnableCheckpoints(s.Checkpoints)
+	}
+
+	dataset := s.Folder + s.Dataset + ".csv"
+	recorder.SetProvenance(audit.Provenance{
+		Dataset:       dataset,
+		DatasetSHA256: audit.HashFile(dataset),
+		K:             s.K,
+		Strategy:      s.Strategy,
+		Policy:        s.Policy,
+	})
+
+	return recorder
+}
+
+func restore(spec Spec, recorder *audit.Recorder) {
+	tzGraph := spec.RestoreTZ()
+
+	for level := 0; level < tzGraph.K; level++ {
+		fmt.Printf("Level %d: %d landmarks\n", level, len(tzGraph.Landmarks[level]))
+	}
+}
+
+func stretch(spec Spec, recorder *audit.Recorder) {
+	fmt.Print(audit.MeasureStretch(recorder, spec.LoadBGP(), spec.RestoreTZ(), spec.Rounds, spec.Samples))
+}
+
+func deletionImpact(spec Spec, recorder *audit.Recorder) {
+	if spec.Deletions != "" {
+		fmt.Print(audit.MeasureChosenEdgeDeletionImpact(recorder, spec.RestoreTZ(), spec.Folder+spec.Deletions))
+	} else {
+		fmt.Print(audit.MeasureEdgeDeletionImpact(recorder, spec.LoadBGP(), spec.RestoreTZ(), spec.Samples))
+	}
+}
+
+func cumulativeDeletions(spec Spec, recorder *audit.Recorder) {
+	bgpPointer := AbstractGraph(spec.LoadBGP())
+	tzPointer := AbstractGraph(spec.RestoreTZ())
+
+	if spec.Deletions != "" {
+		fmt.Print(audit.MeasureChosenDeletionsStretch(recorder, &bgpPointer, &tzPointer, spec.Rounds, spec.Folder+spec.Deletions))
+	} else {
+		fmt.Print(audit.MeasureRandomDeletionsStretch(recorder, &bgpPointer, &tzPointer, spec.Rounds, spec.DeletionProportion))
+	}
+}
+
+func landmarkLevels(spec Spec, recorder *audit.Recorder) {
+	fmt.Print(audit.MeasureLandmarkLevelAfterDeletion(recorder, spec.LoadBGP(), spec.RestoreTZ(), spec.Samples))
+}
+
+func degrees(spec Spec, recorder *audit.Recorder) {
+	fmt.Print(audit.MeasureEndpointsDegrees(recorder, spec.loadTZ()))
+}
@@ -0,0 +1,178 @@
+package experiment
+
+import (
+	"encoding/json"
+	"os"
+
+	"dedis.epfl.ch/bgp"
+	. "dedis.epfl.ch/core"
+	"dedis.epfl.ch/tz"
+	"dedis.epfl.ch/u"
+)
+
+// Spec describes an experiment: the dataset, how TZ structures are built and
+// the parameters of the measurement
+type Spec struct {
+	// Folder containing the dataset and the structures (must end with a slash)
+	Folder string `json:"folder"`
+	// Dataset is the name of the csv file with the edges (without extension)
+	Dataset string `json:"dataset"`
+	// Structures is the prefix of the files with landmarks, witnesses and bunches
+	// (by default: <dataset>-spo-<policy>)
+	Structures string `json:"structures"`
+	// Landmarks is an optional csv file (in Folder) with the landmarks to use instead of electing them
+	Landmarks string `json:"landmarks"`
+	K         int    `json:"k"`
+	// Strategy used to elect landmarks: random, spline, harmonic or immunity
+	Strategy string `json:"strategy"`
+	// Policy is the routing policy the structures were computed with (GR or GRP)
+	// Preprocess only computes GRP structures (the ones of tz.Graph): GR ones can only be restored
+	Policy          string `json:"policy"`
+	ValleyFree      bool   `json:"valleyFree"`
+	AtomicDeletions bool   `json:"atomicDeletions"`
+	// Seed of the random generator (0: current time)
+	// Runs are not fully reproducible, since ASes are picked by iterating over maps
+	Seed int64 `json:"seed"`
+	// Samples, Rounds and DeletionProportion are interpreted by each command
+	Samples            int     `json:"samples"`
+	Rounds             int     `json:"rounds"`
+	DeletionProportion float64 `json:"deletionProportion"`
+	// Deletions is an optional csv file (in Folder) with the sequence of links to delete
+	Deletions string `json:"deletions"`
+	// Output is the path of the log: csv, json lines (.jsonl) or typed columns (.columns)
+	// (the result is saved next to it as json)
+	Output string `json:"output"`
+	// Checkpoints is an optional folder where round-based measurements (cumulative-deletions)
+	// save their state after each round, to be resumed with --resume
+	Checkpoints string `json:"checkpoints"`
+}
+
+// Strategies maps the names of the landmark selection strategies to their values
+var Strategies = map[string]int{
+	"random":   tz.RandomStrategy,
+	"spline":   tz.SplineStrategy,
+	"harmonic": tz.HarmonicStrategy,
+	"immunity": tz.ImmunityStrategy,
+}
+
+// LoadSpec reads a spec from a json file, filling the missing fields with defaults
+func LoadSpec(filename string) Spec {
+	specFile, err := os.Open(filename)
+	if err != nil {
+		panic("Unable to open the experiment spec " + filename)
+	}
+	defer specFile.Close()
+
+	spec := Spec{}
+
+	decoder := json.NewDecoder(specFile)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&spec); err != nil {
+		panic("Invalid experiment spec " + filename + ": " + err.Error())
+	}
+
+	return spec.WithDefaults()
+}
+
+// WithDefaults returns a copy of the spec where the missing fields have a default value
+func (s Spec) WithDefaults() Spec {
+	if s.Folder == "" {
+		s.Folder = "./data/"
+	}
+	if s.K == 0 {
+		s.K = 3
+	}
+	if s.Strategy == "" {
+		s.Strategy = "harmonic"
+	}
+	if s.Policy == "" {
+		s.Policy = "GRP"
+	}
+	if s.Structures == "" {
+		s.Structures = s.Dataset + "-spo-" + s.Policy
+	}
+	if s.Rounds == 0 {
+		s.Rounds = 1
+	}
+
+	if s.Dataset == "" {
+		panic("The experiment spec must name a dataset")
+	}
+	if s.Checkpoints != "" && s.Output == "" {
+		panic("Checkpoints require an output")
+	}
+	if _, exists := Strategies[s.Strategy]; !exists {
+		panic("Unknown landmark selection strategy " + s.Strategy)
+	}
+	if s.Policy != "GR" && s.Policy != "GRP" {
+		panic("Unknown routing policy " + s.Policy)
+	}
+
+	return s
+}
+
+// structureFile returns the path of a structure file ("landmarks", "witnesses" or "bunches")
+func (s Spec) structureFile(structure string) string {
+	return s.Folder + s.Structures + "-" + structure + "-" + u.Str(Strategies[s.Strategy]) + ".csv"
+}
+
+// LoadBGP loads the dataset in a bgp.Graph
+func (s Spec) LoadBGP() *bgp.Graph {
+	bgpGraph := bgp.InitGraph()
+	if err := bgp.LoadFromCsv(&bgpGraph, s.Folder+s.Dataset+".csv"); err != nil {
+		panic("Unable to load the dataset " + s.Dataset + ": " + err.Error())
+	}
+
+	return &bgpGraph
+}
+
+// loadTZ loads the dataset in a tz.Graph, without landmarks
+func (s Spec) loadTZ() *tz.Graph {
+	tzGraph := tz.InitGraph()
+	tzGraph.K = s.K
+	tzGraph.ValleyFree = s.ValleyFree
+	tzGraph.AtomicDeletions = s.AtomicDeletions
+
+	if err := tz.LoadFromCsv(&tzGraph, s.Folder+s.Dataset+".csv"); err != nil {
+		panic("Unable to load the dataset " + s.Dataset + ": " + err.Error())
+	}
+
+	return &tzGraph
+}
+
+// Preprocess elects (or loads) the landmarks, computes witnesses and bunches,
+// and saves them to the structure files
+func (s Spec) Preprocess() *tz.Graph {
+	if s.Policy != "GRP" {
+		panic("Preprocess only computes GRP structures, " + s.Policy + " ones can only be restored")
+	}
+
+	tzGraph := s.loadTZ()
+
+	u.SeedRandom()
+
+	if s.Landmarks != "" {
+		tzGraph.LoadLandmarksFromCsv(s.Folder + s.Landmarks)
+	} else {
+		tzGraph.ElectLandmarks(Strategies[s.Strategy])
+	}
+
+	tzGraph.Preprocess()
+
+	tz.WriteLandmarksToCsv(s.structureFile("landmarks"), &tzGraph.Landmarks)
+	tz.WriteWitnessesToCsv(s.structureFile("witnesses"), &tzGraph.Witnesses)
+	tz.WriteToCsv(s.structureFile("bunches"), &map[int]Serializable{0: &tzGraph.Bunches})
+
+	return tzGraph
+}
+
+// RestoreTZ loads the dataset and the structures saved by Preprocess
+func (s Spec) RestoreTZ() *tz.Graph {
+	tzGraph := s.loadTZ()
+
+	tzGraph.LoadLandmarksFromCsv(s.structureFile("landmarks"))
+	tzGraph.LoadWitnessesFromCsv(s.structureFile("witnesses"))
+	tzGraph.LoadBunchesFromCsv(s.structureFile("bunches"))
+
+	return tzGraph
+}
@@ -1,73 +1,13 @@
 package main
 
 import (
-	"math/rand"
-	"time"
+	"fmt"
+	"os"
 
-	"dedis.epfl.ch/audit"
-	"dedis.epfl.ch/bgp"
-	"dedis.epfl.ch/tz"
-	"dedis.epfl.ch/u"
-
-	. "dedis.epfl.ch/core"
+	"dedis.epfl.ch/experiment"
+	"dedis.epfl.ch/shell"
 )
 
-func restoreTZ(folder string, graphName string, structuresName string, k int, landmarkStrategy int) tz.Graph {
-	tzGraph := tz.InitGraph()
-	tzGraph.K = k
-
-	tz.LoadFromCsv(&tzGraph, folder+graphName+".csv")
-
-	tzGraph.LoadLandmarksFromCsv(folder + structuresName + "-landmarks-" + u.Str(landmarkStrategy) + ".csv")
-	tzGraph.LoadWitnessesFromCsv(folder + structuresName + "-witnesses-" + u.Str(landmarkStrategy) + ".csv")
-	tzGraph.LoadBunchesFromCsv(folder + structuresName + "-bunches-" + u.Str(landmarkStrategy) + ".csv")
-
-	return tzGraph
-}
-
-// folder must end with a slash
-func loadAndProcessTZ(folder string, datasetName string, k int, landmarkStrategy int) tz.Graph {
-	tzGraph := tz.InitGraph()
-	tzGraph.K = k
-
-	tz.LoadFromCsv(&tzGraph, folder+datasetName+".csv")
-
-	rand.Seed(time.Now().UnixNano())
-
-	tzGraph.ElectLandmarks(landmarkStrategy)
-
-	tzGraph.Preprocess()
-
-	grFiltered := "spo-GR"
-
-	tz.WriteLandmarksToCsv(folder+datasetName+grFiltered+"-landmarks-"+u.Str(landmarkStrategy)+".csv", &tzGraph.Landmarks)
-	tz.WriteWitnessesToCsv(folder+datasetName+grFiltered+"-witnesses-"+u.Str(landmarkStrategy)+".csv", &tzGraph.Witnesses)
-	tz.WriteToCsv(folder+datasetName+grFiltered+"-bunches-"+u.Str(landmarkStrategy)+".csv", &map[int]Serializable{0: &tzGraph.Bunches})
-
-	return tzGraph
-}
-
-// folder must end with a slash
-func loadAndProcessWithLandmarksTZ(folder string, datasetName string, k int, landmarkStrategy int, landmarkFile string) tz.Graph {
-	tzGraph := tz.InitGraph()
-	tzGraph.K = k
-
-	tz.LoadFromCsv(&tzGraph, folder+datasetName+".csv")
-
-	rand.Seed(time.Now().UnixNano())
-
-	tzGraph.LoadLandmarksFromCsv(folder + landmarkFile)
-
-	tzGraph.Preprocess()
-
-	grFiltered := "spo-GR"
-
-	tz.WriteWitnessesToCsv(folder+datasetName+grFiltered+"-witnesses-"+u.Str(landmarkStrategy)+".csv", &tzGraph.Witnesses)
-	tz.WriteToCsv(folder+datasetName+grFiltered+"-bunches-"+u.Str(landmarkStrategy)+".csv", &map[int]Serializable{0: &tzGraph.Bunches})
-
-	return tzGraph
-}
-
 /////////////////////////////
 //       DISCLAIMER        //
 // Use only paths with     //
@@ -76,197 +16,19 @@ func loadAndProcessWithLandmarksTZ(folder string, datasetName string, k int, lan
 
 func main() {
 
-	grpTzGraph := restoreTZ("./data/", "202003-full-edges", "202003-full-edges-spo-GRP", 3, tz.HarmonicStrategy)
-	// restoreTZ("./data/", "202003-full-edges", "202003-full-edges-spo-GRP", 3, tz.HarmonicStrategy)
-	//loadAndProcessTZ("./data/", "202003-full-edges", 3, tz.HarmonicStrategy)
-
-	//grTzGraph := restoreTZ("./data/", "202003-full-edges", "202003-full-edges-spo-GRP", 3, tz.HarmonicStrategy)
-	//loadAndProcessWithLandmarksTZ("./data/", "hierarchical-test", 3, tz.HarmonicStrategy, "hierarchical-test-landmarks.csv")
-	//restoreTZ("./data/", "202003-full-edges", "202003-full-edges-GR", 3, tz.HarmonicStrategy)
-	//loadAndProcessWithLandmarksTZ("./data/", "202003-full-edges", 3, tz.HarmonicStrategy, "202003-full-edges-landmarks-2.csv")
-
-	bgpGraph := bgp.InitGraph()
-	bgp.LoadFromCsv(&bgpGraph, "./data/202003-full-edges.csv")
-
-	// audit.InitRecorder("./data/full-stretch-land-spo-GRP-4000.csv")
-	// avgStretch, maxStretch := audit.MeasureStretch(&bgpGraph, &landGrTzGraph, 1, 4000)
-	// fmt.Printf("Average stretch: %f		Maximum stretch: %f\n", avgStretch, maxStretch)
-
-	// audit.InitRecorder("./data/full-endpoints-degrees.csv")
-	// audit.MeasureEndpointsDegrees(&grTzGraph)
-
-	// Measure stretch
-	// audit.InitRecorder("./data/full-stretch-spo-GRP-4000.csv")
-	// avgStretch, maxStretch := audit.MeasureStretch(&bgpGraph, &grTzGraph, 1, 4000)
-	// fmt.Printf("Average stretch: %f		Maximum stretch: %f\n", avgStretch, maxStretch)
-
-	// audit.InitRecorder("./data/full-impact-spo-GRP-chosen.csv")
-	// avgImpact, maxImpact := audit.MeasureChosenEdgeDeletionImpact(&grTzGraph, "./data/202003-to-202004-disappearing.csv")
-	// fmt.Printf("Average impact: %f		Maximum impact: %f\n", avgImpact, maxImpact)
-
-	// audit.InitRecorder("./data/full-impact-spo-GRP-3000.csv")
-	// avgImpact, maxImpact := audit.MeasureEdgeDeletionImpact(&bgpGraph, &grTzGraph, 3000)
-	// fmt.Printf("Average impact: %f		Maximum impact: %f\n", avgImpact, maxImpact)
-
-	bgpPointer := AbstractGraph(&bgpGraph)
-	// grpTzPointer := AbstractGraph(&grpTzGraph)
-
-	// Measure Landmarks level before/after deletion
-	audit.InitRecorder("./data/landmarks-level-deletion-spo-GRP-3000.csv")
-	audit.MeasureLandmarkLevelAfterDeletion(bgpPointer, &grpTzGraph, 3000)
-
-	// // Measure cumulative effects of deletions over stretch
-	// audit.InitRecorder("./data/cumulative-deletions-spo-GRP-10x.02.csv")
-	// avgCumulIncrease, maxCumulIncrease := audit.MeasureRandomDeletionsStretch(&bgpPointer, &grpTzPointer, 10, .02)
-	// fmt.Printf("Average stretch increase (by round): %f		Maximum stretch increase (by round): %f\n", avgCumulIncrease, maxCumulIncrease)
-
-	// audit.InitRecorder("./data/cumulative-deletions-spo-GRP-12x0305-2000.csv")
-	// avgCumulIncrease, maxCumulIncrease := audit.MeasureChosenDeletionsStretch(&bgpPointer, &grTzPointer, 12, "./data/202003-to-202005-disappearing.csv")
-	// fmt.Printf("Average stretch increase (by round): %f		Maximum stretch increase (by round): %f\n", avgCumulIncrease, maxCumulIncrease)
-
-	// Compute TZ from scratch on graph with missing edges
-	// refreshedTzGraph := loadAndProcessTZ("./data/", "missing-edges-12x0.050", 3, tz.HarmonicStrategy)
-
-	// Perform stretch measurements on fresh TZ graph and progressively adapted one
-	// audit.InitRecorder("./data/missing-edges-12x0.05-stretch-3000.csv")
-	// avgMissingStretch, maxMissingStretch := audit.MeasureStretch(&refreshedTzGraph, tzPointer, 2, 1500)
-	// fmt.Printf("Missing edges graph, Average stretch: %f		Maximum stretch: %f\n", avgMissingStretch, maxMissingStretch)
-
-	// audit.InitRecorder("./data/refreshed-tz-12x0.05-stretch-2000.csv")
-	// avgRefreshedStretch, maxRefreshedStretch := audit.MeasureStretch(bgpPointer, &refreshedTzGraph, 2, 1000)
-	// fmt.Printf("Refreshed TZ graph, Average stretch: %f		Maximum stretch: %f\n", avgRefreshedStretch, maxRefreshedStretch)
-
-	// Measure stretch
-	// audit.InitRecorder("./data/full-GR-stretch-4000.csv")
-	// avgStretch, maxStretch := audit.MeasureStretch(&bgpGraph, &grTzGraph, 4, 1000)
-	// fmt.Printf("Average stretch: %f		Maximum stretch: %f\n", avgStretch, maxStretch)
-
-	// audit.InitRecorder("./data/full-deletion-stretch-spo-GRP-1000.csv")
-	// avgDelStretch, maxDelStretch := audit.MeasureDeletionStretch(&bgpGraph, &grTzGraph, 1000)
-	// fmt.Printf("Average stretch increase: %f 	Max stretch increase: %f\n", avgDelStretch, maxDelStretch)
-
-	// if err := exec.Command("cmd", "/C", "shutdown", "/s").Run(); err != nil {
-	// 	fmt.Println("Failed to initiate shutdown:", err)
-	// }
-
-	// grTzGraph.PrintRoute(3, 8)
-
-	// delFile, err := os.Open("./data/debug-of-deletions.csv")
-	// if err != nil {
-	// 	panic("Cannot open deletion file")
-	// }
-
-	// delCsv := csv.NewReader(delFile)
-
-	// for i := 0; ; i++ {
-	// 	row, err := delCsv.Read()
-	// 	if err == io.EOF {
-	// 		break
-	// 	}
-
-	// 	if u.Int(row[0]) > 0 {
-	// 		grTzGraph.RemoveEdge(u.Int(row[0]), u.Int(row[1]))
-	// 	}
-	// }
-
-	// rand.Seed(time.Now().UnixNano())
-
-	// for trial := 0; ; trial++ {
-	// 	endpoint, neighborIdx := audit.RandomLink(&grTzGraph, grTzGraph.CountLinks())
-
-	// 	outcome, impactedArea, _ := grTzGraph.RemoveEdge(endpoint.Asn, endpoint.Links[neighborIdx])
-
-	// 	if outcome {
-
-	// 		couldHaveBeenImpacted := make(map[int]bool)
-	// 		for nd := range impactedArea {
-	// 			for _, ln := range grTzGraph.Nodes[nd].Links {
-	// 				couldHaveBeenImpacted[ln] = true
-	// 			}
-	// 		}
-
-	// 		for ca := range couldHaveBeenImpacted {
-	// 			impactedArea[ca] = true
-	// 		}
-
-	// 		for i := 0; i < 2*len(impactedArea); i++ {
-	// 			startRandom := rand.Int() % len(impactedArea)
-	// 			var startNode int
-	// 			for n := range impactedArea {
-	// 				if startRandom == 0 {
-	// 					startNode = n
-	// 				}
-	// 				startRandom--
-	// 			}
-	// 			endRandom := rand.Int() % len(impactedArea)
-	// 			var endNode int
-	// 			for n := range impactedArea {
-	// 				if endRandom == 0 {
-	// 					endNode = n
-	// 				}
-	// 				endRandom--
-	// 			}
-
-	// 			routeNodes, _ := grTzGraph.GetRoute(startNode, endNode)
-
-	// 			if i%400 == 0 {
-	// 				fmt.Printf("Trial #%d, path #%d: %s\n", trial, i, routeNodes)
-	// 			}
-	// 		}
-	// 	}
-	// }
-
-	// for landGrTzGraph.ExecCommand() {
-	// }
-
-	/*
-		tzGraph.PrintRoute(5, 4)
-
-		tzGraph.RemoveEdge(3, 4)
-		tzGraph.PrintRoute(5, 4)
-
-		tzGraph.RemoveEdge(5, 1)
-		tzGraph.PrintRoute(5, 4)
-	*/
-	/*
-		IDEAS:
-		  - If lower level landmark receives top-level update messages, it also broadcast its presence
-		  - If landmarks have topologically-dependent label (e.g. IP prefix) routing without handshake
-		    is possible in most cases (also assuming they store clusters)
-	*/
-
-	/*
-		// BGP graph
-		bgpGraph := bgp.InitGraph()
-
-		bgp.LoadFromCsv(&bgpGraph, "./data/202003-edges.csv")
-
-		// TZ graph
-		tzGraph := tz.InitGraph()
-		tzGraph.K = 3
-
-		tz.LoadFromCsv(&tzGraph, "./data/202003-edges.csv")
-
-		rand.Seed(time.Now().UnixNano())
-
-		// tzGraph.ElectLandmarks(tz.ImmunityStrategy)
-		// tzGraph.Preprocess()
-
-		// tz.WriteWitnessesToCsv("./data/202003-immunity-witnesses.csv", &tzGraph.Witnesses)
-		// tz.WriteToCsv("./data/202003-immunity-bunches.csv", &map[int]core.Serializable{0: &tzGraph.Bunches})
-
-		tzGraph.LoadWitnessesFromCsv("./data/202003-harmonic(orig)-witnesses.csv")
-		tzGraph.LoadBunchesFromCsv("./data/202003-harmonic(orig)-bunches.csv")
-
-		avgStretch, maxStretch := audit.MeasureStretch(&bgpGraph, &tzGraph, 4, 100)
-		// Measure stretch
-		fmt.Printf("Average stretch: %f		Maximum stretch: %f\n", avgStretch, maxStretch)
-
-		bgp.SetupShell()
-		tz.SetupShell()
-
-		for tzGraph.ExecCommand() {
-		}
-	*/
-
+	// Show the progress of preprocessing and audits on the current line
+	shell.Reporter = shell.NewTerminalReporter()
+
+	// Other experiments run without recompiling, through cmd/tzsim and a json spec
+	spec := experiment.Spec{
+		Dataset: "202003-full-edges",
+		Policy:  "GRP",
+		Samples: 3000,
+		Output:  "./data/landmarks-level-deletion-spo-GRP-3000.csv",
+	}.WithDefaults()
+
+	if _, err := experiment.Run("landmark-levels", spec, false); err != nil {
+		fmt.Fprintln(os.Stderr, "Unable to save the output:", err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,182 @@
+package shell
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Progress describes the advancement of a long task
+type Progress struct {
+	Task string
+	Done int
+	// Total is 0 if unknown
+	Total   int
+	Elapsed time.Duration
+	// Rate is the number of items done per second
+	Rate float64
+	// ETA is the estimated time left (0 if unknown)
+	ETA      time.Duration
+	Finished bool
+}
+
+func (p Progress) String() string {
+	var done string
+	if p.Total > 0 {
+		done = fmt.Sprintf("%d/%d (%.0f%%)", p.Done, p.Total, 100*float64(p.Done)/float64(p.Total))
+	} else {
+		done = fmt.Sprintf("%d", p.Done)
+	}
+
+	if p.Finished {
+		return fmt.Sprintf("%s: %s in %s, %.1f/s", p.Task, done, p.Elapsed.Round(100*time.Millisecond), p.Rate)
+	}
+	if p.ETA > 0 {
+		return fmt.Sprintf("%s: %s, %.1f/s, ETA %s", p.Task, done, p.Rate, p.ETA.Round(time.Second))
+	}
+	return fmt.Sprintf("%s: %s, %.1f/s", p.Task, done, p.Rate)
+}
+
+// ProgressReporter receives the progress of long tasks (possibly from several goroutines)
+type ProgressReporter interface {
+	Report(p Progress)
+}
+
+// Reporter receives the progress of the tasks started with StartTask (nil: no reporting)
+var Reporter ProgressReporter
+
+// ReportInterval is the minimum time between two reports of a task
+// Tasks that complete faster are never reported
+var ReportInterval = 500 * time.Millisecond
+
+// Tracker counts the items done by a task, reporting its progress every ReportInterval
+// The methods of a nil *Tracker do nothing
+type Tracker struct {
+	mutex      sync.Mutex
+	task       string
+	done       int
+	total      int
+	started    time.Time
+	lastReport time.Time
+	reported   bool
+}
+
+// StartTask returns a tracker for a task of 'total' items (0 if unknown),
+// or nil if there is no Reporter
+func StartTask(task string, total int) *Tracker {
+	if Reporter == nil {
+		return nil
+	}
+
+	now := time.Now()
+	return &Tracker{task: task, total: total, started: now, lastReport: now}
+}
+
+// Step marks one item as done
+func (t *Tracker) Step() {
+	t.Add(1)
+}
+
+// Add marks n items as done
+func (t *Tracker) Add(n int) {
+	if t == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.done += n
+	if now := time.Now(); now.Sub(t.lastReport) >= ReportInterval {
+		t.lastReport = now
+		t.reported = true
+		Reporter.Report(t.progress(now, false))
+	}
+}
+
+// SetTotal updates the number of items of the task (e.g. when new items are queued)
+func (t *Tracker) SetTotal(total int) {
+	if t == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.total = total
+}
+
+// Finish reports the completion of the task (if its progress was reported)
+func (t *Tracker) Finish() {
+	if t == nil {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.reported {
+		Reporter.Report(t.progress(time.Now(), true))
+	}
+}
+
+func (t *Tracker) progress(now time.Time, finished bool) Progress {
+	p := Progress{Task: t.task, Done: t.done, Total: t.total, Elapsed: now.Sub(t.started), Finished: finished}
+
+	if seconds := p.Elapsed.Seconds(); seconds > 0 {
+		p.Rate = float64(t.done) / seconds
+	}
+	if p.Rate > 0 && t.total > t.done {
+		p.ETA = time.Duration(float64(t.total-t.done) / p.Rate * float64(time.Second))
+	}
+
+	return p
+}
+
+// TerminalReporter overwrites the current line of the terminal with the latest progress
+type TerminalReporter struct {
+	mutex sync.Mutex
+	shell *Shell
+}
+
+// NewTerminalReporter returns a reporter writing to the standard output
+func NewTerminalReporter() *TerminalReporter {
+	return &TerminalReporter{shell: InitShell("", "")}
+}
+
+// Report implements ProgressReporter
+func (r *TerminalReporter) Report(p Progress) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.shell.Overwrite(p.String())
+	if p.Finished {
+		r.shell.Print("\n")
+	}
+}
+
+// LogReporter writes the progress of each task to a log, at most once per interval
+// (and when the task finishes)
+type LogReporter struct {
+	mutex    sync.Mutex
+	logger   *log.Logger
+	interval time.Duration
+	last     map[string]time.Time
+}
+
+// NewLogReporter returns a reporter writing to the logger
+func NewLogReporter(logger *log.Logger, interval time.Duration) *LogReporter {
+	return &LogReporter{logger: logger, interval: interval, last: make(map[string]time.Time)}
+}
+
+// Report implements ProgressReporter
+func (r *LogReporter) Report(p Progress) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if now := time.Now(); p.Finished || now.Sub(r.last[p.Task]) >= r.interval {
+		r.last[p.Task] = now
+		r.logger.Println(p)
+	}
+}
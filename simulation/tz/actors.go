@@ -0,0 +1,59 @@
+package tz
+
+import (
+	"dedis.epfl.ch/actor"
+)
+
+// landmarkActor processes the landmark announcements received by a single AS
+type landmarkActor struct {
+	run *distributedRun
+	asn int
+}
+
+// Receive implements actor.Actor
+func (a *landmarkActor) Receive(msg actor.Message, send func(actor.Message)) {
+	announcement := msg.Payload.(landmarkAnnouncement)
+
+	if a.run.deliver(announcement) {
+		for _, next := range a.run.announce(a.asn, announcement.key) {
+			send(toMessage(next))
+		}
+	}
+}
+
+func toMessage(announcement landmarkAnnouncement) actor.Message {
+	return actor.Message{
+		From:    announcement.sender,
+		To:      announcement.recipient,
+		Payload: announcement,
+	}
+}
+
+// runConcurrently delivers announcements asynchronously, each AS running in its own goroutine
+func (r *distributedRun) runConcurrently(outbox []landmarkAnnouncement, config actor.Config) {
+	actors := make(map[int]actor.Actor)
+	for asn := range r.nodes {
+		actors[asn] = &landmarkActor{run: r, asn: asn}
+	}
+
+	initial := make([]actor.Message, 0, len(outbox))
+	for _, announcement := range outbox {
+		initial = append(initial, toMessage(announcement))
+	}
+
+	stats := actor.InitRuntime(actors, config).Run(initial)
+	r.messages += int(stats.Sent)
+}
+
+// DistributedPreprocessConcurrently behaves like DistributedPreprocess, but ASes
+// run concurrently and exchange announcements asynchronously over a network
+// configured by 'config' (which can lose and reorder messages)
+// Rounds are only counted for the completion of clusters, since there is no
+// synchronization between ASes
+func (g *Graph) DistributedPreprocessConcurrently(config actor.Config) DistributedReport {
+	return g.distributedPreprocess(func(r *distributedRun, outbox []landmarkAnnouncement) {
+		r.runConcurrently(outbox, config)
+		// The quiescence of the Gao-Rexford phase is detected by the runtime
+		r.runConcurrently(r.fallbackAnnouncements(), config)
+	})
+}
@@ -0,0 +1,29 @@
+package tz
+
+import (
+	"testing"
+
+	"dedis.epfl.ch/actor"
+)
+
+// The synchronous run matches the centralized state (TestDistributedPreprocessMatchesCentralized)
+func TestDistributedPreprocessConcurrentlyMatchesSynchronous(t *testing.T) {
+	configs := []actor.Config{
+		{Seed: 1},
+		{ReorderRate: 0.5, Seed: 2},
+		{LossRate: 0.3, Retransmit: true, RetransmitDelay: 5, Seed: 3},
+		{LossRate: 0.3, Retransmit: true, RetransmitDelay: 5, ReorderRate: 0.5, Seed: 4},
+		{LossRate: 0.5, Retransmit: true, RetransmitDelay: 20, Seed: 5},
+	}
+
+	for _, landmarks := range testLandmarks {
+		graph := loadTestGraph(t, landmarks)
+
+		for _, config := range configs {
+			report := graph.DistributedPreprocessConcurrently(config)
+			if report.WitnessMismatches != 0 || report.BunchMismatches != 0 {
+				t.Errorf("k=%d, %+v: %d witness and %d bunch mismatches", graph.K, config, report.WitnessMismatches, report.BunchMismatches)
+			}
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package tz
+
+import (
+	. "dedis.epfl.ch/core"
+	"dedis.epfl.ch/u"
+)
+
+// EdgeImpact describes the part of a batch repair caused by one of the deleted links
+type EdgeImpact struct {
+	// Removed is false if the link did not exist, or if one of its endpoints would remain isolated
+	Removed      bool
+	ImpactedArea map[int]bool
+	// Measure contains the distance of the invalidated nodes from the endpoints of the link
+	Measure *TapeMeasure
+}
+
+// RemoveEdges deletes a set of edges from the graph, then repairs witnesses and
+// bunches once for all of them (the invalidated regions are merged)
+// returns values with the same meaning of RemoveEdge, along with the impact of every edge
+// (false, 0) : no deletion could be performed (or they would disconnect an AtomicDeletions graph)
+// (false, >0): the deletions were performed but the graph is NO MORE 1 connected component,
+// the nodes of the separated components are returned
+// The TapeMeasure combines the measures of every removed edge (nil if the batch failed)
+func (g *Graph) RemoveEdges(edges [][2]int) (bool, map[int]bool, *TapeMeasure, []EdgeImpact) {
+
+	impacts := make([]EdgeImpact, len(edges))
+	measures := make([]*TapeMeasure, len(edges))
+
+	removed := make([]int, 0, len(edges))
+	removedTypes := make(map[int]int)
+
+	for idx, e := range edges {
+		impacts[idx].ImpactedArea = make(map[int]bool)
+
+		// The endpoints of the link are its origin
+		tempMeasure := InitMeasure(e[0])
+		tempMeasure[e[1]] = 0
+		measures[idx] = &tempMeasure
+		impacts[idx].Measure = &tempMeasure
+
+		a, aOk := g.Nodes[e[0]]
+		b, bOk := g.Nodes[e[1]]
+
+		if !(aOk && bOk) || a.GetNeighborIndex(b) < 0 {
+			continue
+		}
+
+		if len(a.Links) <= 1 || len(b.Links) <= 1 {
+			continue
+		}
+
+		g.saveLinks(a)
+		g.saveLinks(b)
+
+		removedTypes[idx] = a.GetNeighborType(b)
+
+		if !(a.DeleteLink(b) && b.DeleteLink(a)) {
+			panic("Link deletion unsuccessful! Corrupted graph")
+		}
+
+		impacts[idx].Removed = true
+		removed = append(removed, idx)
+	}
+
+	if len(removed) == 0 {
+		return false, nil, nil, impacts
+	}
+
+	separated := g.separatedPieces(edges, removed)
+	if len(separated) > 0 && g.AtomicDeletions {
+		// Put the links back
+		for r := len(removed) - 1; r >= 0; r-- {
+			idx := removed[r]
+			a, b := g.Nodes[edges[idx][0]], g.Nodes[edges[idx][1]]
+			if !(a.AddLink(b, removedTypes[idx]) && b.AddLink(a, -removedTypes[idx])) {
+				panic("Link insertion unsuccessful! Corrupted graph")
+			}
+			impacts[idx].Removed = false
+		}
+		return false, nil, nil, impacts
+	}
+
+	// Fix Witnesses
+	for round := g.K - 1; round >= 0; round-- {
+		invalid := make(map[int]int)
+		for _, idx := range removed {
+			for side := 0; side < 2; side++ {
+				endpoint, other := edges[idx][side], edges[idx][1-side]
+				if witness, exists := (*g.Witnesses[round])[endpoint]; exists && witness.nextHop.Asn == other {
+					invalid[endpoint] = idx
+				}
+			}
+		}
+
+		if len(invalid) > 0 {
+			repaired, _, cause := g.rebuildWitnesses(round, invalid, nil, measures)
+			for asn := range repaired {
+				impacts[cause[asn]].ImpactedArea[asn] = true
+			}
+		}
+
+		// Enforce asterisk rule only when witnesses are coherent
+		g.enforceAsteriskRule(round)
+	}
+
+	// Fix Bunches
+	broken := make([]deletedLink, 0, 2*len(removed))
+	for _, idx := range removed {
+		a, b := g.Nodes[edges[idx][0]], g.Nodes[edges[idx][1]]
+		broken = append(broken, deletedLink{endpoint: a, neighbor: b, cause: idx}, deletedLink{endpoint: b, neighbor: a, cause: idx})
+	}
+
+	for idx, bunchArea := range g.repairBunches(broken, measures, nil) {
+		impacts[idx].ImpactedArea = u.Union(impacts[idx].ImpactedArea, bunchArea)
+	}
+
+	impactedArea := make(map[int]bool)
+	for _, idx := range removed {
+		// The endpoints are always involved, like in RemoveEdge
+		impacts[idx].ImpactedArea[edges[idx][0]] = true
+		impacts[idx].ImpactedArea[edges[idx][1]] = true
+		impactedArea = u.Union(impactedArea, impacts[idx].ImpactedArea)
+	}
+
+	if len(separated) > 0 {
+		// If there are several connected components,
+		// return the disconnected nodes
+		disconnected := make(map[int]bool)
+		for _, piece := range separated {
+			g.splitComponent(piece)
+			disconnected = u.Union(disconnected, piece)
+		}
+		return false, disconnected, nil, impacts
+	}
+
+	// The per-edge measures are kept, the aggregate takes the closest origin of each node
+	impactMeasure := make(TapeMeasure)
+	for _, idx := range removed {
+		for asn, distance := range *measures[idx] {
+			if current, exists := impactMeasure[asn]; !exists || distance < current {
+				impactMeasure[asn] = distance
+			}
+		}
+	}
+
+	return true, impactedArea, &impactMeasure, impacts
+}
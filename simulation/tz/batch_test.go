@@ -0,0 +1,33 @@
+package tz
+
+import (
+	"testing"
+)
+
+func TestRemoveEdgesAggregatesMeasures(t *testing.T) {
+	graph := loadTestGraph(t, testLandmarks[2])
+
+	success, area, measure, impacts := graph.RemoveEdges([][2]int{{1, 5}, {6, 7}})
+	if !success || measure == nil {
+		t.Fatalf("batch refused (%v, %v)", area, measure)
+	}
+
+	for _, asn := range []int{1, 5, 6, 7} {
+		if distance, exists := (*measure)[asn]; !exists || distance != 0 {
+			t.Errorf("endpoint %d measured at %d (%v)", asn, distance, exists)
+		}
+	}
+
+	for idx, impact := range impacts {
+		for asn, distance := range *impact.Measure {
+			if aggregate, exists := (*measure)[asn]; !exists || aggregate > distance {
+				t.Errorf("edge %d measures %d at %d, aggregate %d (%v)", idx, asn, distance, aggregate, exists)
+			}
+		}
+		for asn := range impact.ImpactedArea {
+			if !area[asn] {
+				t.Errorf("edge %d impacts %d, missing from the area", idx, asn)
+			}
+		}
+	}
+}
@@ -2,6 +2,7 @@ package tz
 
 import (
 	. "dedis.epfl.ch/core"
+	"dedis.epfl.ch/shell"
 	"dedis.epfl.ch/u"
 )
 
@@ -22,55 +23,65 @@ func (c *Clusters) Serialize(index int) [][]string {
 }
 
 func (c *Clusters) calculateClustersForRound(nodes *map[int]*Node, k int, l *Landmarks, prevRound *DijkstraGraph) {
+	tracker := shell.StartTask("Clusters of level "+u.Str(k), len((*l)[k])-len((*l)[k+1]))
+	defer tracker.Finish()
+
 	for w := range (*l)[k] {
 		if _, ok := (*l)[k+1][w]; !ok {
 			// w is in the set difference A_(k)\A_(k+1)
-			wClusterGraph := make(DijkstraGraph)
-
-			// Initialize Dijkstra with the source
-			source := dijkstraNode{
-				reference: w.Asn,
-				distance:  0,
-				parent:    w,
-				nextHop:   w,
-			}
-			wClusterGraph[w.Asn] = &source
+			c.calculateCluster(nodes, w, prevRound)
+			tracker.Step()
+		}
+	}
+}
 
-			clusterFrontier := Frontier{
-				Zones:       make(map[int64]map[int]*dijkstraNode),
-				MinDistance: 0,
-			}
-			clusterFrontier.Zones[0] = map[int]*dijkstraNode{source.reference: &source}
+// calculateCluster fills the cluster of w, containing the nodes closer to w than
+// to their witness in 'prevRound'
+func (c *Clusters) calculateCluster(nodes *map[int]*Node, w *Node, prevRound *DijkstraGraph) {
+	wClusterGraph := make(DijkstraGraph)
+
+	// Initialize Dijkstra with the source
+	source := dijkstraNode{
+		reference: w.Asn,
+		distance:  0,
+		parent:    w,
+		nextHop:   w,
+	}
+	wClusterGraph[w.Asn] = &source
 
-			wClusterGraph.runDijkstra(nodes, &clusterFrontier, 1)
+	clusterFrontier := Frontier{
+		Zones:       make(map[int64]map[int]*dijkstraNode),
+		MinDistance: 0,
+	}
+	clusterFrontier.Zones[0] = map[int]*dijkstraNode{source.reference: &source}
 
-			// Create cluster for w
-			(*c)[w.Asn] = make(map[int]*dijkstraNode)
+	wClusterGraph.runDijkstra(nodes, &clusterFrontier, 1, nil)
 
-			// First, add all the leaves (and some other nodes) of the tree
-			for nd := range wClusterGraph {
-				if wClusterGraph[nd].distance < (*prevRound)[nd].distance {
-					(*c)[w.Asn][nd] = wClusterGraph[nd]
-				}
-			}
+	// Create cluster for w
+	(*c)[w.Asn] = make(map[int]*dijkstraNode)
 
-			// Include missing nodes (to form a spanning tree)
-			inPathNodes := make(map[int]*dijkstraNode)
-			for _, dijNode := range (*c)[w.Asn] {
-				cursor := dijNode
-				for cursor.distance > 0 {
-					if _, inPath := (*c)[w.Asn][cursor.reference]; !inPath {
-						inPathNodes[cursor.reference] = cursor
-					}
-					cursor = wClusterGraph[cursor.nextHop.Asn]
-				}
-			}
+	// First, add all the leaves (and some other nodes) of the tree
+	for nd := range wClusterGraph {
+		if wClusterGraph[nd].distance < (*prevRound)[nd].distance {
+			(*c)[w.Asn][nd] = wClusterGraph[nd]
+		}
+	}
 
-			for ip, dij := range inPathNodes {
-				(*c)[w.Asn][ip] = dij
+	// Include missing nodes (to form a spanning tree)
+	inPathNodes := make(map[int]*dijkstraNode)
+	for _, dijNode := range (*c)[w.Asn] {
+		cursor := dijNode
+		for cursor.distance > 0 {
+			if _, inPath := (*c)[w.Asn][cursor.reference]; !inPath {
+				inPathNodes[cursor.reference] = cursor
 			}
+			cursor = wClusterGraph[cursor.nextHop.Asn]
 		}
 	}
+
+	for ip, dij := range inPathNodes {
+		(*c)[w.Asn][ip] = dij
+	}
 }
 
 // Copy returns a duplicate of Clusters
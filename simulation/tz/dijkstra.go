@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	. "dedis.epfl.ch/core"
+	"dedis.epfl.ch/shell"
 	"dedis.epfl.ch/u"
 )
 
@@ -48,11 +49,14 @@ func (d *DijkstraGraph) Serialize(index int) [][]string {
 	return rows
 }
 
-func (d *DijkstraGraph) runDijkstra(nodes *map[int]*Node, frontier *Frontier, frontierPopulation int) {
+// runDijkstra expands the frontier over 'nodes', counting the expanded nodes in tracker
+// (nil when the run is part of a larger task, so that it does not replace its progress)
+func (d *DijkstraGraph) runDijkstra(nodes *map[int]*Node, frontier *Frontier, frontierPopulation int, tracker *shell.Tracker) {
 	for frontierPopulation > 0 {
 		expandFrom := frontier.getFromClosest()
 		frontierPopulation--
 		frontierPopulation += frontier.expandFromNode(nodes, d, expandFrom, false)
+		tracker.Step()
 	}
 
 	// Discover non GR-reachable nodes and run vanilla Dijkstra
@@ -81,6 +85,7 @@ func (d *DijkstraGraph) runDijkstra(nodes *map[int]*Node, frontier *Frontier, fr
 		expandFrom := frontier.getFromClosest()
 		frontierPopulation--
 		frontierPopulation += frontier.expandFromNode(&nonGRneighborhood, d, expandFrom, true)
+		tracker.Step()
 		// frontier.checkFrontierConsistency(frontierPopulation)
 	}
 }
@@ -0,0 +1,454 @@
+package tz
+
+import (
+	"sort"
+
+	. "dedis.epfl.ch/core"
+)
+
+// landmarkAnnouncement advertises the route to a landmark to a neighbor, or withdraws it
+// key identifies the table entry (the level for witnesses, the landmark for clusters)
+// path lists the ASes from the sender to the landmark (announcements containing the
+// recipient are ignored), version orders the announcements of the sender
+type landmarkAnnouncement struct {
+	key       int
+	landmark  int
+	distance  int64
+	path      []int
+	version   int
+	withdraw  bool
+	sender    int
+	recipient int
+}
+
+// distributedRun is a synchronous execution of the landmark announcement protocol,
+// in which every AS only knows its own table and the announcements of its neighbors
+// received holds the latest announcement of each neighbor, advertised the neighbors
+// that received the current route (during the current phase)
+// known is the set of routes known at the end of the Gao-Rexford phase
+type distributedRun struct {
+	nodes      map[int]*Node
+	table      map[int]map[int]*dijkstraNode
+	paths      map[int]map[int][]int
+	versions   map[int]map[int]int
+	received   map[int]map[int]map[int]landmarkAnnouncement
+	advertised map[int]map[int]map[int]bool
+	known      map[int]map[int]bool
+	fallback   bool
+	messages   int
+	rounds     int
+}
+
+// initDistributedRun creates the (empty) tables of every AS
+func initDistributedRun(nodes map[int]*Node) *distributedRun {
+	run := distributedRun{
+		nodes:      nodes,
+		table:      make(map[int]map[int]*dijkstraNode),
+		paths:      make(map[int]map[int][]int),
+		versions:   make(map[int]map[int]int),
+		received:   make(map[int]map[int]map[int]landmarkAnnouncement),
+		advertised: make(map[int]map[int]map[int]bool),
+	}
+
+	// Every AS only modifies its own entries, so that ASes can run concurrently
+	for asn := range nodes {
+		run.table[asn] = make(map[int]*dijkstraNode)
+		run.paths[asn] = make(map[int][]int)
+		run.versions[asn] = make(map[int]int)
+		run.received[asn] = make(map[int]map[int]landmarkAnnouncement)
+		run.advertised[asn] = make(map[int]map[int]bool)
+	}
+
+	return &run
+}
+
+// DistributedReport summarizes a distributed execution of the preprocessing
+type DistributedReport struct {
+	Messages          int
+	Rounds            int
+	MessagesByLevel   []int
+	WitnessMismatches int
+	BunchMismatches   int
+}
+
+// exports tells whether 'asn' advertises its route for 'key' to the neighbor l
+// The Gao-Rexford phase follows the export rules, the fallback phase is restricted
+// to the ASes without a route at its start and their neighbors (like runDijkstra)
+func (r *distributedRun) exports(asn int, key int, l int) bool {
+	route := r.table[asn][key]
+
+	// Do not advertise the route to the neighbor that advertised it
+	if route == nil || l == route.nextHop.Asn {
+		return false
+	}
+
+	if r.fallback {
+		return r.inFallback(asn, key) && r.inFallback(l, key)
+	}
+
+	return r.nodes[asn].CanTellAbout(route.nextHop, r.nodes[l])
+}
+
+// inFallback tells whether 'asn' takes part in the fallback phase for 'key'
+func (r *distributedRun) inFallback(asn int, key int) bool {
+	if !r.known[asn][key] {
+		return true
+	}
+	for _, l := range r.nodes[asn].Links {
+		if !r.known[l][key] {
+			return true
+		}
+	}
+	return false
+}
+
+// announce creates the announcements of the route of 'asn' for 'key', and the
+// withdrawals towards the neighbors that can no longer receive it
+// It only modifies the state of 'asn'
+func (r *distributedRun) announce(asn int, key int) []landmarkAnnouncement {
+	nd := r.nodes[asn]
+	route := r.table[asn][key]
+
+	advertised, exists := r.advertised[asn][key]
+	if !exists {
+		advertised = make(map[int]bool)
+		r.advertised[asn][key] = advertised
+	}
+
+	announcements := make([]landmarkAnnouncement, 0, len(nd.Links))
+
+	for _, l := range nd.Links {
+		msg := landmarkAnnouncement{
+			key:       key,
+			version:   r.versions[asn][key],
+			sender:    asn,
+			recipient: l,
+		}
+
+		if r.exports(asn, key, l) {
+			msg.landmark = route.parent.Asn
+			msg.distance = route.distance
+			msg.path = r.paths[asn][key]
+			advertised[l] = true
+		} else if advertised[l] {
+			msg.withdraw = true
+			delete(advertised, l)
+		} else {
+			continue
+		}
+
+		announcements = append(announcements, msg)
+	}
+
+	return announcements
+}
+
+// seed installs the route of a landmark to itself
+func (r *distributedRun) seed(landmark int, key int) []landmarkAnnouncement {
+	r.table[landmark][key] = &dijkstraNode{
+		reference: landmark,
+		distance:  0,
+		parent:    r.nodes[landmark],
+		nextHop:   r.nodes[landmark],
+	}
+	r.paths[landmark][key] = []int{landmark}
+	r.versions[landmark][key]++
+
+	return r.announce(landmark, key)
+}
+
+// deliver records an announcement and selects the best route of the recipient among
+// the latest announcements of its neighbors: the shortest one, then the one of the
+// neighbor with the lowest ASN (the frontier expands the nodes at the same distance by
+// increasing ASN, and keeps the first route found)
+// returns true if the route of the recipient changed
+// It only modifies the state of the recipient
+func (r *distributedRun) deliver(msg landmarkAnnouncement) bool {
+	received, exists := r.received[msg.recipient][msg.key]
+	if !exists {
+		received = make(map[int]landmarkAnnouncement)
+		r.received[msg.recipient][msg.key] = received
+	}
+
+	// Announcements delivered out of order are outdated
+	if previous, exists := received[msg.sender]; exists && previous.version > msg.version {
+		return false
+	}
+	received[msg.sender] = msg
+
+	current := r.table[msg.recipient][msg.key]
+	if current != nil && current.distance == 0 {
+		// Landmarks keep the route to themselves
+		return false
+	}
+
+	var best *landmarkAnnouncement
+	for sender := range received {
+		candidate := received[sender]
+		if candidate.withdraw || containsAsn(candidate.path, msg.recipient) {
+			continue
+		}
+		if best == nil || candidate.distance < best.distance || (candidate.distance == best.distance && candidate.sender < best.sender) {
+			best = &candidate
+		}
+	}
+
+	if best == nil {
+		if current == nil {
+			return false
+		}
+		delete(r.table[msg.recipient], msg.key)
+		delete(r.paths[msg.recipient], msg.key)
+		r.versions[msg.recipient][msg.key]++
+		return true
+	}
+
+	route := &dijkstraNode{
+		reference: msg.recipient,
+		distance:  best.distance + EdgeWeight,
+		parent:    r.nodes[best.landmark],
+		nextHop:   r.nodes[best.sender],
+	}
+	if !routeChanged(current, route) {
+		return false
+	}
+
+	r.table[msg.recipient][msg.key] = route
+	r.paths[msg.recipient][msg.key] = append([]int{msg.recipient}, best.path...)
+	r.versions[msg.recipient][msg.key]++
+
+	return true
+}
+
+func containsAsn(path []int, asn int) bool {
+	for _, hop := range path {
+		if hop == asn {
+			return true
+		}
+	}
+	return false
+}
+
+// run delivers announcements in synchronous rounds until no AS changes its table
+func (r *distributedRun) run(outbox []landmarkAnnouncement) {
+	for len(outbox) > 0 {
+		r.rounds++
+		r.messages += len(outbox)
+
+		changed := make([][2]int, 0)
+		isChanged := make(map[[2]int]bool)
+
+		for _, msg := range outbox {
+			if !r.deliver(msg) {
+				continue
+			}
+
+			if entry := [2]int{msg.recipient, msg.key}; !isChanged[entry] {
+				isChanged[entry] = true
+				changed = append(changed, entry)
+			}
+		}
+
+		outbox = make([]landmarkAnnouncement, 0, len(changed))
+		for _, entry := range changed {
+			outbox = append(outbox, r.announce(entry[0], entry[1])...)
+		}
+	}
+}
+
+// fallbackAnnouncements records the routes known at the end of the Gao-Rexford
+// phase and starts the fallback phase, in which the ASes without a route and their
+// neighbors exchange routes without export rules
+func (r *distributedRun) fallbackAnnouncements() []landmarkAnnouncement {
+	r.known = make(map[int]map[int]bool)
+	for asn, routes := range r.table {
+		r.known[asn] = make(map[int]bool)
+		for key := range routes {
+			r.known[asn][key] = true
+		}
+	}
+
+	r.fallback = true
+	for asn := range r.advertised {
+		r.advertised[asn] = make(map[int]map[int]bool)
+	}
+
+	outbox := make([]landmarkAnnouncement, 0)
+	for asn, routes := range r.table {
+		for key := range routes {
+			if r.inFallback(asn, key) {
+				outbox = append(outbox, r.announce(asn, key)...)
+			}
+		}
+	}
+
+	return outbox
+}
+
+// converge runs the protocol from the seeds: first respecting Gao-Rexford rules,
+// then letting the routes reach the ASes that could not be reached under those rules
+func (r *distributedRun) converge(outbox []landmarkAnnouncement) {
+	r.run(outbox)
+	r.run(r.fallbackAnnouncements())
+}
+
+// joinClusters completes the clusters into spanning trees (like calculateCluster):
+// every member asks its next-hop towards the landmark to join the cluster
+// members maps each AS to the keys of the clusters it belongs to
+func (r *distributedRun) joinClusters(members map[int]map[int]bool) {
+	outbox := make([][2]int, 0)
+	join := func(asn int, key int) {
+		if route := r.table[asn][key]; route.distance > 0 {
+			outbox = append(outbox, [2]int{route.nextHop.Asn, key})
+		}
+	}
+
+	for asn, keys := range members {
+		for key := range keys {
+			join(asn, key)
+		}
+	}
+
+	for len(outbox) > 0 {
+		r.rounds++
+		r.messages += len(outbox)
+
+		joins := outbox
+		outbox = make([][2]int, 0)
+		for _, msg := range joins {
+			if asn, key := msg[0], msg[1]; !members[asn][key] {
+				members[asn][key] = true
+				join(asn, key)
+			}
+		}
+	}
+}
+
+// sortedLandmarks returns the ASNs of a set of landmarks in increasing order
+func sortedLandmarks(landmarks map[*Node]bool) []int {
+	sorted := make([]int, 0, len(landmarks))
+	for ld := range landmarks {
+		sorted = append(sorted, ld.Asn)
+	}
+	sort.Ints(sorted)
+	return sorted
+}
+
+// DistributedPreprocess simulates the computation of witnesses and bunches by
+// ASes exchanging landmark announcements with their neighbors, starting from
+// the elected Landmarks. The resulting state is compared with Witnesses and Bunches
+// (which must have been computed or loaded before)
+func (g *Graph) DistributedPreprocess() DistributedReport {
+	return g.distributedPreprocess(func(r *distributedRun, outbox []landmarkAnnouncement) {
+		r.converge(outbox)
+	})
+}
+
+// distributedPreprocess runs the protocol level by level, using 'converge' to
+// execute each phase
+func (g *Graph) distributedPreprocess(converge func(r *distributedRun, outbox []landmarkAnnouncement)) DistributedReport {
+
+	g.kIsValid()
+
+	report := DistributedReport{MessagesByLevel: make([]int, g.K)}
+
+	witnesses := make(map[int]map[int]*dijkstraNode)
+	bunches := make(map[int]map[int]*dijkstraNode)
+
+	// Nothing is closer than a missing landmark (e.g. level-k)
+	witnessDistance := func(asn int, round int) int64 {
+		if witness, exists := witnesses[asn][round]; exists {
+			return witness.distance
+		}
+		return int64Max
+	}
+
+	for i := g.K - 1; i >= 0; i-- {
+
+		// Clusters: the routes reach every AS, the ones closer to the landmark than
+		// to the witness of the next level are members
+		clusterRun := initDistributedRun(g.Nodes)
+
+		outbox := make([]landmarkAnnouncement, 0)
+		for _, w := range sortedLandmarks(g.Landmarks[i]) {
+			if _, inNextLevel := g.Landmarks[i+1][g.Nodes[w]]; !inNextLevel {
+				outbox = append(outbox, clusterRun.seed(w, w)...)
+			}
+		}
+		converge(clusterRun, outbox)
+
+		members := make(map[int]map[int]bool)
+		for asn, routes := range clusterRun.table {
+			members[asn] = make(map[int]bool)
+			for w, route := range routes {
+				if route.distance < witnessDistance(asn, i+1) {
+					members[asn][w] = true
+				}
+			}
+		}
+		clusterRun.joinClusters(members)
+
+		for asn, keys := range members {
+			if _, exists := bunches[asn]; !exists {
+				bunches[asn] = make(map[int]*dijkstraNode)
+			}
+			for w := range keys {
+				bunches[asn][w] = clusterRun.table[asn][w]
+			}
+		}
+
+		// Witnesses: a single entry (the closest landmark) per AS
+		witnessRun := initDistributedRun(g.Nodes)
+
+		outbox = make([]landmarkAnnouncement, 0)
+		for _, w := range sortedLandmarks(g.Landmarks[i]) {
+			outbox = append(outbox, witnessRun.seed(w, i)...)
+		}
+		converge(witnessRun, outbox)
+
+		for asn, routes := range witnessRun.table {
+			if _, exists := witnesses[asn]; !exists {
+				witnesses[asn] = make(map[int]*dijkstraNode)
+			}
+			if route, exists := routes[i]; exists {
+				witnesses[asn][i] = route
+			}
+		}
+
+		// Asterisk rule (local to each AS)
+		for _, routes := range witnesses {
+			if i+1 < g.K && routes[i] != nil && routes[i+1] != nil && routes[i].distance == routes[i+1].distance {
+				routes[i].parent = routes[i+1].parent
+				routes[i].nextHop = routes[i+1].nextHop
+			}
+		}
+
+		report.MessagesByLevel[i] = clusterRun.messages + witnessRun.messages
+		report.Messages += report.MessagesByLevel[i]
+		report.Rounds += clusterRun.rounds + witnessRun.rounds
+	}
+
+	// Compare with the centralized state
+	for i := 0; i < g.K; i++ {
+		for asn, central := range *g.Witnesses[i] {
+			if local, exists := witnesses[asn][i]; !exists || routeChanged(central, local) {
+				report.WitnessMismatches++
+			}
+		}
+	}
+
+	for asn, centralBunch := range g.Bunches {
+		for w, central := range centralBunch {
+			if local, exists := bunches[asn][w]; !exists || routeChanged(central, local) {
+				report.BunchMismatches++
+			}
+		}
+		for w := range bunches[asn] {
+			if _, exists := centralBunch[w]; !exists {
+				report.BunchMismatches++
+			}
+		}
+	}
+
+	return report
+}
@@ -0,0 +1,69 @@
+package tz
+
+import (
+	"testing"
+)
+
+func TestDistributedPreprocessMatchesCentralized(t *testing.T) {
+	for _, landmarks := range testLandmarks {
+		graph := loadTestGraph(t, landmarks)
+
+		report := graph.DistributedPreprocess()
+		if report.WitnessMismatches != 0 || report.BunchMismatches != 0 {
+			t.Errorf("k=%d: %d witness and %d bunch mismatches", graph.K, report.WitnessMismatches, report.BunchMismatches)
+		}
+		if report.Messages == 0 {
+			t.Errorf("k=%d: no message exchanged", graph.K)
+		}
+	}
+}
+
+// savedBunches are the bunches of data/test.csv with the landmarks of
+// data/test-witnesses.csv, computed before the zones of the frontier were expanded
+// in order (asn, landmark, distance, next-hop)
+var savedBunches = [][4]int{
+	{1, 1, 0, 1}, {1, 3, 1, 3}, {1, 4, 2, 3},
+	{2, 1, 1, 1}, {2, 2, 0, 2}, {2, 3, 2, 1}, {2, 4, 3, 7},
+	{3, 3, 0, 3}, {3, 4, 1, 4},
+	{4, 3, 1, 3}, {4, 4, 0, 4},
+	{5, 3, 1, 3}, {5, 4, 2, 3}, {5, 5, 0, 5},
+	{6, 3, 4, 7}, {6, 4, 1, 4}, {6, 6, 0, 6},
+	{7, 2, 1, 2}, {7, 3, 3, 2}, {7, 4, 2, 6}, {7, 6, 1, 6}, {7, 7, 0, 7},
+}
+
+// The expansion order of the frontier only breaks ties: the routes found without ties
+// do not change
+func TestPreprocessMatchesSavedState(t *testing.T) {
+	graph := loadTestGraph(t, [][]int{{1, 3, 4}, {3, 4}})
+
+	saved := InitGraph()
+	saved.K = graph.K
+	if err := LoadFromCsv(&saved, "../data/test.csv"); err != nil {
+		t.Fatal(err)
+	}
+	saved.LoadWitnessesFromCsv("../data/test-witnesses.csv")
+
+	for round := 0; round < graph.K; round++ {
+		if len(*graph.Witnesses[round]) != len(*saved.Witnesses[round]) {
+			t.Errorf("level %d: %d witnesses, %d saved", round, len(*graph.Witnesses[round]), len(*saved.Witnesses[round]))
+		}
+		for asn, witness := range *saved.Witnesses[round] {
+			if route := (*graph.Witnesses[round])[asn]; route == nil || routeChanged(witness, route) {
+				t.Errorf("level %d: witness of %d is %v, saved %v", round, asn, route, witness)
+			}
+		}
+	}
+
+	entries := 0
+	for _, bunch := range graph.Bunches {
+		entries += len(bunch)
+	}
+	if entries != len(savedBunches) {
+		t.Errorf("%d bunch entries, %d saved", entries, len(savedBunches))
+	}
+	for _, entry := range savedBunches {
+		if route := graph.Bunches[entry[0]][entry[1]]; route == nil || route.distance != int64(entry[2]) || route.nextHop.Asn != entry[3] {
+			t.Errorf("%d: bunch entry of %d is %v, saved %v", entry[0], entry[1], route, entry)
+		}
+	}
+}
@@ -0,0 +1,596 @@
+package tz
+
+import (
+	. "dedis.epfl.ch/core"
+	"dedis.epfl.ch/u"
+)
+
+// FailLandmark demotes a landmark to level 0 (as if the AS stopped acting as a landmark)
+// and repairs witnesses and bunches. If promote is set, the neighbor of the landmark
+// with the most links is promoted to the levels left by the failed landmark
+// returns true if the landmark was demoted
+// returns the set of asn impacted by the update
+// returns the TapeMeasure of the distance of invalidated witnesses from the landmark
+// (false, nil): the AS is not a landmark, or its level would remain empty
+func (g *Graph) FailLandmark(asn int, promote bool) (bool, map[int]bool, *TapeMeasure) {
+
+	failed, exists := g.Nodes[asn]
+	if !exists {
+		return false, nil, nil
+	}
+
+	top := g.landmarkLevel(asn)
+	if top == 0 {
+		return false, nil, nil
+	}
+
+	var replacement *Node
+	if promote {
+		replacement = g.pickReplacement(failed, top)
+	}
+
+	// Levels cannot remain empty, otherwise their witnesses are not defined
+	if replacement == nil && len(g.Landmarks[top]) <= 1 {
+		return false, nil, nil
+	}
+
+	impactedArea, impactMeasure, _ := g.relevel(failed, 0, replacement, top)
+
+	return true, impactedArea, impactMeasure
+}
+
+// relevel moves 'demoted' down to 'demotedLevel' and 'promoted' up to 'promotedLevel'
+// (any of them can be nil), then repairs witnesses and bunches
+// returns the set of asn impacted by the update, the measure of invalidated witnesses
+// and the number of rebuilt clusters
+func (g *Graph) relevel(demoted *Node, demotedLevel int, promoted *Node, promotedLevel int) (map[int]bool, *TapeMeasure, int) {
+
+	demotedTop, promotedBottom := 0, 0
+
+	var origin *Node
+	if demoted != nil {
+		origin = demoted
+		demotedTop = g.landmarkLevel(demoted.Asn)
+		for lvl := demotedLevel + 1; lvl <= demotedTop; lvl++ {
+			g.saveLandmark(lvl, demoted)
+			delete(g.Landmarks[lvl], demoted)
+		}
+	}
+	if promoted != nil {
+		if origin == nil {
+			origin = promoted
+		}
+		promotedBottom = g.landmarkLevel(promoted.Asn)
+		for lvl := promotedBottom + 1; lvl <= promotedLevel; lvl++ {
+			g.saveLandmark(lvl, promoted)
+			g.Landmarks[lvl][promoted] = true
+		}
+	}
+
+	impactedArea := make(map[int]bool)
+
+	tempMeasure := InitMeasure(origin.Asn)
+	impactMeasure := &tempMeasure
+
+	// changedByRound[r] contains the previous witness distance of the nodes whose
+	// distance to A_r changed
+	changedByRound := make(map[int]map[int]int64)
+
+	top := demotedTop
+	if promoted != nil && promotedLevel > top {
+		top = promotedLevel
+	}
+
+	// Fix Witnesses
+	for round := top; round >= 1; round-- {
+		var failed, seed *Node
+		if demoted != nil && round > demotedLevel && round <= demotedTop {
+			failed = demoted
+		}
+		if promoted != nil && round > promotedBottom && round <= promotedLevel {
+			seed = promoted
+		}
+
+		if failed != nil || seed != nil {
+			repaired, changed, measure := g.repairWitnessRound(origin, failed, seed, round)
+
+			impactMeasure = Combine(impactMeasure, &measure)
+			impactedArea = u.Union(impactedArea, repaired)
+			changedByRound[round] = changed
+		}
+
+		g.enforceAsteriskRule(round)
+	}
+	// Round 0 copies the witnesses of round 1 for ASes at the same distance
+	g.enforceAsteriskRule(0)
+
+	// Fix Bunches: the clusters of the moved landmarks change level,
+	// the clusters of lower levels are bounded by the repaired witnesses
+	toRecompute := make(map[int]*Node)
+	for _, moved := range []*Node{demoted, promoted} {
+		if moved != nil {
+			toRecompute[moved.Asn] = moved
+		}
+	}
+
+	for round, changed := range changedByRound {
+		for w, nd := range g.nearbyLandmarks(changed, round-1) {
+			toRecompute[w] = nd
+		}
+	}
+
+	for _, w := range toRecompute {
+		impactedArea = u.Union(impactedArea, g.replaceCluster(w))
+	}
+
+	return impactedArea, impactMeasure, len(toRecompute)
+}
+
+// RemoveNode deletes an AS (and all its links) from the graph
+// If the AS is a landmark, it is demoted first (see FailLandmark)
+// The removal is journaled, so that a refused removal leaves the graph unchanged
+// returns values with the same meaning of RemoveEdge: if the removal split the graph,
+// the ASes cut off from their component are returned
+func (g *Graph) RemoveNode(asn int, promote bool) (bool, map[int]bool, *TapeMeasure) {
+	success, impactedArea, impactMeasure, _ := g.RemoveNodeWithTrace(asn, promote)
+	return success, impactedArea, impactMeasure
+}
+
+// RemoveNodeWithTrace behaves like RemoveNode, but it also returns the trace of the
+// update messages caused by the deletion of the links and by the withdrawal of the
+// routes towards the AS (the demotion of a landmark is not traced)
+func (g *Graph) RemoveNodeWithTrace(asn int, promote bool) (bool, map[int]bool, *TapeMeasure, *RepairTrace) {
+
+	removed, exists := g.Nodes[asn]
+	if !exists {
+		return false, nil, nil, nil
+	}
+
+	// Neighbors having a single link would be isolated
+	for _, l := range removed.Links {
+		if len(g.Nodes[l].Links) <= 1 {
+			return false, nil, nil, nil
+		}
+	}
+
+	outer := g.beginNested()
+
+	trace := &RepairTrace{}
+
+	impactedArea := make(map[int]bool)
+	disconnected := make(map[int]bool)
+
+	tempMeasure := InitMeasure(asn)
+	impactMeasure := &tempMeasure
+
+	if g.landmarkLevel(asn) > 0 {
+		success, failArea, failMeasure := g.FailLandmark(asn, promote)
+		if !success {
+			g.endNested(outer, false)
+			return false, nil, nil, nil
+		}
+		impactedArea = u.Union(impactedArea, failArea)
+		impactMeasure = Combine(impactMeasure, failMeasure)
+	}
+
+	// Detach the AS one link at a time, until it becomes a leaf
+	neighbors := make([]int, len(removed.Links))
+	copy(neighbors, removed.Links)
+
+	for _, l := range neighbors[:len(neighbors)-1] {
+		success, edgeArea, edgeMeasure, edgeTrace := g.RemoveEdgeWithTrace(asn, l)
+		if !success && len(edgeArea) == 0 {
+			g.endNested(outer, false)
+			return false, nil, nil, nil
+		}
+		trace.Append(edgeTrace)
+		if !success {
+			disconnected = u.Union(disconnected, edgeArea)
+			continue
+		}
+		impactedArea = u.Union(impactedArea, edgeArea)
+		impactMeasure = Combine(impactMeasure, edgeMeasure)
+	}
+
+	// A leaf is only used by the routes towards itself
+	for asnInCluster := range g.clusterMembers(asn) {
+		if asnInCluster != asn {
+			trace.invalidate(g.Nodes[asnInCluster], -1, asn)
+		}
+		g.saveBunch(asnInCluster, asn)
+		delete(g.Bunches[asnInCluster], asn)
+		impactedArea[asnInCluster] = true
+	}
+
+	for round, witnesses := range g.Witnesses {
+		g.saveWitness(round, asn)
+		delete(*witnesses, asn)
+	}
+
+	for w := range g.Bunches[asn] {
+		g.saveBunch(asn, w)
+	}
+	delete(g.Bunches, asn)
+	g.saveLandmark(0, removed)
+	delete(g.Landmarks[0], removed)
+
+	lastLink := g.Nodes[removed.Links[0]]
+	g.saveLinks(lastLink)
+	g.saveLinks(removed)
+	if !lastLink.DeleteLink(removed) {
+		panic("Link deletion unsuccessful! Corrupted graph")
+	}
+	impactedArea[lastLink.Asn] = true
+
+	delete(g.Nodes, asn)
+	g.saveComponent(asn)
+	delete(g.Components, asn)
+	delete(impactedArea, asn)
+
+	g.endNested(outer, true)
+
+	// The area of the links detached before a split is kept as well
+	trace.ImpactedArea = u.Union(u.Union(make(map[int]bool), trace.ImpactedArea), impactedArea)
+	delete(trace.ImpactedArea, asn)
+
+	if len(disconnected) > 0 {
+		delete(disconnected, asn)
+		return false, disconnected, nil, trace
+	}
+
+	return true, impactedArea, impactMeasure, trace
+}
+
+// pickReplacement chooses the neighbor that replaces a failed landmark of level 'top'
+// (the one with the most links, among the ones that are not already in A_top)
+// returns nil if no neighbor can be promoted
+func (g *Graph) pickReplacement(failed *Node, top int) *Node {
+	var replacement *Node
+
+	for _, l := range failed.Links {
+		candidate := g.Nodes[l]
+		if _, isLandmark := g.Landmarks[top][candidate]; isLandmark {
+			continue
+		}
+		if replacement == nil ||
+			len(candidate.Links) > len(replacement.Links) ||
+			(len(candidate.Links) == len(replacement.Links) && candidate.Asn < replacement.Asn) {
+			replacement = candidate
+		}
+	}
+
+	return replacement
+}
+
+// repairWitnessRound restores the witnesses of a round after 'failed' left A_round
+// and 'seed' joined it (any of them can be nil)
+// The routes to the failed landmark and the ones that the seed improves are invalidated
+// (along with the routes passing through them), then they are computed again
+// returns the set of asn needed to complete the operation, the previous distance of
+// the nodes whose witness distance changed and the measure of the distance of
+// invalidated nodes from the moved landmarks
+func (g *Graph) repairWitnessRound(origin *Node, failed *Node, seed *Node, round int) (map[int]bool, map[int]int64, TapeMeasure) {
+
+	impactMeasure := InitMeasure(origin.Asn)
+
+	invalid := make(map[int]int)
+	if failed != nil {
+		invalid[failed.Asn] = 0
+	}
+	if seed != nil {
+		// Nodes without a witness (in a separated component) are improved as well
+		for asn, distance := range g.closerToSeed(seed, g.Witnesses[round]) {
+			invalid[asn] = 0
+			impactMeasure[asn] = distance
+		}
+	}
+
+	impactedAsn, changed, _ := g.rebuildWitnesses(round, invalid, seed, []*TapeMeasure{&impactMeasure})
+
+	return impactedAsn, changed, impactMeasure
+}
+
+// rebuildWitnesses invalidates the witnesses of a round for the 'invalid' nodes (along
+// with the routes passing through them), then it computes them again, starting
+// from the nodes around them and from 'seed' (if it is not nil)
+// 'invalid' maps each node to the cause of its invalidation (an index of 'measures'):
+// the nodes invalidated by the propagation inherit the cause, and extend its measure
+// returns the set of asn needed to complete the operation, the previous distance of
+// the nodes whose witness distance changed and the cause of each asn in the set
+func (g *Graph) rebuildWitnesses(round int, invalid map[int]int, seed *Node, measures []*TapeMeasure) (map[int]bool, map[int]int64, map[int]int) {
+
+	witnesses := g.Witnesses[round]
+
+	toUpdateZone := make(map[int]*Node)
+
+	// Distances before the repair (to detect changes)
+	previous := make(map[int]int64)
+
+	invalidate := func(asn int) {
+		toUpdateZone[asn] = g.Nodes[asn]
+		previous[asn] = int64Max
+		if witness, hasWitness := (*witnesses)[asn]; hasWitness {
+			previous[asn] = witness.distance
+			g.saveWitness(round, asn)
+			delete(*witnesses, asn)
+		}
+	}
+
+	cause := make(map[int]int)
+
+	addedInRound := make(map[int]bool)
+	for asn, c := range invalid {
+		invalidate(asn)
+		cause[asn] = c
+		addedInRound[asn] = true
+	}
+
+	// Invalidate the routes passing through invalidated nodes
+	for len(addedInRound) > 0 {
+		nextAdded := make(map[int]bool)
+		for a := range addedInRound {
+			for _, n := range g.Nodes[a].Links {
+				if witness, stillThere := (*witnesses)[n]; stillThere && witness.nextHop.Asn == a {
+					invalidate(n)
+					cause[n] = cause[a]
+					nextAdded[n] = true
+					measures[cause[a]].Extend(a, n)
+				}
+			}
+		}
+		addedInRound = nextAdded
+	}
+
+	frontier := Frontier{
+		Zones:       make(map[int64]map[int]*dijkstraNode),
+		MinDistance: int64Max,
+	}
+
+	frontierPopulation := 0
+
+	if seed != nil {
+		seedWitness := dijkstraNode{
+			reference: seed.Asn,
+			distance:  0,
+			parent:    seed,
+			nextHop:   seed,
+		}
+		g.saveWitness(round, seed.Asn)
+		(*witnesses)[seed.Asn] = &seedWitness
+		if frontier.addToFrontier(&seedWitness) {
+			frontierPopulation++
+		}
+	}
+
+	stillHavingWitness := make(map[int]*Node)
+	for toUp := range toUpdateZone {
+		for _, l := range g.Nodes[toUp].Links {
+			dijNode, hasWitness := (*witnesses)[l]
+			_, alreadyMarked := stillHavingWitness[l]
+			if !alreadyMarked && hasWitness {
+				stillHavingWitness[l] = g.Nodes[l]
+				if _, hasCause := cause[l]; !hasCause {
+					cause[l] = cause[toUp]
+				}
+				if _, measured := previous[l]; !measured {
+					previous[l] = dijNode.distance
+				}
+				if frontier.addToFrontier(dijNode) {
+					frontierPopulation++
+				}
+			}
+		}
+	}
+
+	for hwAsn, hwNode := range stillHavingWitness {
+		toUpdateZone[hwAsn] = hwNode
+	}
+
+	g.saveWitnesses(round, toUpdateZone)
+	witnesses.runDijkstra(&toUpdateZone, &frontier, frontierPopulation, nil)
+
+	impactedAsn := make(map[int]bool)
+	changed := make(map[int]int64)
+	for asn, distance := range previous {
+		current := int64Max
+		if route, exists := (*witnesses)[asn]; exists {
+			current = route.distance
+		}
+		if current != distance {
+			changed[asn] = distance
+		}
+	}
+	for asn := range toUpdateZone {
+		impactedAsn[asn] = true
+	}
+
+	return impactedAsn, changed, cause
+}
+
+// closerToSeed returns the nodes that would be closer to 'seed' than to their witness
+// (nodes without a witness are included), along with their distance from the seed
+// Only the area around the seed that can be closer to it is explored (see landmarkArea)
+func (g *Graph) closerToSeed(seed *Node, witnesses *DijkstraGraph) map[int]int64 {
+	seedGraph := make(DijkstraGraph)
+
+	source := dijkstraNode{
+		reference: seed.Asn,
+		distance:  0,
+		parent:    seed,
+		nextHop:   seed,
+	}
+	seedGraph[seed.Asn] = &source
+
+	seedFrontier := Frontier{
+		Zones:       make(map[int64]map[int]*dijkstraNode),
+		MinDistance: 0,
+	}
+	seedFrontier.Zones[0] = map[int]*dijkstraNode{source.reference: &source}
+
+	area := g.landmarkArea(seed, witnesses)
+	seedGraph.runDijkstra(&area, &seedFrontier, 1, nil)
+
+	closer := make(map[int]int64)
+	for asn, fromSeed := range seedGraph {
+		if witness, hasWitness := (*witnesses)[asn]; !hasWitness || fromSeed.distance < witness.distance {
+			closer[asn] = fromSeed.distance
+		}
+	}
+
+	return closer
+}
+
+// nearbyLandmarks returns the landmarks of level 'level' (and not above) whose cluster
+// can change because the distance to A_(level+1) of some nodes changed:
+//   - a node whose witness got closer can only leave the clusters it is no longer
+//     close enough to (they are found in its bunch)
+//   - a node whose witness got farther can join the clusters of the landmarks that
+//     are closer (in hops) than its current witness distance
+func (g *Graph) nearbyLandmarks(changed map[int]int64, level int) map[int]*Node {
+
+	candidates := make(map[int]*Node)
+
+	// budget[asn] is the number of hops that can still be walked from asn
+	budget := make(map[int]int64)
+	visiting := make([]int, 0, len(changed))
+
+	for asn, previousDistance := range changed {
+		radius := int64Max
+		if current, exists := (*g.Witnesses[level+1])[asn]; exists {
+			radius = current.distance
+		}
+
+		if radius < previousDistance {
+			for w, route := range g.Bunches[asn] {
+				if route.distance >= radius && g.landmarkLevel(w) == level {
+					candidates[w] = g.Nodes[w]
+				}
+			}
+			continue
+		}
+
+		if old, seen := budget[asn]; !seen || radius-EdgeWeight > old {
+			budget[asn] = radius - EdgeWeight
+			visiting = append(visiting, asn)
+		}
+	}
+
+	for len(visiting) > 0 {
+		next := make([]int, 0)
+		for _, asn := range visiting {
+			if budget[asn] < EdgeWeight {
+				continue
+			}
+			for _, l := range g.Nodes[asn].Links {
+				if old, seen := budget[l]; !seen || budget[asn]-EdgeWeight > old {
+					budget[l] = budget[asn] - EdgeWeight
+					next = append(next, l)
+				}
+			}
+		}
+		visiting = next
+	}
+
+	for asn, left := range budget {
+		if left >= 0 && g.landmarkLevel(asn) == level {
+			candidates[asn] = g.Nodes[asn]
+		}
+	}
+
+	return candidates
+}
+
+// clusterMembers returns the set of nodes having w in their bunch
+// (the cluster of w is connected, so it can be explored from w)
+func (g *Graph) clusterMembers(w int) map[int]bool {
+	members := make(map[int]bool)
+
+	if _, inOwnBunch := g.Bunches[w][w]; !inOwnBunch {
+		return members
+	}
+
+	members[w] = true
+	toVisit := []int{w}
+	for len(toVisit) > 0 {
+		a := toVisit[len(toVisit)-1]
+		toVisit = toVisit[:len(toVisit)-1]
+		for _, n := range g.Nodes[a].Links {
+			if _, inCluster := g.Bunches[n][w]; inCluster && !members[n] {
+				members[n] = true
+				toVisit = append(toVisit, n)
+			}
+		}
+	}
+
+	return members
+}
+
+// landmarkArea returns the nodes around w that can be closer to it than to their
+// witness: the area grows from w, one hop at a time, as long as some of the nodes
+// at the current hop distance are closer to w than to their witness
+// (nodes without a witness are always closer)
+func (g *Graph) landmarkArea(w *Node, witnesses *DijkstraGraph) map[int]*Node {
+	area := map[int]*Node{w.Asn: w}
+	layer := []*Node{w}
+
+	for hops := int64(0); len(layer) > 0; hops += EdgeWeight {
+		growing := false
+		for _, nd := range layer {
+			if witness, exists := (*witnesses)[nd.Asn]; !exists || hops < witness.distance {
+				growing = true
+				break
+			}
+		}
+		if !growing {
+			break
+		}
+
+		next := make([]*Node, 0)
+		for _, nd := range layer {
+			for _, l := range nd.Links {
+				if _, inArea := area[l]; !inArea {
+					area[l] = g.Nodes[l]
+					next = append(next, g.Nodes[l])
+				}
+			}
+		}
+		layer = next
+	}
+
+	return area
+}
+
+// replaceCluster recomputes the cluster of w according to its current level
+// Only the area that can belong to the cluster is explored (see landmarkArea)
+// returns the set of asn whose bunch changed
+func (g *Graph) replaceCluster(w *Node) map[int]bool {
+	level := g.landmarkLevel(w.Asn)
+
+	area := g.landmarkArea(w, g.Witnesses[level+1])
+
+	updated := make(Clusters)
+	updated.calculateCluster(&area, w, g.Witnesses[level+1])
+
+	changedAsn := make(map[int]bool)
+
+	for asn := range g.clusterMembers(w.Asn) {
+		if _, stillInCluster := updated[w.Asn][asn]; !stillInCluster {
+			g.saveBunch(asn, w.Asn)
+			delete(g.Bunches[asn], w.Asn)
+			changedAsn[asn] = true
+		}
+	}
+
+	for asn, route := range updated[w.Asn] {
+		if previous, exists := g.Bunches[asn][w.Asn]; !exists || routeChanged(previous, route) {
+			changedAsn[asn] = true
+		}
+		g.saveBunch(asn, w.Asn)
+		if _, exists := g.Bunches[asn]; !exists {
+			g.Bunches[asn] = make(map[int]*dijkstraNode)
+		}
+		g.Bunches[asn][w.Asn] = route
+	}
+
+	return changedAsn
+}
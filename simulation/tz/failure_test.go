@@ -0,0 +1,127 @@
+package tz
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// graphState describes the links, landmarks, witnesses and bunches of every AS
+func graphState(g *Graph) string {
+	asns := make([]int, 0, len(g.Nodes))
+	for asn := range g.Nodes {
+		asns = append(asns, asn)
+	}
+	sort.Ints(asns)
+
+	var state strings.Builder
+	for _, asn := range asns {
+		fmt.Fprintf(&state, "%d: links %v %v, level %d, component %d\n", asn, g.Nodes[asn].Links, g.Nodes[asn].Type, g.landmarkLevel(asn), g.Components[asn])
+		for round := 0; round < g.K; round++ {
+			if witness, exists := (*g.Witnesses[round])[asn]; exists {
+				fmt.Fprintf(&state, "  witness %d: %s\n", round, witness)
+			}
+		}
+		landmarks := make([]int, 0, len(g.Bunches[asn]))
+		for w := range g.Bunches[asn] {
+			landmarks = append(landmarks, w)
+		}
+		sort.Ints(landmarks)
+		for _, w := range landmarks {
+			fmt.Fprintf(&state, "  bunch %d: %s\n", w, g.Bunches[asn][w])
+		}
+	}
+
+	return state.String()
+}
+
+func TestRemoveNodeIsAtomic(t *testing.T) {
+	graph := loadTestGraph(t, [][]int{{2, 4, 6}, {4}})
+	graph.AtomicDeletions = true
+
+	if success, _, _ := graph.RemoveNode(1, true); !success {
+		t.Fatal("removal of 1 refused")
+	}
+
+	// 6 is a landmark, and removing it separates {2, 7} from {3, 4, 5}
+	before := graphState(graph)
+	if success, area, _ := graph.RemoveNode(6, true); success || area != nil {
+		t.Fatalf("removal of 6 performed (%v)", area)
+	}
+	if after := graphState(graph); after != before {
+		t.Errorf("refused removal changed the graph:\n%s\nexpected:\n%s", after, before)
+	}
+}
+
+func TestRemoveNodeRollsBackWithTransaction(t *testing.T) {
+	graph := loadTestGraph(t, [][]int{{2, 4, 6}, {4}})
+	before := graphState(graph)
+
+	graph.Begin()
+	if success, _, _ := graph.RemoveNode(1, true); !success {
+		t.Fatal("removal of 1 refused")
+	}
+	if success, separated, _ := graph.RemoveNode(6, true); success || len(separated) == 0 || separated[6] {
+		t.Errorf("removal of 6 returned %v, %v", success, separated)
+	}
+	graph.Rollback()
+
+	if after := graphState(graph); after != before {
+		t.Errorf("rollback did not restore the graph:\n%s\nexpected:\n%s", after, before)
+	}
+}
+
+func TestRemoveEdgeRollsBackWithTransaction(t *testing.T) {
+	graph := loadTestGraph(t, [][]int{{2, 4, 6}, {4}})
+	before := graphState(graph)
+
+	graph.Begin()
+	if success, _, _ := graph.RemoveEdge(3, 4); !success {
+		t.Fatal("removal of 3-4 refused")
+	}
+	if during := graphState(graph); during == before {
+		t.Fatal("removal of 3-4 did not change the graph")
+	}
+	graph.Rollback()
+
+	if after := graphState(graph); after != before {
+		t.Errorf("rollback did not restore the graph:\n%s\nexpected:\n%s", after, before)
+	}
+}
+
+func TestAddEdgeRollsBackWithTransaction(t *testing.T) {
+	graph := loadTestGraph(t, [][]int{{2, 4, 6}, {4}})
+	before := graphState(graph)
+
+	graph.Begin()
+	if success, _, _ := graph.AddEdge(5, 7, 1); !success {
+		t.Fatal("insertion of 5-7 refused")
+	}
+	if during := graphState(graph); during == before {
+		t.Fatal("insertion of 5-7 did not change the graph")
+	}
+	graph.Rollback()
+
+	if after := graphState(graph); after != before {
+		t.Errorf("rollback did not restore the graph:\n%s\nexpected:\n%s", after, before)
+	}
+}
+
+func TestFailLandmarkKeepsRoutesConsistent(t *testing.T) {
+	// 30 and 40 are the top-level landmarks of data/tie.csv. When 30 fails, its neighbor
+	// 10 is promoted: 20 is then as far from 10 as from 40, and 50 reaches them through 20
+	graph := loadGraphFile(t, "../data/tie.csv", [][]int{{30, 40}})
+
+	if success, _, _ := graph.FailLandmark(30, true); !success {
+		t.Fatal("failure of 30 refused")
+	}
+
+	witnesses := *graph.Witnesses[1]
+	for asn, witness := range witnesses {
+		// The next-hop leads to the same landmark
+		if next := witnesses[witness.nextHop.Asn]; asn != witness.parent.Asn && next.parent != witness.parent {
+			t.Errorf("%d reaches %d through %d, that reaches %d", asn, witness.parent.Asn, witness.nextHop.Asn, next.parent.Asn)
+		}
+	}
+}
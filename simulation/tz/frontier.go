@@ -2,6 +2,7 @@ package tz
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	. "dedis.epfl.ch/core"
@@ -12,6 +13,9 @@ import (
 type Frontier struct {
 	Zones       map[int64]map[int]*dijkstraNode
 	MinDistance int64
+	// order lists the nodes of the zones being expanded by increasing ASN, so that
+	// the routes found on ties do not depend on the order of the maps
+	order map[int64][]int
 }
 
 func (f *Frontier) String() string {
@@ -32,10 +36,30 @@ func (f *Frontier) printFrontier() {
 }
 
 func (f *Frontier) getSomeNode(distance int64) *dijkstraNode {
-	for _, k := range f.Zones[distance] {
-		return k
+	if f.order == nil {
+		f.order = make(map[int64][]int)
 	}
 
+	order, sorted := f.order[distance]
+	if !sorted {
+		order = make([]int, 0, len(f.Zones[distance]))
+		for asn := range f.Zones[distance] {
+			order = append(order, asn)
+		}
+		sort.Ints(order)
+	}
+
+	// Nodes moved to another zone since the sort are skipped
+	for len(order) > 0 {
+		k, inZone := f.Zones[distance][order[0]]
+		order = order[1:]
+		if inZone {
+			f.order[distance] = order
+			return k
+		}
+	}
+	delete(f.order, distance)
+
 	panic(fmt.Sprintf("The minimum distance Zone (%d) of the frontier was empty", distance))
 }
 
@@ -68,6 +92,7 @@ func (f *Frontier) addToFrontier(n *dijkstraNode) bool {
 	}
 
 	f.Zones[n.distance][n.reference] = n
+	delete(f.order, n.distance)
 
 	if n.distance < f.MinDistance {
 		f.MinDistance = n.distance
@@ -89,6 +114,7 @@ func (f *Frontier) deleteFromFrontier(n *dijkstraNode) bool {
 
 	if len(f.Zones[f.MinDistance]) == 0 {
 		delete(f.Zones, f.MinDistance)
+		delete(f.order, f.MinDistance)
 
 		var newMin int64 = int64Max
 		// Search new minDistance
@@ -2,10 +2,9 @@ package tz
 
 import (
 	"fmt"
-	"math/rand"
-	"time"
 
 	. "dedis.epfl.ch/core"
+	"dedis.epfl.ch/shell"
 	"dedis.epfl.ch/u"
 )
 
@@ -19,15 +18,25 @@ type Graph struct {
 	Landmarks Landmarks
 	Witnesses map[int]*DijkstraGraph
 	Bunches   Clusters
+	// ValleyFree restricts ApproximatePath to paths respecting the no-valley rule
+	ValleyFree bool
+	// AtomicDeletions makes RemoveEdge refuse (leaving the graph untouched)
+	// the deletions that would disconnect the graph
+	AtomicDeletions bool
+	// Components maps each AS to its connected component (0 until the graph is split)
+	Components map[int]int
+	// journal is nil outside transactions
+	journal *undoLog
 }
 
 // InitGraph returns a fresh graph
 func InitGraph() Graph {
 	return Graph{
-		Nodes:     make(map[int]*Node),
-		Landmarks: make(Landmarks),
-		Witnesses: make(map[int]*DijkstraGraph),
-		Bunches:   make(Clusters),
+		Nodes:      make(map[int]*Node),
+		Landmarks:  make(Landmarks),
+		Witnesses:  make(map[int]*DijkstraGraph),
+		Bunches:    make(Clusters),
+		Components: make(map[int]int),
 	}
 }
 
@@ -63,7 +72,7 @@ func (g *Graph) ElectLandmarks(selectionStrategy int) {
 		panic("The number of landmark sets must be >= 1, got " + u.Str(g.K))
 	}
 
-	rand.Seed(time.Now().UnixNano())
+	u.SeedRandom()
 
 	switch selectionStrategy {
 	case RandomStrategy:
@@ -101,7 +110,11 @@ func (g *Graph) calculateWitnessForRound(round int) *DijkstraGraph {
 		frontierPopulation++
 	}
 
-	dijkstraGraph.runDijkstra(&g.Nodes, &frontier, frontierPopulation)
+	// Every node is expanded once
+	tracker := shell.StartTask("Witnesses of level "+u.Str(round), len(g.Nodes))
+	defer tracker.Finish()
+
+	dijkstraGraph.runDijkstra(&g.Nodes, &frontier, frontierPopulation, tracker)
 
 	return &dijkstraGraph
 }
@@ -128,9 +141,6 @@ func (g *Graph) Preprocess() {
 	clusters := make(Clusters)
 
 	for i := g.K - 1; i >= 0; i-- {
-
-		fmt.Printf("Starting round... %d\n", i)
-
 		clusters.calculateClustersForRound(&g.Nodes, i, &g.Landmarks, g.Witnesses[i+1])
 		g.Witnesses[i] = g.calculateWitnessForRound(i)
 
@@ -153,6 +163,9 @@ func (g *Graph) enforceAsteriskRule(round int) {
 	for asn := range *g.Witnesses[round] {
 		prevDijkstraNode, exists := (*g.Witnesses[round+1])[asn]
 		if exists && (*g.Witnesses[round])[asn].distance == prevDijkstraNode.distance {
+			if (*g.Witnesses[round])[asn].parent != prevDijkstraNode.parent || (*g.Witnesses[round])[asn].nextHop != prevDijkstraNode.nextHop {
+				g.saveWitness(round, asn)
+			}
 			(*g.Witnesses[round])[asn].parent = prevDijkstraNode.parent
 			(*g.Witnesses[round])[asn].nextHop = prevDijkstraNode.nextHop
 		}
@@ -221,10 +234,25 @@ func (g *Graph) printPath(a int, w int, b int, round int) {
 	fmt.Printf("%d\n", hops[len(hops)-1])
 }
 
+// NoValleyFreePath is the level returned by ApproximatePath when the graph is
+// ValleyFree and no concatenation respects the no-valley rule
+const NoValleyFreePath = -2
+
 // ApproximatePath compute an approximation of the path from 'from' to 'to'
 // It returns the level of landmarks used and a path
+// If the graph is ValleyFree, only the shortest valley-free concatenation is returned
+// returns (NoValleyFreePath, nil) if there is none
+// returns (-1, nil) if the two ASes are in different connected components
 func (g *Graph) ApproximatePath(from int, to int) (int, []int) {
 
+	if !g.Reachable(from, to) {
+		return -1, nil
+	}
+
+	if g.ValleyFree {
+		return g.approximateValleyFreePath(from, to)
+	}
+
 	var w int = from
 	var i int = 0
 
@@ -255,11 +283,18 @@ func (g *Graph) ApproximatePath(from int, to int) (int, []int) {
 // Copy returns a duplicate of the Graph
 func (g *Graph) Copy() AbstractGraph {
 	copyGraph := Graph{
-		Nodes:     make(map[int]*Node),
-		K:         g.K,
-		Landmarks: nil,
-		Witnesses: make(map[int]*DijkstraGraph),
-		Bunches:   make(Clusters),
+		Nodes:           make(map[int]*Node),
+		K:               g.K,
+		Landmarks:       nil,
+		Witnesses:       make(map[int]*DijkstraGraph),
+		Bunches:         make(Clusters),
+		ValleyFree:      g.ValleyFree,
+		AtomicDeletions: g.AtomicDeletions,
+		Components:      make(map[int]int),
+	}
+
+	for asn, c := range g.Components {
+		copyGraph.Components[asn] = c
 	}
 
 	for k, v := range g.Nodes {
@@ -280,11 +315,18 @@ func (g *Graph) Copy() AbstractGraph {
 // CopyAsTz returns a duplicate of the tz.Graph
 func (g *Graph) CopyAsTz() *Graph {
 	copyGraph := Graph{
-		Nodes:     make(map[int]*Node),
-		K:         g.K,
-		Landmarks: nil,
-		Witnesses: make(map[int]*DijkstraGraph),
-		Bunches:   make(Clusters),
+		Nodes:           make(map[int]*Node),
+		K:               g.K,
+		Landmarks:       nil,
+		Witnesses:       make(map[int]*DijkstraGraph),
+		Bunches:         make(Clusters),
+		ValleyFree:      g.ValleyFree,
+		AtomicDeletions: g.AtomicDeletions,
+		Components:      make(map[int]int),
+	}
+
+	for asn, c := range g.Components {
+		copyGraph.Components[asn] = c
 	}
 
 	for k, v := range g.Nodes {
@@ -306,26 +348,44 @@ func (g *Graph) CopyAsTz() *Graph {
 // relevant data structures
 // returns true if the deletion was successful
 // returns the number of nodes impacted by the update
-// (false, 0) : the deletion could not be performed
-// (false, >0): the deletion was performed but the graph is NO MORE 1 connected component
+// (false, 0) : the deletion could not be performed (or would disconnect an AtomicDeletions graph)
+// (false, >0): the deletion was performed but the graph is NO MORE 1 connected component,
+// the nodes of the separated component are returned (every component is repaired separately)
 // returns the combined TapeMeasure
 func (g *Graph) RemoveEdge(aAsn int, bAsn int) (bool, map[int]bool, *TapeMeasure) {
+	success, impactedArea, impactMeasure, _ := g.RemoveEdgeWithTrace(aAsn, bAsn)
+	return success, impactedArea, impactMeasure
+}
+
+// RemoveEdgeWithTrace behaves like RemoveEdge, but it also returns the trace of
+// the update messages that a distributed repair would need
+func (g *Graph) RemoveEdgeWithTrace(aAsn int, bAsn int) (bool, map[int]bool, *TapeMeasure, *RepairTrace) {
 
 	a, aOk := g.Nodes[aAsn]
 	b, bOk := g.Nodes[bAsn]
 
 	if !(aOk && bOk) {
-		return false, nil, nil
+		return false, nil, nil, nil
 	}
 
 	if len(a.Links) <= 1 || len(b.Links) <= 1 {
-		return false, nil, nil
+		return false, nil, nil, nil
 	}
 
+	separated := g.separatedBy(a, b)
+	if separated != nil && g.AtomicDeletions {
+		return false, nil, nil, nil
+	}
+
+	g.saveLinks(a)
+	g.saveLinks(b)
+
 	if !(a.DeleteLink(b) && b.DeleteLink(a)) {
 		panic("Link deletion unsuccessful! Corrupted graph")
 	}
 
+	trace := &RepairTrace{}
+
 	impactedArea := make(map[int]bool)
 
 	tempWitnessMeasure := InitMeasure(aAsn)
@@ -333,8 +393,8 @@ func (g *Graph) RemoveEdge(aAsn int, bAsn int) (bool, map[int]bool, *TapeMeasure
 
 	// Fix Witnesses
 	for round := g.K - 1; round >= 0; round-- {
-		fixWitFromA, witnessFromA := g.fixWitnessByRound(a, b, round)
-		fixWitFromB, witnessFromB := g.fixWitnessByRound(b, a, round)
+		fixWitFromA, witnessFromA := g.fixWitnessByRound(a, b, round, trace)
+		fixWitFromB, witnessFromB := g.fixWitnessByRound(b, a, round, trace)
 
 		impactMeasure = Combine(impactMeasure, &witnessFromA)
 		impactMeasure = Combine(impactMeasure, &witnessFromB)
@@ -346,8 +406,8 @@ func (g *Graph) RemoveEdge(aAsn int, bAsn int) (bool, map[int]bool, *TapeMeasure
 		g.enforceAsteriskRule(round)
 	}
 
-	fixBunFromA, tapeMeasureFromA := g.fixBunches(a, b)
-	fixBunFromB, tapeMeasureFromB := g.fixBunches(b, a)
+	fixBunFromA, tapeMeasureFromA := g.fixBunches(a, b, trace)
+	fixBunFromB, tapeMeasureFromB := g.fixBunches(b, a, trace)
 
 	impactMeasure = Combine(impactMeasure, &tapeMeasureFromA)
 	impactMeasure = Combine(impactMeasure, &tapeMeasureFromB)
@@ -355,27 +415,34 @@ func (g *Graph) RemoveEdge(aAsn int, bAsn int) (bool, map[int]bool, *TapeMeasure
 	impactedArea = u.Union(impactedArea, fixBunFromA)
 	impactedArea = u.Union(impactedArea, fixBunFromB)
 
-	disconnectedNodes := make(map[int]bool)
+	trace.ImpactedArea = impactedArea
 
-	// Check that the graph is still connected
-	for ia := range impactedArea {
-		if len(g.Bunches[ia]) < len(g.Landmarks[g.K-1]) {
-			disconnectedNodes[ia] = true
-		}
-	}
-
-	if len(disconnectedNodes) > 0 {
+	if separated != nil {
 		// If there are several connected components,
 		// return the disconnected nodes
-		return false, disconnectedNodes, nil
+		// The landmarks promoted to the top level of a component are announced
+		// to the ASes impacted by the promotion
+		topLevel := make(map[int]bool)
+		for nd := range g.Landmarks[g.K-1] {
+			topLevel[nd.Asn] = true
+		}
+		for asn := range g.splitComponent(separated) {
+			for w, route := range g.Bunches[asn] {
+				if !topLevel[w] && g.landmarkLevel(w) == g.K-1 {
+					trace.announce(&g.Nodes, route, -1, nil)
+				}
+			}
+		}
+		return false, separated, nil, trace
 	}
 
-	return true, impactedArea, impactMeasure
+	return true, impactedArea, impactMeasure, trace
 }
 
 // Remove from the bunch of 'target' the set of routes to 'unavailable' passing through 'nextHop'
+// The removed routes are stored in 'purged'
 // returns the set of invalidated destinations
-func (g *Graph) purgeFromBunch(targetAsn int, unavailable map[int]*Node, nextHopAsn int) map[int]*Node {
+func (g *Graph) purgeFromBunch(targetAsn int, unavailable map[int]*Node, nextHopAsn int, purged Clusters, trace *RepairTrace) map[int]*Node {
 	toInvalidate := make(map[int]*Node)
 
 	// Collect destinations to invalidate
@@ -387,8 +454,15 @@ func (g *Graph) purgeFromBunch(targetAsn int, unavailable map[int]*Node, nextHop
 		}
 	}
 
+	if _, exists := purged[targetAsn]; !exists && len(toInvalidate) > 0 {
+		purged[targetAsn] = make(map[int]*dijkstraNode)
+	}
+
 	// Update the bunch
 	for dest := range toInvalidate {
+		purged[targetAsn][dest] = g.Bunches[targetAsn][dest]
+		trace.invalidate(g.Nodes[targetAsn], -1, dest)
+		g.saveBunch(targetAsn, dest)
 		delete(g.Bunches[targetAsn], dest)
 	}
 
@@ -399,57 +473,103 @@ func (g *Graph) purgeFromBunch(targetAsn int, unavailable map[int]*Node, nextHop
 // returns
 //  - the set of asn touched by the update of top-level landmarks only
 //  - The measure of the distance of nodes that invalidate some destinations
-func (g *Graph) fixBunches(endpoint *Node, brokenLink *Node) (map[int]bool, TapeMeasure) {
+func (g *Graph) fixBunches(endpoint *Node, brokenLink *Node, trace *RepairTrace) (map[int]bool, TapeMeasure) {
+	measureImpact := InitMeasure(endpoint.Asn)
 
-	unavailable := make(map[int]*Node)
+	impactedAsn := g.repairBunches([]deletedLink{{endpoint: endpoint, neighbor: brokenLink}}, []*TapeMeasure{&measureImpact}, trace)
 
-	// Fill unavailable
-	for dest, dij := range g.Bunches[endpoint.Asn] {
-		if dij.nextHop.Asn == brokenLink.Asn {
-			unavailable[dest] = g.Nodes[dest]
-		}
-	}
+	return impactedAsn[0], measureImpact
+}
 
-	brokenTopLevel := g.Landmarks.filterByLevel(unavailable, g.K-1)
+// deletedLink is a deleted link, seen from one of its endpoints
+// cause is the index of the measure extended by the invalidations it triggers
+type deletedLink struct {
+	endpoint *Node
+	neighbor *Node
+	cause    int
+}
 
-	// This impact considers only nodes that must invalidate some destinations
-	measureImpact := InitMeasure(endpoint.Asn)
+// repairBunches restores the correctness of bunches after the deletion of some links
+// The invalidations triggered by a link extend the measure of its cause
+// returns, for each cause, the set of asn touched by the update of top-level landmarks only
+func (g *Graph) repairBunches(broken []deletedLink, measures []*TapeMeasure, trace *RepairTrace) []map[int]bool {
+
+	// cause[asn] is the cause of the first invalidation at asn
+	cause := make(map[int]int)
+
+	// For each asn, addedInRound stores the invalidated destinations
+	addedInRound := make(map[int]map[int]*Node)
+
+	brokenTopLevel := make(map[int]*Node)
 
 	dijkstraByLandmark := make(map[int]*DijkstraGraph)
 	frontierByLandmark := make(map[int]*Frontier)
 	populationByLandmark := make(map[int]int)
 	toUpdateByLandmark := make(map[int]*map[int]*Node)
-	for tl := range brokenTopLevel {
-		frontierByLandmark[tl] = &Frontier{
-			Zones:       make(map[int64]map[int]*dijkstraNode),
-			MinDistance: int64Max,
+
+	// Routes removed from bunches (to detect changes)
+	purged := make(Clusters)
+
+	for _, bl := range broken {
+		unavailable := make(map[int]*Node)
+
+		// Fill unavailable
+		for dest, dij := range g.Bunches[bl.endpoint.Asn] {
+			if dij.nextHop.Asn == bl.neighbor.Asn {
+				unavailable[dest] = g.Nodes[dest]
+			}
 		}
-		populationByLandmark[tl] = 0
 
-		tempGraph := make(DijkstraGraph)
-		dijkstraByLandmark[tl] = &tempGraph
+		if _, hasCause := cause[bl.endpoint.Asn]; !hasCause {
+			cause[bl.endpoint.Asn] = bl.cause
+		}
 
-		tempUpdate := make(map[int]*Node)
-		tempUpdate[endpoint.Asn] = endpoint
-		toUpdateByLandmark[tl] = &tempUpdate
-	}
+		for tl, tlNode := range g.Landmarks.filterByLevel(unavailable, g.K-1) {
+			if _, exists := brokenTopLevel[tl]; !exists {
+				brokenTopLevel[tl] = tlNode
+				frontierByLandmark[tl] = &Frontier{
+					Zones:       make(map[int64]map[int]*dijkstraNode),
+					MinDistance: int64Max,
+				}
+				populationByLandmark[tl] = 0
 
-	// For each asn, addedInRound stores the invalidated destinations
-	addedInRound := make(map[int]map[int]*Node)
-	addedInRound[endpoint.Asn] = unavailable
+				tempGraph := make(DijkstraGraph)
+				dijkstraByLandmark[tl] = &tempGraph
 
-	g.purgeFromBunch(endpoint.Asn, unavailable, brokenLink.Asn)
+				tempUpdate := make(map[int]*Node)
+				toUpdateByLandmark[tl] = &tempUpdate
+			}
+			(*toUpdateByLandmark[tl])[bl.endpoint.Asn] = bl.endpoint
+		}
+
+		if _, exists := addedInRound[bl.endpoint.Asn]; !exists {
+			addedInRound[bl.endpoint.Asn] = make(map[int]*Node)
+		}
+		for dest, nd := range unavailable {
+			addedInRound[bl.endpoint.Asn][dest] = nd
+		}
+
+		g.purgeFromBunch(bl.endpoint.Asn, unavailable, bl.neighbor.Asn, purged, trace)
+	}
 
 	for len(addedInRound) > 0 {
 		nextAdded := make(map[int]map[int]*Node)
 		for a, deletedFromA := range addedInRound {
 			for _, n := range g.Nodes[a].Links {
-				revokedDests := g.purgeFromBunch(n, deletedFromA, a)
+				revokedDests := g.purgeFromBunch(n, deletedFromA, a, purged, trace)
 
 				// Check if some destinations were revoked
 				if len(revokedDests) > 0 {
-					nextAdded[n] = revokedDests
-					measureImpact.Extend(a, n)
+					if _, exists := nextAdded[n]; !exists {
+						nextAdded[n] = make(map[int]*Node)
+					}
+					for dest, nd := range revokedDests {
+						nextAdded[n][dest] = nd
+					}
+					if _, hasCause := cause[n]; !hasCause {
+						cause[n] = cause[a]
+					}
+					measures[cause[a]].Extend(a, n)
 				}
 
 				neededAtN := g.Landmarks.filterByLevel(revokedDests, g.K-1)
@@ -473,6 +593,9 @@ func (g *Graph) fixBunches(endpoint *Node, brokenLink *Node) (map[int]bool, Tape
 					_, alreadyDiscovered := havingTopLevel[n]
 					if !alreadyInserted && !alreadyDiscovered {
 						havingTopLevel[n] = true
+						if _, hasCause := cause[n]; !hasCause {
+							cause[n] = cause[missingIt.Asn]
+						}
 						tempDij := dij.Copy(&g.Nodes)
 						(*dijkstraByLandmark[topLevel])[n] = tempDij
 						if frontierByLandmark[topLevel].addToFrontier(tempDij) {
@@ -489,28 +612,41 @@ func (g *Graph) fixBunches(endpoint *Node, brokenLink *Node) (map[int]bool, Tape
 	}
 
 	// Audit
-	impactedAsn := make(map[int]bool)
+	impactedAsn := make([]map[int]bool, len(measures))
+	for c := range impactedAsn {
+		impactedAsn[c] = make(map[int]bool)
+	}
 	for tl := range brokenTopLevel {
 		for e := range *toUpdateByLandmark[tl] {
-			impactedAsn[e] = true
+			impactedAsn[cause[e]][e] = true
 		}
 	}
 
 	// Execute Dijkstra for each top-level landmark
 	for tl := range brokenTopLevel {
-		dijkstraByLandmark[tl].runDijkstra(toUpdateByLandmark[tl], frontierByLandmark[tl], populationByLandmark[tl])
+		dijkstraByLandmark[tl].runDijkstra(toUpdateByLandmark[tl], frontierByLandmark[tl], populationByLandmark[tl], nil)
 
 		for nd, toLandmark := range *dijkstraByLandmark[tl] {
+			previous, exists := g.Bunches[nd][tl]
+			if !exists {
+				previous = purged[nd][tl]
+			}
+
+			if routeChanged(previous, toLandmark) {
+				trace.announce(&g.Nodes, toLandmark, -1, previous)
+			}
+
+			g.saveBunch(nd, toLandmark.parent.Asn)
 			g.Bunches[nd][toLandmark.parent.Asn] = toLandmark
 		}
 	}
 
-	return impactedAsn, measureImpact
+	return impactedAsn
 }
 
 // Restore the correctness of witnesses for a given round
 // return the set of asn needed to complete the operation
-func (g *Graph) fixWitnessByRound(endpoint *Node, brokenLink *Node, round int) (map[int]bool, TapeMeasure) {
+func (g *Graph) fixWitnessByRound(endpoint *Node, brokenLink *Node, round int, trace *RepairTrace) (map[int]bool, TapeMeasure) {
 
 	// Check if the witness was reached through the broken link
 	if (*g.Witnesses[round])[endpoint.Asn].nextHop.Asn != brokenLink.Asn {
@@ -520,8 +656,14 @@ func (g *Graph) fixWitnessByRound(endpoint *Node, brokenLink *Node, round int) (
 	toUpdateZone := make(map[int]*Node)
 	toUpdateZone[endpoint.Asn] = endpoint
 
+	// Routes before the repair (to detect changes)
+	previous := make(map[int]*dijkstraNode)
+	previous[endpoint.Asn] = (*g.Witnesses[round])[endpoint.Asn]
+	trace.invalidate(endpoint, round, previous[endpoint.Asn].parent.Asn)
+
 	// Remove the dijkstraNode (instead than setting dist=+inf) so that
 	// runDijkstra esasily detects if it's not reached
+	g.saveWitness(round, endpoint.Asn)
 	delete(*g.Witnesses[round], endpoint.Asn)
 
 	var addedInRound map[int]bool
@@ -548,7 +690,10 @@ func (g *Graph) fixWitnessByRound(endpoint *Node, brokenLink *Node, round int) (
 					if witness.nextHop.Asn == a {
 						toUpdateZone[n] = g.Nodes[n]
 						nextAdded[n] = true
+						previous[n] = witness
+						trace.invalidate(g.Nodes[n], round, witness.parent.Asn)
 						// Delete corresponding dijkstraNode (see above comment)
+						g.saveWitness(round, n)
 						delete((*g.Witnesses[round]), n)
 						impactMeasure.Extend(a, n)
 					}
@@ -567,6 +712,8 @@ func (g *Graph) fixWitnessByRound(endpoint *Node, brokenLink *Node, round int) (
 			_, alreadyMarked := stillHavingWitness[l]
 			if !alreadyMarked && hasWitness {
 				stillHavingWitness[l] = g.Nodes[l]
+				// Entries on the frontier can be relaxed in place
+				previous[l] = dijNode.Copy(&g.Nodes)
 				if frontier.addToFrontier(dijNode) {
 					frontierPopulation++
 				}
@@ -584,11 +731,32 @@ func (g *Graph) fixWitnessByRound(endpoint *Node, brokenLink *Node, round int) (
 		impactedAsn[asn] = true
 	}
 
-	g.Witnesses[round].runDijkstra(&toUpdateZone, &frontier, frontierPopulation)
+	g.saveWitnesses(round, toUpdateZone)
+	g.Witnesses[round].runDijkstra(&toUpdateZone, &frontier, frontierPopulation, nil)
+
+	for asn := range toUpdateZone {
+		route, exists := (*g.Witnesses[round])[asn]
+		if exists && routeChanged(previous[asn], route) {
+			trace.announce(&g.Nodes, route, round, previous[asn])
+		}
+	}
 
 	return impactedAsn, impactMeasure
 }
 
+// StateEntries returns the number of bunch and witness entries held by an AS
+// The level-0 witness (the AS itself) is not counted
+func (g *Graph) StateEntries(asn int) (int, int) {
+	witnesses := 0
+	for round := 1; round < g.K; round++ {
+		if _, exists := (*g.Witnesses[round])[asn]; exists {
+			witnesses++
+		}
+	}
+
+	return len(g.Bunches[asn]), witnesses
+}
+
 // Evolve brings the graph to a stable state
 func (g *Graph) Evolve() int {
 	return 0
@@ -614,7 +782,11 @@ func (g *Graph) GetRoute(originAsn int, destinationAsn int) ([]*Node, []int) {
 		return nil, nil
 	}
 
-	_, hops := g.ApproximatePath(originAsn, destinationAsn)
+	level, hops := g.ApproximatePath(originAsn, destinationAsn)
+	if level < 0 || len(hops) == 0 {
+		// Different connected components, or no valley-free path
+		return nil, nil
+	}
 
 	nodeHops := make([]*Node, 0, len(hops))
 	nodeTypes := make([]int, 0, len(hops)-1)
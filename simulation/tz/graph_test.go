@@ -0,0 +1,47 @@
+package tz
+
+import (
+	"testing"
+
+	. "dedis.epfl.ch/core"
+)
+
+// testLandmarks are landmark hierarchies (levels 1 to k-1) for data/test.csv
+var testLandmarks = [][][]int{
+	{{3}},
+	{{1, 6}},
+	{{2, 4, 6}, {4}},
+	{{1, 5, 7}, {1, 7}},
+}
+
+// loadTestGraph preprocesses data/test.csv with the given landmarks of levels 1 to k-1
+// (every AS is a level-0 landmark)
+func loadTestGraph(t *testing.T, landmarks [][]int) *Graph {
+	return loadGraphFile(t, "../data/test.csv", landmarks)
+}
+
+// loadGraphFile preprocesses a CSV file like loadTestGraph
+func loadGraphFile(t *testing.T, filename string, landmarks [][]int) *Graph {
+	graph := InitGraph()
+	graph.K = len(landmarks) + 1
+
+	if err := LoadFromCsv(&graph, filename); err != nil {
+		t.Fatal(err)
+	}
+
+	graph.Landmarks[0] = make(map[*Node]bool)
+	for _, nd := range graph.Nodes {
+		graph.Landmarks[0][nd] = true
+	}
+	for level, asns := range landmarks {
+		graph.Landmarks[level+1] = make(map[*Node]bool)
+		for _, asn := range asns {
+			graph.Landmarks[level+1][graph.Nodes[asn]] = true
+		}
+	}
+	graph.Landmarks[graph.K] = nil
+
+	graph.Preprocess()
+
+	return &graph
+}
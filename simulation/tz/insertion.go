@@ -0,0 +1,131 @@
+package tz
+
+import (
+	. "dedis.epfl.ch/core"
+	"dedis.epfl.ch/u"
+)
+
+// AddEdge inserts a link between a and b (linkType is the type of b as seen by a)
+// and updates the relevant data structures
+// If a and b were in different connected components, the components are merged
+// returns true if the insertion was successful
+// returns the set of asn impacted by the update
+// returns the TapeMeasure of the distance from a of the nodes with an improved witness
+func (g *Graph) AddEdge(aAsn int, bAsn int, linkType int) (bool, map[int]bool, *TapeMeasure) {
+
+	a, aOk := g.Nodes[aAsn]
+	b, bOk := g.Nodes[bAsn]
+
+	if !(aOk && bOk) || aAsn == bAsn || a.GetNeighborIndex(b) >= 0 {
+		return false, nil, nil
+	}
+
+	g.kIsValid()
+
+	// Improvements are measured on the graph without the new link
+	improvedByRound := make(map[int]map[int]int64)
+	for round := g.K - 1; round >= 1; round-- {
+		improved := g.improvedBy(a, b, round)
+		for asn, distance := range g.improvedBy(b, a, round) {
+			if previous, exists := improved[asn]; !exists || distance+EdgeWeight < previous {
+				improved[asn] = distance + EdgeWeight
+			}
+		}
+		improvedByRound[round] = improved
+	}
+
+	g.saveLinks(a)
+	g.saveLinks(b)
+
+	if !(a.AddLink(b, linkType) && b.AddLink(a, -linkType)) {
+		panic("Link insertion unsuccessful! Corrupted graph")
+	}
+
+	g.mergeComponents(a, b)
+
+	impactedArea := map[int]bool{aAsn: true, bAsn: true}
+
+	tempMeasure := InitMeasure(aAsn)
+	impactMeasure := &tempMeasure
+
+	// changedByRound[r] contains the previous witness distance of the nodes whose
+	// distance to A_r changed
+	changedByRound := make(map[int]map[int]int64)
+
+	// Fix Witnesses
+	for round := g.K - 1; round >= 1; round-- {
+		invalid := make(map[int]int)
+		for asn, distance := range improvedByRound[round] {
+			invalid[asn] = 0
+			(*impactMeasure)[asn] = distance
+		}
+
+		repaired, changed, _ := g.rebuildWitnesses(round, invalid, nil, []*TapeMeasure{impactMeasure})
+
+		impactedArea = u.Union(impactedArea, repaired)
+		changedByRound[round] = changed
+
+		g.enforceAsteriskRule(round)
+	}
+	g.enforceAsteriskRule(0)
+
+	// Fix Bunches: the clusters of a and b can grow through the new link,
+	// the others are bounded by the repaired witnesses
+	toRecompute := make(map[int]*Node)
+	for _, endpoint := range []*Node{a, b} {
+		for w := range g.Bunches[endpoint.Asn] {
+			toRecompute[w] = g.Nodes[w]
+		}
+	}
+
+	for round, changed := range changedByRound {
+		for w, nd := range g.nearbyLandmarks(changed, round-1) {
+			toRecompute[w] = nd
+		}
+	}
+
+	for _, w := range toRecompute {
+		impactedArea = u.Union(impactedArea, g.replaceCluster(w))
+	}
+
+	return true, impactedArea, impactMeasure
+}
+
+// improvedBy returns the nodes (reached from 'from') whose witness of a round would be
+// closer through a new link from 'from' to 'through', along with their distance from 'from'
+// The exploration stops at the nodes that are not improved, since the nodes behind
+// them cannot be improved either
+func (g *Graph) improvedBy(from *Node, through *Node, round int) map[int]int64 {
+	improved := make(map[int]int64)
+
+	throughWitness, hasWitness := (*g.Witnesses[round])[through.Asn]
+	if !hasWitness {
+		return improved
+	}
+
+	isImproved := func(asn int, distance int64) bool {
+		witness, exists := (*g.Witnesses[round])[asn]
+		return !exists || throughWitness.distance+EdgeWeight+distance < witness.distance
+	}
+
+	if !isImproved(from.Asn, 0) {
+		return improved
+	}
+
+	improved[from.Asn] = 0
+	visiting := []int{from.Asn}
+	for len(visiting) > 0 {
+		next := make([]int, 0)
+		for _, asn := range visiting {
+			for _, l := range g.Nodes[asn].Links {
+				if _, visited := improved[l]; !visited && isImproved(l, improved[asn]+EdgeWeight) {
+					improved[l] = improved[asn] + EdgeWeight
+					next = append(next, l)
+				}
+			}
+		}
+		visiting = next
+	}
+
+	return improved
+}
@@ -0,0 +1,253 @@
+package tz
+
+import (
+	. "dedis.epfl.ch/core"
+)
+
+// undoLog stores the state touched by a transaction before its first change
+type undoLog struct {
+	links *LinkJournal
+	// witnesses[round][asn] and bunches[asn][landmark]
+	witnesses map[int]map[int]savedRoute
+	bunches   map[int]map[int]savedRoute
+	// bunchSets[asn] tells if Bunches[asn] existed
+	bunchSets  map[int]bool
+	landmarks  map[int]map[*Node]bool
+	components map[int]savedComponent
+}
+
+// savedRoute keeps the content of a dijkstraNode (entry is nil if the route was absent)
+// Routes are restored in place, since they can be relaxed in place by runDijkstra
+type savedRoute struct {
+	entry *dijkstraNode
+	value dijkstraNode
+}
+
+type savedComponent struct {
+	component int
+	exists    bool
+}
+
+func initUndoLog() *undoLog {
+	return &undoLog{
+		links:      InitLinkJournal(),
+		witnesses:  make(map[int]map[int]savedRoute),
+		bunches:    make(map[int]map[int]savedRoute),
+		bunchSets:  make(map[int]bool),
+		landmarks:  make(map[int]map[*Node]bool),
+		components: make(map[int]savedComponent),
+	}
+}
+
+func saveRoute(saved map[int]savedRoute, key int, entry *dijkstraNode) {
+	if _, alreadySaved := saved[key]; alreadySaved {
+		return
+	}
+	if entry == nil {
+		saved[key] = savedRoute{}
+	} else {
+		saved[key] = savedRoute{entry: entry, value: *entry}
+	}
+}
+
+func restoreRoutes(routes map[int]*dijkstraNode, saved map[int]savedRoute) {
+	for key, s := range saved {
+		if s.entry == nil {
+			delete(routes, key)
+		} else {
+			*s.entry = s.value
+			routes[key] = s.entry
+		}
+	}
+}
+
+// Begin starts a transaction: witnesses, bunches, landmarks, components and links
+// are journaled until Commit or Rollback
+func (g *Graph) Begin() {
+	if g.journal != nil {
+		panic("A transaction is already in progress")
+	}
+	g.journal = initUndoLog()
+}
+
+// Commit keeps the changes performed since Begin
+func (g *Graph) Commit() {
+	if g.journal == nil {
+		panic("No transaction in progress")
+	}
+	g.journal = nil
+}
+
+// Rollback undoes the changes performed since Begin
+func (g *Graph) Rollback() {
+	if g.journal == nil {
+		panic("No transaction in progress")
+	}
+
+	j := g.journal
+	g.journal = nil
+
+	j.links.Restore(g.Nodes)
+
+	for round, saved := range j.witnesses {
+		restoreRoutes(*g.Witnesses[round], saved)
+	}
+
+	for asn, saved := range j.bunches {
+		if _, exists := g.Bunches[asn]; !exists {
+			g.Bunches[asn] = make(map[int]*dijkstraNode)
+		}
+		restoreRoutes(g.Bunches[asn], saved)
+	}
+	for asn, existed := range j.bunchSets {
+		if !existed {
+			delete(g.Bunches, asn)
+		}
+	}
+
+	for level, saved := range j.landmarks {
+		for nd, wasLandmark := range saved {
+			if wasLandmark {
+				g.Landmarks[level][nd] = true
+			} else {
+				delete(g.Landmarks[level], nd)
+			}
+		}
+	}
+
+	for asn, saved := range j.components {
+		if saved.exists {
+			g.Components[asn] = saved.component
+		} else {
+			delete(g.Components, asn)
+		}
+	}
+}
+
+// beginNested starts a transaction inside the current one (if any), so that an
+// operation can undo its own changes
+// returns the outer journal, to be passed to endNested
+func (g *Graph) beginNested() *undoLog {
+	outer := g.journal
+	g.journal = initUndoLog()
+	return outer
+}
+
+// endNested keeps or undoes the changes of the nested transaction, then resumes
+// the outer one (the kept changes are journaled by the outer transaction)
+func (g *Graph) endNested(outer *undoLog, keep bool) {
+	if !keep {
+		g.Rollback()
+	} else if outer != nil {
+		outer.merge(g.journal)
+	}
+	g.journal = outer
+}
+
+// merge adds to the log the state saved by a nested log, unless it was already saved
+// (the nested log saved it before its own changes, so no earlier change happened)
+func (j *undoLog) merge(nested *undoLog) {
+	j.links.Merge(nested.links)
+
+	mergeRoutes := func(to map[int]map[int]savedRoute, from map[int]map[int]savedRoute) {
+		for key, saved := range from {
+			if _, exists := to[key]; !exists {
+				to[key] = make(map[int]savedRoute)
+			}
+			for asn, s := range saved {
+				if _, alreadySaved := to[key][asn]; !alreadySaved {
+					to[key][asn] = s
+				}
+			}
+		}
+	}
+	mergeRoutes(j.witnesses, nested.witnesses)
+	mergeRoutes(j.bunches, nested.bunches)
+
+	for asn, existed := range nested.bunchSets {
+		if _, alreadySaved := j.bunchSets[asn]; !alreadySaved {
+			j.bunchSets[asn] = existed
+		}
+	}
+
+	for level, saved := range nested.landmarks {
+		if _, exists := j.landmarks[level]; !exists {
+			j.landmarks[level] = make(map[*Node]bool)
+		}
+		for nd, wasLandmark := range saved {
+			if _, alreadySaved := j.landmarks[level][nd]; !alreadySaved {
+				j.landmarks[level][nd] = wasLandmark
+			}
+		}
+	}
+
+	for asn, saved := range nested.components {
+		if _, alreadySaved := j.components[asn]; !alreadySaved {
+			j.components[asn] = saved
+		}
+	}
+}
+
+// The following methods save the state before a change (they do nothing outside transactions)
+
+func (g *Graph) saveLinks(nd *Node) {
+	if g.journal != nil {
+		g.journal.links.Save(nd)
+	}
+}
+
+func (g *Graph) saveWitness(round int, asn int) {
+	if g.journal == nil {
+		return
+	}
+	if _, exists := g.journal.witnesses[round]; !exists {
+		g.journal.witnesses[round] = make(map[int]savedRoute)
+	}
+	saveRoute(g.journal.witnesses[round], asn, (*g.Witnesses[round])[asn])
+}
+
+func (g *Graph) saveWitnesses(round int, zone map[int]*Node) {
+	if g.journal == nil {
+		return
+	}
+	for asn := range zone {
+		g.saveWitness(round, asn)
+	}
+}
+
+func (g *Graph) saveBunch(asn int, w int) {
+	if g.journal == nil {
+		return
+	}
+	if _, alreadySaved := g.journal.bunchSets[asn]; !alreadySaved {
+		_, exists := g.Bunches[asn]
+		g.journal.bunchSets[asn] = exists
+	}
+	if _, exists := g.journal.bunches[asn]; !exists {
+		g.journal.bunches[asn] = make(map[int]savedRoute)
+	}
+	saveRoute(g.journal.bunches[asn], w, g.Bunches[asn][w])
+}
+
+func (g *Graph) saveLandmark(level int, nd *Node) {
+	if g.journal == nil {
+		return
+	}
+	if _, exists := g.journal.landmarks[level]; !exists {
+		g.journal.landmarks[level] = make(map[*Node]bool)
+	}
+	if _, alreadySaved := g.journal.landmarks[level][nd]; !alreadySaved {
+		_, isLandmark := g.Landmarks[level][nd]
+		g.journal.landmarks[level][nd] = isLandmark
+	}
+}
+
+func (g *Graph) saveComponent(asn int) {
+	if g.journal == nil {
+		return
+	}
+	if _, alreadySaved := g.journal.components[asn]; !alreadySaved {
+		component, exists := g.Components[asn]
+		g.journal.components[asn] = savedComponent{component: component, exists: exists}
+	}
+}
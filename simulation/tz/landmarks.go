@@ -7,7 +7,6 @@ import (
 	"math"
 	"math/rand"
 	"os"
-	"time"
 
 	. "dedis.epfl.ch/core"
 	"dedis.epfl.ch/u"
@@ -96,7 +95,7 @@ func (g *Graph) splineStrategy() {
 		panic(err)
 	}
 
-	rand.Seed(time.Now().UnixNano())
+	u.SeedRandom()
 
 	var selProbability float64 = math.Pow(float64(len(g.Nodes)), -1./float64(g.K))
 
@@ -149,7 +148,7 @@ func (g *Graph) harmonicStrategy() {
 		panic(err)
 	}
 
-	rand.Seed(time.Now().UnixNano())
+	u.SeedRandom()
 
 	var selProbability float64 = math.Pow(float64(len(g.Nodes)), -1./float64(g.K))
 
@@ -213,7 +212,7 @@ func (g *Graph) immunityStrategy() {
 		panic(err)
 	}
 
-	rand.Seed(time.Now().UnixNano())
+	u.SeedRandom()
 
 	var selProbability float64 = math.Pow(float64(len(g.Nodes)), -1./float64(g.K))
 
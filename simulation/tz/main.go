@@ -198,7 +198,7 @@ func (g *Graph) LoadBunchesFromCsv(filename string) {
 	}
 }
 
-var commandParams = map[string]int{"route": 2, "test-link": 2, "bunch": 1, "witness": 2, "delete": 2, "help": 0, "exit": 0} //map[string]int{"show": 1, "add-route": 1, "evolve": 0, "route": 2, "help": 0, "exit": 0}
+var commandParams = map[string]int{"route": 2, "distance": 2, "test-link": 2, "bunch": 1, "witness": 2, "delete": 2, "fail": 1, "help": 0, "exit": 0} //map[string]int{"show": 1, "add-route": 1, "evolve": 0, "route": 2, "help": 0, "exit": 0}
 
 var sh *Shell
 
@@ -229,6 +229,17 @@ func (g *Graph) ExecCommand() bool {
 	case "route":
 		g.PrintRoute(u.Int(cmd[1]), u.Int(cmd[2]))
 
+	case "distance":
+		if estimate, ok := g.EstimateDistance(u.Int(cmd[1]), u.Int(cmd[2])); ok {
+			fmt.Printf("\tEstimated distance %d (>= %d) through level %d landmark %d\n",
+				estimate.Distance,
+				estimate.LowerBound,
+				estimate.Level,
+				estimate.Landmark)
+		} else {
+			fmt.Println("INVALID AS SPECIFIED")
+		}
+
 	case "test-link":
 		g.TestLink(u.Int(cmd[1]), u.Int(cmd[2]))
 
@@ -252,6 +263,13 @@ func (g *Graph) ExecCommand() bool {
 		_, asnUpdated, asnDistance := g.RemoveEdge(u.Int(cmd[1]), u.Int(cmd[2]))
 		fmt.Printf("Graph updated, %d nodes exchanged updates, the average distance from link is %f\n", len(asnUpdated), asnDistance.Mean())
 
+	case "fail":
+		if success, asnUpdated, asnDistance := g.FailLandmark(u.Int(cmd[1]), true); success {
+			fmt.Printf("Landmark demoted, %d nodes exchanged updates, the average distance from landmark is %f\n", len(asnUpdated), asnDistance.Mean())
+		} else {
+			fmt.Println("THE AS CANNOT BE DEMOTED")
+		}
+
 	case "help":
 		fmt.Println("The available commands are:")
 		for keyword := range commandParams {
@@ -0,0 +1,104 @@
+package tz
+
+import (
+	"runtime"
+	"sync"
+)
+
+// DistanceEstimate is the answer of the distance oracle to a query
+type DistanceEstimate struct {
+	From     int
+	To       int
+	Distance int64
+	Landmark int
+	Level    int
+	// StretchBound is the theoretical stretch (2k-1) guaranteed by TZ when route distances
+	// are a metric: Gao-Rexford filtering breaks the triangle inequality, so it can be exceeded
+	StretchBound int
+	// LowerBound is the smallest real distance compatible with the estimate, under StretchBound
+	// (the estimate measures a walk, so it is never below the hop distance)
+	LowerBound int64
+}
+
+// EstimateDistance returns the TZ estimate of the distance from 'from' to 'to'
+// without expanding the path (only witness and bunch distances are used)
+// returns false if one of the endpoints is not in the graph or if they are not connected
+// The estimate ignores policies: it returns false on ValleyFree graphs (use ApproximatePath)
+func (g *Graph) EstimateDistance(from int, to int) (DistanceEstimate, bool) {
+	if g.ValleyFree {
+		return DistanceEstimate{From: from, To: to, Level: -1, StretchBound: 2*g.K - 1}, false
+	}
+	return g.estimateDistance(from, to)
+}
+
+// estimateDistance is EstimateDistance regardless of ValleyFree
+func (g *Graph) estimateDistance(from int, to int) (DistanceEstimate, bool) {
+	estimate := DistanceEstimate{
+		From:         from,
+		To:           to,
+		Level:        -1,
+		StretchBound: 2*g.K - 1,
+	}
+
+	_, okFrom := g.Nodes[from]
+	_, okTo := g.Nodes[to]
+	if !(okFrom && okTo) || !g.Reachable(from, to) {
+		return estimate, false
+	}
+
+	var w int = from
+	var i int = 0
+
+	for {
+		if toW, ok := g.Bunches[to][w]; ok {
+			estimate.Distance = (*g.Witnesses[i])[from].distance + toW.distance
+			estimate.Landmark = w
+			estimate.Level = i
+			// Round up, since distances are integers
+			estimate.LowerBound = (estimate.Distance + int64(estimate.StretchBound) - 1) / int64(estimate.StretchBound)
+
+			return estimate, true
+		}
+
+		// Debug check
+		if i == g.K {
+			panic("Calculated a wrong distance approximation")
+		}
+
+		i++
+		from, to = to, from
+		w = (*g.Witnesses[i])[from].parent.Asn
+	}
+}
+
+// EstimateDistances answers a batch of queries in parallel, using 'workers' goroutines
+// (all the available CPUs if workers <= 0)
+// The estimate of a query whose endpoints are not in the graph (or not connected) has Level -1,
+// like every estimate on ValleyFree graphs
+func (g *Graph) EstimateDistances(queries [][2]int, workers int) []DistanceEstimate {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	estimates := make([]DistanceEstimate, len(queries))
+
+	var wg sync.WaitGroup
+
+	// Each worker answers a contiguous slice of queries
+	for wk := 0; wk < workers; wk++ {
+		startIdx := wk * len(queries) / workers
+		endIdx := (wk + 1) * len(queries) / workers
+
+		wg.Add(1)
+		go func(startIdx int, endIdx int) {
+			defer wg.Done()
+			for q := startIdx; q < endIdx; q++ {
+				estimates[q], _ = g.EstimateDistance(queries[q][0], queries[q][1])
+			}
+		}(startIdx, endIdx)
+	}
+
+	wg.Wait()
+
+	return estimates
+}
@@ -0,0 +1,102 @@
+package tz
+
+import (
+	"testing"
+)
+
+// hopsFrom returns the hop distance of every AS from 'from', ignoring policies
+func hopsFrom(g *Graph, from int) map[int]int64 {
+	distances := map[int]int64{from: 0}
+	queue := []int{from}
+	for len(queue) > 0 {
+		asn := queue[0]
+		queue = queue[1:]
+		for _, neighbor := range g.Nodes[asn].Links {
+			if _, seen := distances[neighbor]; !seen {
+				distances[neighbor] = distances[asn] + 1
+				queue = append(queue, neighbor)
+			}
+		}
+	}
+	return distances
+}
+
+// routeDistances returns the distance of the route of every AS towards 'to',
+// following the announcements of 'to' like the preprocessing does
+func routeDistances(g *Graph, to int) map[int]int64 {
+	source := dijkstraNode{reference: to, distance: 0, parent: g.Nodes[to], nextHop: g.Nodes[to]}
+	routes := DijkstraGraph{to: &source}
+	frontier := Frontier{
+		Zones:       map[int64]map[int]*dijkstraNode{0: {to: &source}},
+		MinDistance: 0,
+	}
+	routes.runDijkstra(&g.Nodes, &frontier, 1, nil)
+
+	distances := make(map[int]int64)
+	for asn, route := range routes {
+		distances[asn] = route.distance
+	}
+	return distances
+}
+
+func TestEstimateDistanceWithinBounds(t *testing.T) {
+	// Every route of data/tree.csv is valley-free along the tree, so route
+	// distances are a metric and the TZ bound holds
+	treeLandmarks := [][][]int{
+		{{2, 9}},
+		{{1, 4, 6}, {1}},
+		{{8, 10}},
+		{{5, 7, 9}, {7}},
+	}
+
+	for _, landmarks := range treeLandmarks {
+		graph := loadGraphFile(t, "../data/tree.csv", landmarks)
+
+		for to := range graph.Nodes {
+			for from, d := range routeDistances(graph, to) {
+				estimate, ok := graph.EstimateDistance(from, to)
+				if !ok {
+					t.Errorf("%v: no estimate from %d to %d", landmarks, from, to)
+					continue
+				}
+				if estimate.LowerBound > d || estimate.Distance < d || estimate.Distance > int64(estimate.StretchBound)*d {
+					t.Errorf("%v: %d to %d estimated at %d (>= %d), distance %d", landmarks, from, to, estimate.Distance, estimate.LowerBound, d)
+				}
+			}
+		}
+	}
+}
+
+func TestEstimateDistanceExceedsBoundUnderPolicies(t *testing.T) {
+	// The route of 6 to its provider 4 is direct, but the route of 6 to 3
+	// (the witness of 4) goes around the peering link 3-4
+	graph := loadTestGraph(t, testLandmarks[0])
+
+	if d := routeDistances(graph, 4)[6]; d != 1 {
+		t.Fatalf("6 reaches 4 at %d", d)
+	}
+	if estimate, ok := graph.EstimateDistance(6, 4); !ok || estimate.Distance != 5 {
+		t.Errorf("6 to 4 estimated at %+v (%v)", estimate, ok)
+	}
+
+	// The estimate measures a walk, so it is never below the hop distance
+	for _, landmarks := range testLandmarks {
+		graph := loadTestGraph(t, landmarks)
+		for from := range graph.Nodes {
+			for to, d := range hopsFrom(graph, from) {
+				if estimate, ok := graph.EstimateDistance(from, to); !ok || estimate.Distance < d {
+					t.Errorf("%v: %d to %d estimated at %d, %d hops (%v)", landmarks, from, to, estimate.Distance, d, ok)
+				}
+			}
+		}
+	}
+}
+
+func TestEstimateDistanceRefusedWhenValleyFree(t *testing.T) {
+	graph := loadTestGraph(t, testLandmarks[2])
+	graph.ValleyFree = true
+
+	if estimate, ok := graph.EstimateDistance(1, 7); ok || estimate.Level != -1 {
+		t.Errorf("estimate %+v returned on a valley-free graph", estimate)
+	}
+}
@@ -0,0 +1,197 @@
+package tz
+
+import (
+	. "dedis.epfl.ch/core"
+)
+
+// Reachable checks if two ASes belong to the same connected component
+func (g *Graph) Reachable(aAsn int, bAsn int) bool {
+	return g.Components[aAsn] == g.Components[bAsn]
+}
+
+// separatedBy returns the connected component that would be cut off by the deletion
+// of the link (a, b), or nil if a and b would remain connected
+// Both sides are explored in parallel, so that the cost depends on the smaller one
+// (which is the one returned)
+func (g *Graph) separatedBy(a *Node, b *Node) map[int]bool {
+
+	type side struct {
+		visited map[int]bool
+		queue   []int
+	}
+
+	sides := [2]*side{
+		{visited: map[int]bool{a.Asn: true}, queue: []int{a.Asn}},
+		{visited: map[int]bool{b.Asn: true}, queue: []int{b.Asn}},
+	}
+
+	for turn := 0; ; turn = 1 - turn {
+		current, other := sides[turn], sides[1-turn]
+
+		if len(current.queue) == 0 {
+			return current.visited
+		}
+
+		asn := current.queue[0]
+		current.queue = current.queue[1:]
+
+		for _, l := range g.Nodes[asn].Links {
+			// Ignore the link to delete
+			if (asn == a.Asn && l == b.Asn) || (asn == b.Asn && l == a.Asn) {
+				continue
+			}
+			if other.visited[l] {
+				return nil
+			}
+			if !current.visited[l] {
+				current.visited[l] = true
+				current.queue = append(current.queue, l)
+			}
+		}
+	}
+}
+
+// separatedPieces returns the parts of the connected components that have been cut off
+// by the deletion of the 'removed' edges (the largest part of each component keeps
+// being the component), or nil if no component was split
+func (g *Graph) separatedPieces(edges [][2]int, removed []int) []map[int]bool {
+
+	split := false
+	for _, idx := range removed {
+		if g.separatedBy(g.Nodes[edges[idx][0]], g.Nodes[edges[idx][1]]) != nil {
+			split = true
+			break
+		}
+	}
+	if !split {
+		return nil
+	}
+
+	// Find the parts containing the endpoints, grouped by component
+	piecesByComponent := make(map[int][]map[int]bool)
+	visited := make(map[int]bool)
+
+	for _, idx := range removed {
+		for _, start := range edges[idx] {
+			if visited[start] {
+				continue
+			}
+
+			piece := map[int]bool{start: true}
+			visited[start] = true
+			toVisit := []int{start}
+			for len(toVisit) > 0 {
+				a := toVisit[len(toVisit)-1]
+				toVisit = toVisit[:len(toVisit)-1]
+				for _, l := range g.Nodes[a].Links {
+					if !visited[l] {
+						visited[l] = true
+						piece[l] = true
+						toVisit = append(toVisit, l)
+					}
+				}
+			}
+
+			c := g.Components[start]
+			piecesByComponent[c] = append(piecesByComponent[c], piece)
+		}
+	}
+
+	separated := make([]map[int]bool, 0)
+	for _, pieces := range piecesByComponent {
+		largest := 0
+		for p := range pieces {
+			if len(pieces[p]) > len(pieces[largest]) {
+				largest = p
+			}
+		}
+		for p := range pieces {
+			if p != largest {
+				separated = append(separated, pieces[p])
+			}
+		}
+	}
+
+	return separated
+}
+
+// splitComponent assigns a new component to the separated nodes and makes sure
+// that both components have top-level landmarks (promoting one node if needed)
+// returns the set of asn impacted by the promotions
+func (g *Graph) splitComponent(separated map[int]bool) map[int]bool {
+
+	if g.Components == nil {
+		g.Components = make(map[int]int)
+	}
+
+	newComponent := 0
+	for _, c := range g.Components {
+		if c >= newComponent {
+			newComponent = c + 1
+		}
+	}
+	if newComponent == 0 {
+		newComponent = 1
+	}
+
+	var remaining int
+	for asn := range separated {
+		remaining = g.Components[asn]
+		g.saveComponent(asn)
+		g.Components[asn] = newComponent
+	}
+
+	impactedArea := make(map[int]bool)
+
+	for _, c := range []int{newComponent, remaining} {
+		best := g.bestLandmarkOfComponent(c)
+		if level := g.landmarkLevel(best.Asn); level < g.K-1 {
+			promotedArea, _, _ := g.relevel(nil, 0, best, g.K-1)
+			for asn := range promotedArea {
+				impactedArea[asn] = true
+			}
+		}
+	}
+
+	return impactedArea
+}
+
+// mergeComponents assigns the component of a to the nodes in the component of b
+func (g *Graph) mergeComponents(a *Node, b *Node) {
+	merged, absorbed := g.Components[a.Asn], g.Components[b.Asn]
+	if merged == absorbed {
+		return
+	}
+
+	// ASes without an entry belong to component 0
+	for asn := range g.Nodes {
+		if g.Components[asn] == absorbed {
+			g.saveComponent(asn)
+			g.Components[asn] = merged
+		}
+	}
+}
+
+// bestLandmarkOfComponent returns the node of a component with the highest landmark
+// level (ties are broken by number of links, then by ASN)
+func (g *Graph) bestLandmarkOfComponent(component int) *Node {
+	var best *Node
+	bestLevel := -1
+
+	for asn, nd := range g.Nodes {
+		if g.Components[asn] != component {
+			continue
+		}
+
+		level := g.landmarkLevel(asn)
+		if best == nil ||
+			level > bestLevel ||
+			(level == bestLevel && len(nd.Links) > len(best.Links)) ||
+			(level == bestLevel && len(nd.Links) == len(best.Links) && asn < best.Asn) {
+			best = nd
+			bestLevel = level
+		}
+	}
+
+	return best
+}
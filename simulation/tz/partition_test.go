@@ -0,0 +1,89 @@
+package tz
+
+import (
+	"reflect"
+	"testing"
+)
+
+// checkRoutes checks that the ASes in the same group (and only them) are reachable
+// from each other, and that GetRoute follows existing links between them
+func checkRoutes(t *testing.T, graph *Graph, groups [][]int) {
+	groupOf := make(map[int]int)
+	for idx, group := range groups {
+		for _, asn := range group {
+			groupOf[asn] = idx
+		}
+	}
+
+	for from := range graph.Nodes {
+		for to := range graph.Nodes {
+			if from == to {
+				continue
+			}
+
+			reachable := groupOf[from] == groupOf[to]
+			if graph.Reachable(from, to) != reachable {
+				t.Errorf("k=%d: Reachable(%d, %d) is %v", graph.K, from, to, !reachable)
+			}
+
+			route, _ := graph.GetRoute(from, to)
+			if !reachable {
+				if route != nil {
+					t.Errorf("k=%d: route %v between components", graph.K, route)
+				}
+				continue
+			}
+
+			if len(route) < 2 || route[0].Asn != from || route[len(route)-1].Asn != to {
+				t.Errorf("k=%d: route %v from %d to %d", graph.K, route, from, to)
+				continue
+			}
+			for idx := 1; idx < len(route); idx++ {
+				if route[idx-1].GetNeighborIndex(route[idx]) < 0 {
+					t.Errorf("k=%d: route %v from %d to %d follows a missing link", graph.K, route, from, to)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestSeparatedBy(t *testing.T) {
+	graph := loadTestGraph(t, testLandmarks[0])
+
+	// 1-5 is in the triangle 1-3-5
+	if separated := graph.separatedBy(graph.Nodes[1], graph.Nodes[5]); separated != nil {
+		t.Errorf("deleting 1-5 separates %v", separated)
+	}
+
+	graph.RemoveEdge(1, 2)
+
+	// The smaller side is returned
+	expected := map[int]bool{2: true, 6: true, 7: true}
+	if separated := graph.separatedBy(graph.Nodes[4], graph.Nodes[6]); !reflect.DeepEqual(separated, expected) {
+		t.Errorf("deleting 4-6 separates %v, expected %v", separated, expected)
+	}
+}
+
+func TestSplitAndMergeComponents(t *testing.T) {
+	for _, landmarks := range testLandmarks {
+		graph := loadTestGraph(t, landmarks)
+
+		graph.RemoveEdge(1, 2)
+		checkRoutes(t, graph, [][]int{{1, 2, 3, 4, 5, 6, 7}})
+
+		// The links 1-2 and 4-6 are the only ones between {1, 3, 4, 5} and {2, 6, 7}
+		graph.RemoveEdge(4, 6)
+		checkRoutes(t, graph, [][]int{{1, 3, 4, 5}, {2, 6, 7}})
+
+		for _, component := range [][]int{{1, 3, 4, 5}, {2, 6, 7}} {
+			best := graph.bestLandmarkOfComponent(graph.Components[component[0]])
+			if level := graph.landmarkLevel(best.Asn); level != graph.K-1 {
+				t.Errorf("k=%d: the highest landmark of %v has level %d", graph.K, component, level)
+			}
+		}
+
+		graph.AddEdge(4, 6, 1)
+		checkRoutes(t, graph, [][]int{{1, 2, 3, 4, 5, 6, 7}})
+	}
+}
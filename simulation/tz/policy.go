@@ -0,0 +1,219 @@
+package tz
+
+import (
+	"sort"
+
+	. "dedis.epfl.ch/core"
+)
+
+// maxHops returns the number of hops of a path towards a landmark whose first entry is
+// 'start': each hop brings the path EdgeWeight closer to the landmark
+// (it protects from loops in corrupted structures)
+func maxHops(start *dijkstraNode) int {
+	return int(start.distance/EdgeWeight) + 1
+}
+
+// witnessHops returns the hops from 'asn' to its witness of the given round
+// or nil if the chain of next-hops is broken
+func (g *Graph) witnessHops(asn int, round int) []int {
+	start, exists := (*g.Witnesses[round])[asn]
+	if !exists {
+		return nil
+	}
+
+	hops := make([]int, 0, 4)
+
+	for len(hops) < maxHops(start) {
+		dij, exists := (*g.Witnesses[round])[asn]
+		if !exists {
+			return nil
+		}
+
+		hops = append(hops, asn)
+
+		if asn == dij.parent.Asn {
+			return hops
+		}
+		asn = dij.nextHop.Asn
+	}
+
+	return nil
+}
+
+// bunchHops returns the hops from 'asn' to the landmark 'w' of its bunch
+// or nil if the chain of next-hops is broken
+func (g *Graph) bunchHops(asn int, w int) []int {
+	start, exists := g.Bunches[asn][w]
+	if !exists {
+		return nil
+	}
+
+	hops := make([]int, 0, 4)
+
+	for len(hops) < maxHops(start) {
+		dij, exists := g.Bunches[asn][w]
+		if !exists {
+			return nil
+		}
+
+		hops = append(hops, asn)
+
+		if asn == w {
+			return hops
+		}
+		asn = dij.nextHop.Asn
+	}
+
+	return nil
+}
+
+// joinAtLandmark concatenates the path from 'a' to a landmark with the
+// reversed path from 'b' to the same landmark, removing loops
+// Both paths must end with the landmark
+func joinAtLandmark(hopsAtoW []int, hopsBtoW []int) []int {
+	// trimPrefix expects the landmark only at the end of the first path
+	hopsAtoW, hopsBtoW = trimPrefix(hopsAtoW, hopsBtoW[:len(hopsBtoW)-1])
+
+	path := make([]int, 0, len(hopsAtoW)+len(hopsBtoW))
+	path = append(path, hopsAtoW...)
+	for idx := len(hopsBtoW) - 1; idx >= 0; idx-- {
+		path = append(path, hopsBtoW[idx])
+	}
+
+	return path
+}
+
+// TreePath returns the path from 'from' to 'to' in the tree (cluster) of the landmark 'w',
+// i.e. the concatenation of their bunch routes to w used by ApproximateBestPath
+// returns nil if w is not in both bunches (or a chain of next-hops is broken)
+func (g *Graph) TreePath(from int, to int, w int) []int {
+	hopsFrom := g.bunchHops(from, w)
+	hopsTo := g.bunchHops(to, w)
+	if hopsFrom == nil || hopsTo == nil {
+		return nil
+	}
+	return joinAtLandmark(hopsFrom, hopsTo)
+}
+
+// linkTypes returns the type of each link traversed by the path
+func (g *Graph) linkTypes(path []int) []int {
+	types := make([]int, 0, len(path))
+	for idx := 1; idx < len(path); idx++ {
+		types = append(types, g.Nodes[path[idx-1]].GetNeighborType(g.Nodes[path[idx]]))
+	}
+	return types
+}
+
+// landmarkLevel returns the highest level of landmarks containing 'asn'
+func (g *Graph) landmarkLevel(asn int) int {
+	level := 0
+	for lvl := 1; lvl < g.K; lvl++ {
+		if _, isLandmark := g.Landmarks[lvl][g.Nodes[asn]]; isLandmark {
+			level = lvl
+		}
+	}
+	return level
+}
+
+// candidatePaths returns, for each level, the paths from 'from' to 'to'
+// passing through the witnesses of both endpoints and through the landmarks
+// shared by both bunches (in increasing ASN order, so that ties are broken the same way)
+func (g *Graph) candidatePaths(from int, to int) map[int][][]int {
+	candidates := make(map[int][][]int)
+
+	addCandidate := func(level int, hopsFrom []int, hopsTo []int) {
+		if hopsFrom != nil && hopsTo != nil {
+			candidates[level] = append(candidates[level], joinAtLandmark(hopsFrom, hopsTo))
+		}
+	}
+
+	for i := 0; i < g.K; i++ {
+		if w := (*g.Witnesses[i])[from].parent.Asn; g.Bunches[to][w] != nil {
+			addCandidate(i, g.witnessHops(from, i), g.bunchHops(to, w))
+		}
+		if w := (*g.Witnesses[i])[to].parent.Asn; g.Bunches[from][w] != nil {
+			addCandidate(i, g.bunchHops(from, w), g.witnessHops(to, i))
+		}
+	}
+
+	shared := make([]int, 0, len(g.Bunches[from]))
+	for w := range g.Bunches[from] {
+		if _, inBoth := g.Bunches[to][w]; inBoth {
+			shared = append(shared, w)
+		}
+	}
+	sort.Ints(shared)
+
+	for _, w := range shared {
+		addCandidate(g.landmarkLevel(w), g.bunchHops(from, w), g.bunchHops(to, w))
+	}
+
+	return candidates
+}
+
+// approximateValleyFreePath returns the shortest valley-free path among the
+// candidates of the lowest possible level
+// It returns (NoValleyFreePath, nil) if no candidate respects the no-valley rule
+func (g *Graph) approximateValleyFreePath(from int, to int) (int, []int) {
+	candidates := g.candidatePaths(from, to)
+
+	for i := 0; i < g.K; i++ {
+		var shortest []int
+		for _, path := range candidates[i] {
+			if RespectsNoValley(g.linkTypes(path)) && (shortest == nil || len(path) < len(shortest)) {
+				shortest = path
+			}
+		}
+
+		if shortest != nil {
+			return i, shortest
+		}
+	}
+
+	return NoValleyFreePath, nil
+}
+
+// reversePath returns a reversed copy of the path
+func reversePath(path []int) []int {
+	reversed := make([]int, 0, len(path))
+	for idx := len(path) - 1; idx >= 0; idx-- {
+		reversed = append(reversed, path[idx])
+	}
+	return reversed
+}
+
+// ApproximateBestPath evaluates the query in both directions and returns the shorter
+// path along with the level of landmarks used
+// If allLandmarks is set, every landmark shared by the two bunches (and the witnesses
+// of both endpoints) is considered as well
+// If the graph is ValleyFree, only valley-free paths are considered
+// (like ApproximatePath, it returns a nil path if there is none)
+func (g *Graph) ApproximateBestPath(from int, to int, allLandmarks bool) (int, []int) {
+	bestLevel, bestPath := g.ApproximatePath(from, to)
+
+	consider := func(level int, path []int) {
+		if path == nil || (g.ValleyFree && !RespectsNoValley(g.linkTypes(path))) {
+			return
+		}
+		if bestPath == nil || len(path) < len(bestPath) {
+			bestLevel, bestPath = level, path
+		}
+	}
+
+	backLevel, backPath := g.ApproximatePath(to, from)
+	if backPath != nil {
+		consider(backLevel, reversePath(backPath))
+	}
+
+	// Levels are visited in order, so that ties go to the lowest level
+	if allLandmarks && bestPath != nil {
+		candidates := g.candidatePaths(from, to)
+		for level := 0; level < g.K; level++ {
+			for _, path := range candidates[level] {
+				consider(level, path)
+			}
+		}
+	}
+
+	return bestLevel, bestPath
+}
@@ -0,0 +1,95 @@
+package tz
+
+import (
+	"reflect"
+	"testing"
+
+	. "dedis.epfl.ch/core"
+)
+
+// checkWalk reports the paths that do not follow links from 'from' to 'to'
+func checkWalk(t *testing.T, g *Graph, from int, to int, path []int) {
+	if len(path) == 0 || path[0] != from || path[len(path)-1] != to {
+		t.Errorf("k=%d: path %v does not lead from %d to %d", g.K, path, from, to)
+		return
+	}
+	for idx := 1; idx < len(path); idx++ {
+		if g.Nodes[path[idx-1]].GetNeighborIndex(g.Nodes[path[idx]]) < 0 {
+			t.Errorf("k=%d: path %v from %d to %d uses the missing link %d-%d", g.K, path, from, to, path[idx-1], path[idx])
+		}
+	}
+}
+
+func TestApproximateBestPathIsNoLonger(t *testing.T) {
+	for _, landmarks := range testLandmarks {
+		graph := loadTestGraph(t, landmarks)
+
+		for from := range graph.Nodes {
+			for to := range graph.Nodes {
+				if from == to {
+					continue
+				}
+
+				_, path := graph.ApproximatePath(from, to)
+				_, backPath := graph.ApproximatePath(to, from)
+				_, best := graph.ApproximateBestPath(from, to, false)
+				_, bestOfAll := graph.ApproximateBestPath(from, to, true)
+
+				checkWalk(t, graph, from, to, best)
+				checkWalk(t, graph, from, to, bestOfAll)
+
+				if len(best) > len(path) || len(best) > len(backPath) {
+					t.Errorf("k=%d: best path %v from %d to %d, one-directional %v and %v", graph.K, best, from, to, path, backPath)
+				}
+				if len(bestOfAll) > len(best) {
+					t.Errorf("k=%d: best path %v from %d to %d through all landmarks, %v through the witnesses", graph.K, bestOfAll, from, to, best)
+				}
+			}
+		}
+	}
+}
+
+func TestApproximateBestPathIsDeterministic(t *testing.T) {
+	for _, landmarks := range testLandmarks {
+		graph := loadTestGraph(t, landmarks)
+
+		for from := range graph.Nodes {
+			for to := range graph.Nodes {
+				level, path := graph.ApproximateBestPath(from, to, true)
+				for run := 0; run < 20; run++ {
+					if otherLevel, other := graph.ApproximateBestPath(from, to, true); otherLevel != level || !reflect.DeepEqual(other, path) {
+						t.Fatalf("k=%d: %d to %d at level %d through %v, then at level %d through %v", graph.K, from, to, level, path, otherLevel, other)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestApproximateBestPathIsValleyFree(t *testing.T) {
+	for _, landmarks := range testLandmarks {
+		graph := loadTestGraph(t, landmarks)
+		graph.ValleyFree = true
+
+		for from := range graph.Nodes {
+			for to := range graph.Nodes {
+				if from == to {
+					continue
+				}
+
+				level, best := graph.ApproximateBestPath(from, to, true)
+				if best == nil {
+					if level != NoValleyFreePath {
+						t.Errorf("k=%d: no path from %d to %d at level %d", graph.K, from, to, level)
+					}
+					continue
+				}
+
+				checkWalk(t, graph, from, to, best)
+				if !RespectsNoValley(graph.linkTypes(best)) {
+					t.Errorf("k=%d: best path %v from %d to %d has a valley", graph.K, best, from, to)
+				}
+			}
+		}
+	}
+}
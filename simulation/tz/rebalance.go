@@ -0,0 +1,244 @@
+package tz
+
+import (
+	"math/rand"
+	"sort"
+
+	. "dedis.epfl.ch/core"
+	"dedis.epfl.ch/u"
+)
+
+// RebalanceConfig contains the thresholds that trigger the re-election of landmarks
+// (a zero value disables the corresponding rule)
+type RebalanceConfig struct {
+	// Landmarks with a larger cluster are promoted to the next level
+	MaxClusterSize int
+	// Landmarks (of level >= 1) with a smaller cluster are demoted to the previous level
+	MinClusterSize int
+	// While the estimated stretch is higher, the landmark with the largest
+	// cluster (below the top level) is promoted
+	MaxStretch float64
+	// Number of pairs used to estimate the stretch (before and after the changes)
+	StretchSamples int
+	// Maximum number of promotions and demotions in a single call
+	MaxChanges int
+}
+
+// IsValid panics if the stretch rule is enabled without samples to estimate the stretch
+func (c RebalanceConfig) IsValid() {
+	if c.MaxStretch > 0 && c.StretchSamples <= 0 {
+		panic("MaxStretch requires StretchSamples >= 1, got " + u.Str(c.StretchSamples))
+	}
+}
+
+// RebalanceReport describes the changes performed by Rebalance
+type RebalanceReport struct {
+	Promoted        []int
+	Demoted         []int
+	RebuiltClusters int
+	ImpactedNodes   int
+	StretchBefore   float64
+	StretchAfter    float64
+}
+
+// Rebalance promotes or demotes landmarks according to the thresholds of 'config',
+// rebuilding only the affected witnesses and clusters
+func (g *Graph) Rebalance(config RebalanceConfig) RebalanceReport {
+
+	g.kIsValid()
+	config.IsValid()
+
+	report := RebalanceReport{
+		Promoted: make([]int, 0),
+		Demoted:  make([]int, 0),
+	}
+
+	impactedArea := make(map[int]bool)
+
+	changesLeft := func() bool {
+		return config.MaxChanges <= 0 || len(report.Promoted)+len(report.Demoted) < config.MaxChanges
+	}
+
+	apply := func(demoted *Node, demotedLevel int, promoted *Node, promotedLevel int) {
+		impacted, _, rebuilt := g.relevel(demoted, demotedLevel, promoted, promotedLevel)
+		for asn := range impacted {
+			impactedArea[asn] = true
+		}
+		report.RebuiltClusters += rebuilt
+	}
+
+	if config.StretchSamples > 0 {
+		report.StretchBefore = g.EstimateStretch(config.StretchSamples)
+	}
+
+	// Sizes are computed once, so that each landmark moves at most one level per call
+	sizes := g.clusterSizes()
+
+	for _, w := range g.landmarksBySize(sizes, true) {
+		level := g.landmarkLevel(w)
+		if config.MaxClusterSize <= 0 || sizes[w] <= config.MaxClusterSize || !changesLeft() {
+			break
+		}
+		if level < g.K-1 {
+			apply(nil, 0, g.Nodes[w], level+1)
+			report.Promoted = append(report.Promoted, w)
+		}
+	}
+
+	for _, w := range g.landmarksBySize(sizes, false) {
+		level := g.landmarkLevel(w)
+		if config.MinClusterSize <= 0 || sizes[w] >= config.MinClusterSize || !changesLeft() {
+			break
+		}
+		// Levels cannot remain empty, otherwise their witnesses are not defined
+		if level >= 1 && len(g.Landmarks[level]) > 1 && !report.moved(w) {
+			apply(g.Nodes[w], level-1, nil, 0)
+			report.Demoted = append(report.Demoted, w)
+		}
+	}
+
+	if config.StretchSamples > 0 {
+		report.StretchAfter = g.EstimateStretch(config.StretchSamples)
+
+		for config.MaxStretch > 0 && report.StretchAfter > config.MaxStretch && changesLeft() {
+			sizes = g.clusterSizes()
+
+			var candidate *Node
+			for _, w := range g.landmarksBySize(sizes, true) {
+				if g.landmarkLevel(w) < g.K-1 && !report.moved(w) {
+					candidate = g.Nodes[w]
+					break
+				}
+			}
+			if candidate == nil {
+				break
+			}
+
+			apply(nil, 0, candidate, g.landmarkLevel(candidate.Asn)+1)
+			report.Promoted = append(report.Promoted, candidate.Asn)
+
+			report.StretchAfter = g.EstimateStretch(config.StretchSamples)
+		}
+	}
+
+	report.ImpactedNodes = len(impactedArea)
+
+	return report
+}
+
+// moved checks if a landmark has already been promoted or demoted
+func (r *RebalanceReport) moved(asn int) bool {
+	for _, promoted := range r.Promoted {
+		if promoted == asn {
+			return true
+		}
+	}
+	for _, demoted := range r.Demoted {
+		if demoted == asn {
+			return true
+		}
+	}
+	return false
+}
+
+// clusterSizes returns the number of nodes in the cluster of each landmark
+func (g *Graph) clusterSizes() map[int]int {
+	sizes := make(map[int]int)
+	for _, bunch := range g.Bunches {
+		for w := range bunch {
+			sizes[w]++
+		}
+	}
+	return sizes
+}
+
+// landmarksBySize returns the ASNs of landmarks sorted by cluster size
+// (ties are broken by ASN, to make re-elections reproducible)
+func (g *Graph) landmarksBySize(sizes map[int]int, decreasing bool) []int {
+	landmarks := make([]int, 0, len(sizes))
+	for w := range sizes {
+		landmarks = append(landmarks, w)
+	}
+
+	sort.Slice(landmarks, func(i, j int) bool {
+		a, b := landmarks[i], landmarks[j]
+		if sizes[a] != sizes[b] {
+			return (sizes[a] > sizes[b]) == decreasing
+		}
+		return a < b
+	})
+
+	return landmarks
+}
+
+// EstimateStretch returns the average ratio between the TZ distance estimate and
+// the hop distance over 'samples' random pairs of distinct ASes
+// (both ignore policies, so ValleyFree graphs are estimated as well)
+// Pairs that are not connected are skipped (0 if no pair is connected)
+func (g *Graph) EstimateStretch(samples int) float64 {
+	asns := make([]int, 0, len(g.Nodes))
+	for asn := range g.Nodes {
+		asns = append(asns, asn)
+	}
+	sort.Ints(asns)
+
+	if len(asns) < 2 || samples <= 0 {
+		return 0
+	}
+
+	// The hop distances of an origin are computed once
+	distancesFrom := make(map[int]map[int]int64)
+
+	var stretch float64
+	measured := 0
+	for s := 0; s < samples; s++ {
+		from := asns[rand.Intn(len(asns))]
+		to := asns[rand.Intn(len(asns))]
+		for to == from {
+			to = asns[rand.Intn(len(asns))]
+		}
+
+		estimate, connected := g.estimateDistance(from, to)
+		if !connected {
+			continue
+		}
+
+		if _, cached := distancesFrom[from]; !cached {
+			distancesFrom[from] = g.hopDistances(from)
+		}
+		hops, reachable := distancesFrom[from][to]
+		if !reachable {
+			continue
+		}
+
+		stretch += float64(estimate.Distance) / float64(hops)
+		measured++
+	}
+
+	if measured == 0 {
+		return 0
+	}
+
+	return stretch / float64(measured)
+}
+
+// hopDistances returns the distance (ignoring policies) of every AS from 'from'
+func (g *Graph) hopDistances(from int) map[int]int64 {
+	distances := map[int]int64{from: 0}
+
+	visiting := []int{from}
+	for len(visiting) > 0 {
+		next := make([]int, 0)
+		for _, asn := range visiting {
+			for _, l := range g.Nodes[asn].Links {
+				if _, visited := distances[l]; !visited {
+					distances[l] = distances[asn] + EdgeWeight
+					next = append(next, l)
+				}
+			}
+		}
+		visiting = next
+	}
+
+	return distances
+}
@@ -0,0 +1,158 @@
+package tz
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	. "dedis.epfl.ch/core"
+)
+
+// landmarksOf returns the landmarks of levels 1 to k-1, like testLandmarks
+func landmarksOf(g *Graph) [][]int {
+	landmarks := make([][]int, 0, g.K-1)
+	for level := 1; level < g.K; level++ {
+		asns := make([]int, 0, len(g.Landmarks[level]))
+		for nd := range g.Landmarks[level] {
+			asns = append(asns, nd.Asn)
+		}
+		sort.Ints(asns)
+		landmarks = append(landmarks, asns)
+	}
+	return landmarks
+}
+
+// checkRebalanced checks that the landmarks are nested, that the route of each witness
+// leads to a landmark of its level, and that the bunches respect the witness distances
+func checkRebalanced(t *testing.T, graph *Graph) {
+	landmarks := landmarksOf(graph)
+
+	for level := 1; level < graph.K; level++ {
+		if len(graph.Landmarks[level]) == 0 {
+			t.Errorf("%v: level %d is empty", landmarks, level)
+		}
+		for nd := range graph.Landmarks[level] {
+			if !graph.Landmarks[level-1][nd] {
+				t.Errorf("%v: %d is a landmark of level %d but not of level %d", landmarks, nd.Asn, level, level-1)
+			}
+		}
+	}
+
+	for level := 0; level < graph.K; level++ {
+		witnesses := *graph.Witnesses[level]
+		for asn := range graph.Nodes {
+			witness, exists := witnesses[asn]
+			if !exists || !graph.Landmarks[level][witness.parent] {
+				t.Errorf("%v: witness %d of %d is %v", landmarks, level, asn, witness)
+				continue
+			}
+			if asn == witness.parent.Asn {
+				continue
+			}
+			if next := witnesses[witness.nextHop.Asn]; next.parent != witness.parent || next.distance+EdgeWeight != witness.distance {
+				t.Errorf("%v: %d reaches %d at %d through %d, that reaches %d at %d", landmarks, asn, witness.parent.Asn, witness.distance, witness.nextHop.Asn, next.parent.Asn, next.distance)
+			}
+		}
+	}
+
+	// Clusters are trees: their members are closer to the landmark than to their witness,
+	// or on the route of a member
+	clusters := make(map[int]map[int]*dijkstraNode)
+	for asn, bunch := range graph.Bunches {
+		for w, entry := range bunch {
+			if clusters[w] == nil {
+				clusters[w] = make(map[int]*dijkstraNode)
+			}
+			clusters[w][asn] = entry
+		}
+	}
+	for w, cluster := range clusters {
+		onRoute := make(map[int]bool)
+		for asn, entry := range cluster {
+			if asn == w {
+				continue
+			}
+			onRoute[entry.nextHop.Asn] = true
+			if next, exists := cluster[entry.nextHop.Asn]; !exists || next.distance+EdgeWeight != entry.distance {
+				t.Errorf("%v: %d reaches %d at %d through %d, outside of its cluster", landmarks, asn, w, entry.distance, entry.nextHop.Asn)
+			}
+		}
+		for asn, entry := range cluster {
+			if bound := (*graph.Witnesses[graph.landmarkLevel(w)+1])[asn]; entry.distance >= bound.distance && !onRoute[asn] {
+				t.Errorf("%v: %d in the cluster of %d at %d, witness at %d", landmarks, asn, w, entry.distance, bound.distance)
+			}
+		}
+	}
+}
+
+func TestRebalanceKeepsStructuresConsistent(t *testing.T) {
+	configs := []RebalanceConfig{
+		{MaxClusterSize: 2},
+		{MinClusterSize: 4},
+		{MaxClusterSize: 2, MinClusterSize: 4, MaxChanges: 2},
+	}
+	promoted, demoted := 0, 0
+
+	for _, landmarks := range testLandmarks {
+		for _, config := range configs {
+			graph := loadTestGraph(t, landmarks)
+			report := graph.Rebalance(config)
+
+			promoted += len(report.Promoted)
+			demoted += len(report.Demoted)
+			checkRebalanced(t, graph)
+		}
+	}
+
+	if promoted == 0 || demoted == 0 {
+		t.Errorf("%d promotions and %d demotions", promoted, demoted)
+	}
+}
+
+func TestRebalanceRequiresStretchSamples(t *testing.T) {
+	graph := loadTestGraph(t, testLandmarks[2])
+	defer func() {
+		if recover() == nil {
+			t.Error("MaxStretch accepted without StretchSamples")
+		}
+	}()
+	graph.Rebalance(RebalanceConfig{MaxStretch: 1.5})
+}
+
+func TestRebalanceReducesStretch(t *testing.T) {
+	rand.Seed(1)
+	graph := loadGraphFile(t, "../data/tree.csv", [][]int{{8, 10}})
+
+	report := graph.Rebalance(RebalanceConfig{MaxStretch: 1, StretchSamples: 200, MaxChanges: 3})
+	if len(report.Promoted) == 0 || report.StretchAfter >= report.StretchBefore {
+		t.Errorf("report %+v", report)
+	}
+	checkRebalanced(t, graph)
+}
+
+func TestEstimateStretch(t *testing.T) {
+	rand.Seed(1)
+
+	for _, landmarks := range testLandmarks {
+		graph := loadTestGraph(t, landmarks)
+		if stretch := graph.EstimateStretch(0); stretch != 0 {
+			t.Errorf("%v: stretch %f without samples", landmarks, stretch)
+		}
+
+		// The estimates measure walks, so they are never below the hop distance
+		if stretch := graph.EstimateStretch(100); stretch < 1 {
+			t.Errorf("%v: stretch %f", landmarks, stretch)
+		}
+
+		graph.ValleyFree = true
+		if stretch := graph.EstimateStretch(100); stretch < 1 {
+			t.Errorf("%v: stretch %f on a valley-free graph", landmarks, stretch)
+		}
+	}
+
+	// Route distances of data/tree.csv are a metric, so the TZ bound holds
+	graph := loadGraphFile(t, "../data/tree.csv", [][]int{{1, 4, 6}, {1}})
+	if stretch := graph.EstimateStretch(100); stretch < 1 || stretch > float64(2*graph.K-1) {
+		t.Errorf("stretch %f on data/tree.csv", stretch)
+	}
+}
@@ -0,0 +1,238 @@
+package tz
+
+import (
+	"math/bits"
+	"sort"
+
+	. "dedis.epfl.ch/core"
+)
+
+// LabelEntry locates a destination in the tree (cluster) of one of its witnesses
+type LabelEntry struct {
+	Level    int
+	Landmark int
+	Dfs      int
+}
+
+// Label is the destination address used by the routing scheme
+type Label []LabelEntry
+
+// treeEntry is the state kept by a node for the tree of a landmark
+// Ports are indexes in the Links of the node, children are sorted by DFS number
+type treeEntry struct {
+	parentPort int
+	first      int
+	last       int
+	childPorts []int
+	childFirst []int
+}
+
+// RoutingTable contains the tree entries of a node, by landmark
+type RoutingTable map[int]*treeEntry
+
+// RoutingScheme contains the per-AS state of the Thorup-Zwick routing scheme
+// (interval routing on the trees spanned by the clusters)
+type RoutingScheme struct {
+	Tables map[int]RoutingTable
+	Labels map[int]Label
+	nodes  map[int]*Node
+	idBits int
+}
+
+// bitsFor returns the number of bits needed to represent 'values' different values
+func bitsFor(values int) int {
+	if values <= 1 {
+		return 1
+	}
+	return bits.Len(uint(values - 1))
+}
+
+// BuildRoutingScheme derives routing tables and labels from Bunches and Witnesses
+// A node is in the tree of landmark w if w is in its bunch, its parent is the
+// next-hop towards w. Nodes whose chain to w is broken are left out of the tree
+func (g *Graph) BuildRoutingScheme() *RoutingScheme {
+	scheme := RoutingScheme{
+		Tables: make(map[int]RoutingTable),
+		Labels: make(map[int]Label),
+		nodes:  g.Nodes,
+		idBits: bitsFor(len(g.Nodes)),
+	}
+
+	// children[w][p] contains the children of p in the tree of w
+	children := make(map[int]map[int][]int)
+	for asn, bunch := range g.Bunches {
+		scheme.Tables[asn] = make(RoutingTable)
+		for w, dij := range bunch {
+			if _, exists := children[w]; !exists {
+				children[w] = make(map[int][]int)
+			}
+			if asn != w {
+				children[w][dij.nextHop.Asn] = append(children[w][dij.nextHop.Asn], asn)
+			}
+		}
+	}
+
+	// dfsNumber[w][x] is the DFS number of x in the tree of w
+	dfsNumber := make(map[int]map[int]int)
+
+	for w, treeChildren := range children {
+		if _, isRoot := g.Bunches[w][w]; !isRoot {
+			continue
+		}
+		dfsNumber[w] = scheme.numberTree(w, treeChildren, &g.Bunches)
+	}
+
+	// Labels: the position of the node in the trees of its witnesses
+	for asn := range g.Nodes {
+		label := make(Label, 0, g.K)
+		for i := 0; i < g.K; i++ {
+			witness, exists := (*g.Witnesses[i])[asn]
+			if !exists {
+				continue
+			}
+			w := witness.parent.Asn
+			if len(label) > 0 && label[len(label)-1].Landmark == w {
+				continue
+			}
+			if dfs, inTree := dfsNumber[w][asn]; inTree {
+				label = append(label, LabelEntry{Level: i, Landmark: w, Dfs: dfs})
+			}
+		}
+		scheme.Labels[asn] = label
+	}
+
+	return &scheme
+}
+
+// numberTree assigns DFS intervals to the tree rooted at 'w' and fills the tables
+// returns the DFS number of each node of the tree
+func (rs *RoutingScheme) numberTree(w int, treeChildren map[int][]int, bunches *Clusters) map[int]int {
+	numbers := make(map[int]int)
+
+	type visit struct {
+		asn  int
+		exit bool
+	}
+
+	counter := 0
+	stack := []visit{{asn: w}}
+
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if top.exit {
+			rs.Tables[top.asn][w].last = counter - 1
+			continue
+		}
+
+		numbers[top.asn] = counter
+		entry := treeEntry{parentPort: -1, first: counter}
+		rs.Tables[top.asn][w] = &entry
+		counter++
+
+		stack = append(stack, visit{asn: top.asn, exit: true})
+
+		// Visit children in decreasing order, so that they are numbered in increasing order
+		kids := treeChildren[top.asn]
+		sort.Sort(sort.Reverse(sort.IntSlice(kids)))
+		for _, kid := range kids {
+			stack = append(stack, visit{asn: kid})
+		}
+	}
+
+	// Now that every node is numbered, fill ports
+	for x := range numbers {
+		entry := rs.Tables[x][w]
+		if x != w {
+			entry.parentPort = rs.nodes[x].GetNeighborIndex((*bunches)[x][w].nextHop)
+		}
+		kids := treeChildren[x]
+		sort.Slice(kids, func(i, j int) bool { return numbers[kids[i]] < numbers[kids[j]] })
+		for _, kid := range kids {
+			entry.childPorts = append(entry.childPorts, rs.nodes[x].GetNeighborIndex(rs.nodes[kid]))
+			entry.childFirst = append(entry.childFirst, numbers[kid])
+		}
+	}
+
+	return numbers
+}
+
+// header returns the entry of the label of 'to' used by a packet sent from 'from':
+// the first entry whose tree is known by the origin (nil if there is none)
+func (rs *RoutingScheme) header(from int, to int) *LabelEntry {
+	for idx, entry := range rs.Labels[to] {
+		if _, known := rs.Tables[from][entry.Landmark]; known {
+			return &rs.Labels[to][idx]
+		}
+	}
+	return nil
+}
+
+// Tree returns the landmark whose tree is used by Forward to route from 'from' to 'to'
+// returns false if no tree is shared by the origin and the label
+func (rs *RoutingScheme) Tree(from int, to int) (int, bool) {
+	if header := rs.header(from, to); header != nil {
+		return header.Landmark, true
+	}
+	return -1, false
+}
+
+// Forward routes a packet from 'from' to 'to' using only the tables of the
+// traversed nodes and the label of the destination
+// The tree used is the first entry of the label known by the origin (see Tree)
+// returns false if no tree is shared by the origin and the label, or if the packet
+// is not delivered (it loops, or it must go up from the landmark)
+func (rs *RoutingScheme) Forward(from int, to int) ([]int, bool) {
+	header := rs.header(from, to)
+	if header == nil {
+		return nil, false
+	}
+
+	path := []int{from}
+
+	for cursor := from; len(path) <= len(rs.nodes); {
+		entry := rs.Tables[cursor][header.Landmark]
+
+		var port int
+		switch {
+		case entry.first == header.Dfs:
+			return path, cursor == to
+		case header.Dfs > entry.first && header.Dfs <= entry.last:
+			// Go down, towards the child whose interval contains the destination
+			child := sort.Search(len(entry.childFirst), func(i int) bool { return entry.childFirst[i] > header.Dfs }) - 1
+			port = entry.childPorts[child]
+		case entry.parentPort < 0:
+			// The destination is not in the tree of the landmark
+			return path, false
+		default:
+			// Go up, towards the landmark
+			port = entry.parentPort
+		}
+
+		cursor = rs.nodes[cursor].Links[port]
+		path = append(path, cursor)
+	}
+
+	// Forwarding loop
+	return path, false
+}
+
+// TableBits estimates the size (in bits) of the routing table of a node
+func (rs *RoutingScheme) TableBits(asn int) int {
+	portBits := bitsFor(len(rs.nodes[asn].Links))
+
+	size := 0
+	for _, entry := range rs.Tables[asn] {
+		// Landmark, parent port, interval and (port, first) for each child
+		size += rs.idBits + portBits + 2*rs.idBits + len(entry.childPorts)*(portBits+rs.idBits)
+	}
+
+	return size
+}
+
+// LabelBits estimates the size (in bits) of the label of a node
+func (rs *RoutingScheme) LabelBits(asn int) int {
+	// Landmark and DFS number for each entry
+	return len(rs.Labels[asn]) * 2 * rs.idBits
+}
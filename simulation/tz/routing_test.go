@@ -0,0 +1,50 @@
+package tz
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestForwardFollowsTreePath(t *testing.T) {
+	for _, landmarks := range testLandmarks {
+		graph := loadTestGraph(t, landmarks)
+		scheme := graph.BuildRoutingScheme()
+
+		for from := range graph.Nodes {
+			for to := range graph.Nodes {
+				landmark, hasTree := scheme.Tree(from, to)
+				if !hasTree {
+					continue
+				}
+
+				forwarded, delivered := scheme.Forward(from, to)
+				if !delivered {
+					t.Errorf("k=%d: packet from %d to %d not delivered (%v)", graph.K, from, to, forwarded)
+					continue
+				}
+
+				if expected := graph.TreePath(from, to, landmark); !reflect.DeepEqual(forwarded, expected) {
+					t.Errorf("k=%d: forwarded %v from %d to %d, expected %v in the tree of %d", graph.K, forwarded, from, to, expected, landmark)
+				}
+			}
+		}
+	}
+}
+
+func TestForwardWithoutTree(t *testing.T) {
+	graph := loadTestGraph(t, testLandmarks[0])
+	scheme := graph.BuildRoutingScheme()
+
+	// The origin does not know the tree of the label
+	scheme.Labels[7] = Label{{Level: 0, Landmark: 7, Dfs: 0}}
+	delete(scheme.Tables[1], 7)
+	if _, delivered := scheme.Forward(1, 7); delivered {
+		t.Error("packet delivered without a shared tree")
+	}
+
+	// The destination is not in the tree: the packet reaches the landmark and cannot go up
+	scheme.Labels[7] = Label{{Level: 1, Landmark: 3, Dfs: len(graph.Nodes)}}
+	if path, delivered := scheme.Forward(1, 7); delivered || path[len(path)-1] != 3 {
+		t.Errorf("packet outside of the tree was forwarded along %v", path)
+	}
+}
@@ -0,0 +1,118 @@
+package tz
+
+import (
+	. "dedis.epfl.ch/core"
+	"dedis.epfl.ch/u"
+)
+
+// Kinds of update messages exchanged during a repair
+const (
+	InvalidationMessage = 0
+	AnnouncementMessage = 1
+)
+
+// RepairMessage is an update about a landmark that an AS sends to its neighbors
+// Level is the round of the witness, or -1 for bunch entries
+type RepairMessage struct {
+	Kind            int
+	Asn             int
+	Level           int
+	Landmark        int
+	Fanout          int
+	DistanceChanged bool
+}
+
+// RepairTrace records the messages that a distributed repair would need
+// Messages are counted once per receiving neighbor, like the messagesSent of bgp.Graph.Activate
+// ImpactedArea is the set of ASes involved in the repair (also when the deletion split
+// the graph, and RemoveEdge returns the separated ASes instead)
+type RepairTrace struct {
+	Messages     []RepairMessage
+	ImpactedArea map[int]bool
+}
+
+// Count returns the number of messages of a given kind
+func (t *RepairTrace) Count(kind int) int {
+	count := 0
+	for _, msg := range t.Messages {
+		if msg.Kind == kind {
+			count += msg.Fanout
+		}
+	}
+	return count
+}
+
+// Total returns the number of messages of any kind
+func (t *RepairTrace) Total() int {
+	return t.Count(InvalidationMessage) + t.Count(AnnouncementMessage)
+}
+
+// DistanceChanges returns the number of entries whose distance has changed
+func (t *RepairTrace) DistanceChanges() int {
+	changes := 0
+	for _, msg := range t.Messages {
+		if msg.Kind == AnnouncementMessage && msg.DistanceChanged {
+			changes++
+		}
+	}
+	return changes
+}
+
+// Append adds the messages and the impacted area of another trace
+func (t *RepairTrace) Append(other *RepairTrace) {
+	t.Messages = append(t.Messages, other.Messages...)
+	if len(other.ImpactedArea) > 0 {
+		if t.ImpactedArea == nil {
+			t.ImpactedArea = make(map[int]bool)
+		}
+		u.Union(t.ImpactedArea, other.ImpactedArea)
+	}
+}
+
+// invalidate records the withdrawal of a route, sent to every neighbor
+func (t *RepairTrace) invalidate(nd *Node, level int, landmark int) {
+	if t == nil {
+		return
+	}
+	t.Messages = append(t.Messages, RepairMessage{
+		Kind:     InvalidationMessage,
+		Asn:      nd.Asn,
+		Level:    level,
+		Landmark: landmark,
+		Fanout:   len(nd.Links),
+	})
+}
+
+// announce records the advertisement of a new route, sent to the neighbors
+// allowed by Gao-Rexford rules ('previous' is nil if there was no route before)
+func (t *RepairTrace) announce(nodes *map[int]*Node, route *dijkstraNode, level int, previous *dijkstraNode) {
+	if t == nil {
+		return
+	}
+
+	nd := (*nodes)[route.reference]
+
+	fanout := 0
+	for _, l := range nd.Links {
+		if l != route.nextHop.Asn && nd.CanTellAbout(route.nextHop, (*nodes)[l]) {
+			fanout++
+		}
+	}
+
+	t.Messages = append(t.Messages, RepairMessage{
+		Kind:            AnnouncementMessage,
+		Asn:             nd.Asn,
+		Level:           level,
+		Landmark:        route.parent.Asn,
+		Fanout:          fanout,
+		DistanceChanged: previous == nil || previous.distance != route.distance,
+	})
+}
+
+// routeChanged checks if a route differs from a previous one (nil if missing)
+func routeChanged(previous *dijkstraNode, route *dijkstraNode) bool {
+	return previous == nil ||
+		previous.distance != route.distance ||
+		previous.parent.Asn != route.parent.Asn ||
+		previous.nextHop.Asn != route.nextHop.Asn
+}
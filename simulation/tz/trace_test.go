@@ -0,0 +1,101 @@
+package tz
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// sortedMessages orders messages by kind, AS, level and landmark, as purges
+// visit the bunches in no particular order
+func sortedMessages(messages []RepairMessage) []RepairMessage {
+	sorted := append([]RepairMessage{}, messages...)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Asn != b.Asn {
+			return a.Asn < b.Asn
+		}
+		if a.Level != b.Level {
+			return a.Level < b.Level
+		}
+		return a.Landmark < b.Landmark
+	})
+	return sorted
+}
+
+func TestRemoveEdgeWithTrace(t *testing.T) {
+	graph := loadTestGraph(t, [][]int{{3}})
+
+	// Without 3-4, 4 reaches 3 through 6, its only neighbor left, so its new routes reach no one
+	// 4 and 6 also lose their routes towards 5 (in the bunches)
+	success, area, _, trace := graph.RemoveEdgeWithTrace(3, 4)
+	if !success {
+		t.Fatal("removal of 3-4 refused")
+	}
+	if expected := map[int]bool{3: true, 4: true, 6: true}; !reflect.DeepEqual(area, expected) || !reflect.DeepEqual(trace.ImpactedArea, expected) {
+		t.Errorf("impacted area %v, trace area %v, expected %v", area, trace.ImpactedArea, expected)
+	}
+
+	expected := []RepairMessage{
+		{Kind: InvalidationMessage, Asn: 4, Level: -1, Landmark: 3, Fanout: 1},
+		{Kind: InvalidationMessage, Asn: 4, Level: -1, Landmark: 5, Fanout: 1},
+		{Kind: InvalidationMessage, Asn: 4, Level: 1, Landmark: 3, Fanout: 1},
+		{Kind: InvalidationMessage, Asn: 6, Level: -1, Landmark: 5, Fanout: 2},
+		{Kind: AnnouncementMessage, Asn: 4, Level: -1, Landmark: 3, Fanout: 0, DistanceChanged: true},
+		{Kind: AnnouncementMessage, Asn: 4, Level: 1, Landmark: 3, Fanout: 0, DistanceChanged: true},
+	}
+	if messages := sortedMessages(trace.Messages); !reflect.DeepEqual(messages, expected) {
+		t.Errorf("messages %+v, expected %+v", messages, expected)
+	}
+
+	if count := trace.Count(InvalidationMessage); count != 5 {
+		t.Errorf("%d invalidations, expected 5", count)
+	}
+	if count := trace.Count(AnnouncementMessage); count != 0 {
+		t.Errorf("%d announcements, expected 0", count)
+	}
+	if total := trace.Total(); total != 5 {
+		t.Errorf("%d messages, expected 5", total)
+	}
+	if changes := trace.DistanceChanges(); changes != 2 {
+		t.Errorf("%d distance changes, expected 2", changes)
+	}
+}
+
+func TestRepairTraceAppend(t *testing.T) {
+	_, _, _, first := loadTestGraph(t, [][]int{{3}}).RemoveEdgeWithTrace(3, 4)
+	_, _, _, second := loadTestGraph(t, [][]int{{3}}).RemoveEdgeWithTrace(1, 2)
+
+	trace := &RepairTrace{}
+	trace.Append(&RepairTrace{})
+	if trace.ImpactedArea != nil || trace.Total() != 0 {
+		t.Errorf("appending an empty trace gave %+v", trace)
+	}
+
+	trace.Append(first)
+	trace.Append(second)
+
+	if len(trace.Messages) != len(first.Messages)+len(second.Messages) {
+		t.Errorf("%d messages, expected %d", len(trace.Messages), len(first.Messages)+len(second.Messages))
+	}
+	for _, kind := range []int{InvalidationMessage, AnnouncementMessage} {
+		if count := trace.Count(kind); count != first.Count(kind)+second.Count(kind) {
+			t.Errorf("%d messages of kind %d, expected %d", count, kind, first.Count(kind)+second.Count(kind))
+		}
+	}
+	if total := trace.Total(); total != 24 {
+		t.Errorf("%d messages, expected 24", total)
+	}
+	if changes := trace.DistanceChanges(); changes != 6 {
+		t.Errorf("%d distance changes, expected 6", changes)
+	}
+	if expected := map[int]bool{1: true, 2: true, 3: true, 4: true, 6: true, 7: true}; !reflect.DeepEqual(trace.ImpactedArea, expected) {
+		t.Errorf("impacted area %v, expected %v", trace.ImpactedArea, expected)
+	}
+	if first.ImpactedArea[1] {
+		t.Error("Append modified the impacted area of the appended trace")
+	}
+}
@@ -1,6 +1,10 @@
 package u
 
-import "strconv"
+import (
+	"math/rand"
+	"strconv"
+	"time"
+)
 
 // Int converts strings to ints
 func Int(a string) int {
@@ -42,3 +46,25 @@ func Union(acculator map[int]bool, toAdd map[int]bool) map[int]bool {
 	}
 	return acculator
 }
+
+// FixedSeed, if not 0, is used instead of the current time to seed the random generator
+var FixedSeed int64
+
+// LastSeed is the last seed used by SeedRandom
+var LastSeed int64
+
+// SeedRandom seeds math/rand with FixedSeed (or the current time if it's 0)
+func SeedRandom() {
+	if FixedSeed != 0 {
+		LastSeed = FixedSeed
+	} else {
+		LastSeed = time.Now().UnixNano()
+	}
+	rand.Seed(LastSeed)
+}
+
+// SeedRound reseeds math/rand for a round of a long measurement, deriving the seed
+// from LastSeed, so that a measurement resumed from a checkpoint draws the same numbers
+func SeedRound(round int) {
+	rand.Seed(LastSeed + int64(round))
+}